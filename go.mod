@@ -5,6 +5,7 @@ go 1.19
 require (
 	github.com/ProtonMail/gopenpgp/v2 v2.4.10
 	github.com/google/go-cmp v0.5.8
+	github.com/klauspost/compress v1.15.9
 	github.com/spf13/cobra v1.5.0
 	github.com/ulikunitz/xz v0.5.10
 )
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anfernee/goapt/pkg/bazel"
+	"github.com/anfernee/goapt/pkg/lock"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bazelOptions bazel.Options
+	bazelFormat  string
+	bazelLabel   string
+	bazelOutput  string
+)
+
+var bazelCmd = &cobra.Command{
+	Use:   "bazel [lockfile]",
+	Short: "Generate Bazel repositories of the packages of a lockfile",
+	Long: `Generate the Bazel repositories fetching the .deb files of a lockfile,
+goapt.lock by default, with http_file, checking their SHA256. The formats are:
+
+  bzl     a .bzl file defining goapt_repositories(), a macro for WORKSPACE,
+          goapt, a module extension for MODULE.bazel, and PACKAGES, the
+          labels of the .deb files by "name:arch"
+  module  the MODULE.bazel lines using the extension of the .bzl file at
+          --label
+
+A mirror is either "<url>=<mirror>", replacing the URL prefix of the packages,
+or the URL of a server with the layout of goapt mirror, goapt proxy or a
+published snapshot, e.g. http://proxy:3142.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "goapt.lock"
+		if len(args) > 0 {
+			path = args[0]
+		}
+		l, err := lock.Read(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		bazelOptions.Source = filepath.Base(path)
+		repos, err := bazel.Repositories(l, &bazelOptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var b bytes.Buffer
+		switch bazelFormat {
+		case "bzl":
+			err = bazel.WriteBzl(&b, repos, &bazelOptions)
+		case "module":
+			err = bazel.WriteModule(&b, bazelLabel, repos, &bazelOptions)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown format %q\n", bazelFormat)
+			os.Exit(1)
+		}
+		if err == nil && bazelOutput != "" {
+			err = os.WriteFile(bazelOutput, b.Bytes(), 0644)
+		} else if err == nil {
+			_, err = os.Stdout.Write(b.Bytes())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(bazelCmd)
+
+	flags := bazelCmd.Flags()
+	flags.StringVar(&bazelFormat, "format", "bzl", "output format, bzl or module")
+	flags.StringVar(&bazelLabel, "label", "//:goapt.bzl", "label of the generated .bzl file, for the module format")
+	flags.StringVarP(&bazelOutput, "output", "o", "", "file to write (default stdout)")
+	flags.StringVar(&bazelOptions.Prefix, "prefix", "deb_", "prefix of the repository names")
+	flags.StringArrayVar(&bazelOptions.Mirrors, "mirror", nil, "mirror tried after the URL of the packages, repeatable")
+}
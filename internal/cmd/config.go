@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/anfernee/goapt/pkg/aptconf"
+	"github.com/anfernee/goapt/pkg/goapt"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// aptConfig is the apt configuration loaded before running any command.
+var aptConfig = aptconf.New()
+
+// architectures returns the architectures of the target system from
+// APT::Architecture and APT::Architectures, defaulting to the host's.
+func architectures() pkg.Architectures {
+	if archs := aptConfig.Architectures(); len(archs) > 0 {
+		return archs
+	}
+	return pkg.Architectures{pkg.HostArch()}
+}
+
+// loadConfig loads apt.conf and applies the settings goapt honors. If root
+// is set, the configuration is read from the root and every path setting
+// is resolved under it.
+func loadConfig(root, path string) error {
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		root = abs
+	}
+	if path == "" {
+		path = filepath.Join("/", root, "etc/apt/apt.conf")
+	}
+	c, err := aptconf.LoadFrom(path)
+	if err != nil {
+		return err
+	}
+	if root != "" {
+		c.SetRoot(root)
+	}
+	aptConfig = c
+	return nil
+}
+
+// newClient creates a goapt client configured by aptConfig.
+func newClient() (*goapt.Client, error) {
+	return goapt.New(&goapt.Options{
+		Config:        aptConfig,
+		Architectures: architectures(),
+	})
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/diff"
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffArchs  []string
+	diffStore  string
+	diffOutput string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare the packages and the releases of two repository states",
+	Long: `Compare two repository states, and report the packages added, removed,
+upgraded and downgraded, and the changed metadata of the releases. A state is
+one of:
+
+  snapshot:<name>   a snapshot of the store
+  <url>             a suite, e.g. http://archive.ubuntu.com/ubuntu/dists/focal
+  <dir>             a mirror, a published snapshot, or the cached lists of apt,
+                    e.g. /var/lib/apt/lists
+
+Packages are compared by their highest version in each state. Signatures are
+not verified.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		var states [2]*diff.State
+		for i, location := range args[:2] {
+			s, err := loadState(location)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", location, err)
+				os.Exit(1)
+			}
+			states[i] = s
+		}
+		d := diff.Compare(states[0], states[1])
+
+		switch diffOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(d)
+		case "markdown":
+			printDiffMarkdown(os.Stdout, d)
+		case "text":
+			printDiffText(os.Stdout, d)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format %q\n", diffOutput)
+			os.Exit(1)
+		}
+	},
+}
+
+// loadState loads the repository state at location.
+func loadState(location string) (*diff.State, error) {
+	switch {
+	case strings.HasPrefix(location, "snapshot:"):
+		store, err := snapshot.Open(diffStore)
+		if err != nil {
+			return nil, err
+		}
+		return diff.LoadSnapshot(store, strings.TrimPrefix(location, "snapshot:"), diffArchs)
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		return diff.LoadURL(goapt.NewFetcher(aptConfig), location, diffArchs)
+	}
+	return diff.LoadDir(location, diffArchs)
+}
+
+func printDiffText(w io.Writer, d *diff.Diff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	suite := ""
+	for _, c := range d.Releases {
+		if c.Suite != suite {
+			suite = c.Suite
+			fmt.Fprintf(w, "Release %s:\n", suite)
+		}
+		fmt.Fprintf(w, "  %s: %s => %s\n", c.Field, orNone(c.From), orNone(c.To))
+	}
+
+	sections := []struct {
+		title   string
+		changes []diff.Change
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"upgraded", d.Upgraded},
+		{"downgraded", d.Downgraded},
+	}
+	for _, s := range sections {
+		if len(s.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "The following packages were %s:\n", s.title)
+		for _, c := range s.changes {
+			switch {
+			case c.From != "" && c.To != "":
+				fmt.Fprintf(w, "  %s:%s (%s => %s)\n", c.Name, c.Arch, c.From, c.To)
+			case c.To != "":
+				fmt.Fprintf(w, "  %s:%s (%s)\n", c.Name, c.Arch, c.To)
+			default:
+				fmt.Fprintf(w, "  %s:%s (%s)\n", c.Name, c.Arch, c.From)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d upgraded and %d downgraded.\n",
+		len(d.Added), len(d.Removed), len(d.Upgraded), len(d.Downgraded))
+}
+
+func printDiffMarkdown(w io.Writer, d *diff.Diff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	cell := func(s string) string {
+		return strings.ReplaceAll(orNone(s), "|", `\|`)
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d upgraded and %d downgraded.\n",
+		len(d.Added), len(d.Removed), len(d.Upgraded), len(d.Downgraded))
+
+	if len(d.Releases) > 0 {
+		fmt.Fprintf(w, "\n## Releases\n\n| Suite | Field | Old | New |\n| --- | --- | --- | --- |\n")
+		for _, c := range d.Releases {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", cell(c.Suite), c.Field, cell(c.From), cell(c.To))
+		}
+	}
+
+	sections := []struct {
+		title   string
+		changes []diff.Change
+	}{
+		{"Added", d.Added},
+		{"Removed", d.Removed},
+		{"Upgraded", d.Upgraded},
+		{"Downgraded", d.Downgraded},
+	}
+	for _, s := range sections {
+		if len(s.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s (%d)\n\n| Package | Architecture | Old | New |\n| --- | --- | --- | --- |\n", s.title, len(s.changes))
+		for _, c := range s.changes {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", c.Name, c.Arch, cell(c.From), cell(c.To))
+		}
+	}
+}
+
+// orNone returns s, or "(none)" if empty.
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	flags := diffCmd.Flags()
+	flags.StringSliceVar(&diffArchs, "arch", nil, "only compare the packages of the architectures, and of all (default every architecture)")
+	flags.StringVar(&diffStore, "store", "snapshots", "directory of the snapshot store of snapshot:<name> states")
+	flags.StringVarP(&diffOutput, "output", "o", "text", "output format: text, json or markdown")
+}
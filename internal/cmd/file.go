@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var fileCmd = &cobra.Command{
+	Use:   "file",
+	Short: "Find the packages shipping a file, like apt-file",
+}
+
+func init() {
+	RootCmd.AddCommand(fileCmd)
+}
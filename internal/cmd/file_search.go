@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/contents"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fileSearchRegexp bool
+	fileSearchOutput string
+)
+
+var fileSearchCmd = &cobra.Command{
+	Use:   "search <path-or-glob>",
+	Short: "Search the packages shipping files matching a path, a glob or a regex",
+	Long: `Search the packages shipping files matching a pattern in the cached Contents
+indices. A pattern with glob characters matches the whole path if absolute, or
+the file name otherwise. An absolute path matches exactly, any other pattern
+matches a part of the path.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		match, err := contents.Matcher(args[0], fileSearchRegexp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		matches, err := c.SearchFiles(match)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch fileSearchOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(matches)
+		case "text":
+			for _, m := range matches {
+				fmt.Printf("%s: %s\n", strings.Join(m.Packages, ", "), m.Path)
+			}
+		default:
+			err = fmt.Errorf("unknown output format %q", fileSearchOutput)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	fileCmd.AddCommand(fileSearchCmd)
+
+	flags := fileSearchCmd.Flags()
+	flags.BoolVarP(&fileSearchRegexp, "regexp", "x", false, "the pattern is a regular expression")
+	flags.StringVarP(&fileSearchOutput, "output", "o", "text", "output format: text or json")
+}
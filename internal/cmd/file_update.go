@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var fileUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the Contents indices of the sources, like apt-file update",
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		start := time.Now()
+		fetches, err := c.UpdateContents(context.Background())
+		printFetches(fetches, start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	fileCmd.AddCommand(fileUpdateCmd)
+}
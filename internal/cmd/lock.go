@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/anfernee/goapt/pkg/lock"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockfilePath string
+	lockCacheDir string
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [manifest]",
+	Short: "Pin the packages of a manifest and their dependencies into a lockfile",
+	Long: `Resolve the packages of a manifest, goapt.json by default, and their
+dependencies, and pin every package to its exact version, file, size and
+SHA256, along with the SHA256 of the InRelease file it came from, into a
+lockfile. A manifest lists the sources, the architectures and the packages:
+
+  {
+    "sources": ["deb http://archive.ubuntu.com/ubuntu focal main"],
+    "architectures": ["amd64"],
+    "packages": ["bash", "ca-certificates"],
+    "keyrings": ["ubuntu-keyring.gpg"]
+  }
+
+The sources are verified against the trusted keys of apt and the keyrings of
+the manifest. The packages are fetched hermetically from the URLs of the
+lockfile, checking their SHA256.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "goapt.json"
+		if len(args) > 0 {
+			path = args[0]
+		}
+		m, err := lock.ReadManifest(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		c, err := newLockClient(m, filepath.Dir(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+		l, err := c.Lock(m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := l.Write(lockfilePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var size int64
+		for _, p := range l.Packages {
+			size += int64(p.Size)
+		}
+		fmt.Printf("Locked %d packages, %s, into %s\n", len(l.Packages), sizeToString(size), lockfilePath)
+	},
+}
+
+// newLockClient returns a client of the sources and architectures of the
+// manifest m, with the indices updated. Relative keyrings of m are relative
+// to dir.
+func newLockClient(m *lock.Manifest, dir string) (*goapt.Client, error) {
+	root := lockCacheDir
+	if root == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		root = filepath.Join(cache, "goapt", "lock")
+	}
+	sourceList := filepath.Join(root, "etc/apt/sources.list")
+	if err := os.MkdirAll(filepath.Dir(sourceList), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(sourceList, []byte(strings.Join(m.Sources, "\n")+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	keyRing, err := release.LoadKeyRing(aptConfig.TrustedPath(), aptConfig.TrustedDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range m.KeyRings {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		kr, err := release.LoadKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keyring %s: %w", path, err)
+		}
+		for _, key := range kr.GetKeys() {
+			keyRing.AddKey(key)
+		}
+	}
+
+	archs := m.Architectures
+	if len(archs) == 0 {
+		archs = architectures()
+	}
+	c, err := goapt.New(&goapt.Options{
+		Root:          root,
+		Architectures: archs,
+		Languages:     []string{},
+		Fetcher:       goapt.NewFetcher(aptConfig),
+		KeyRing:       keyRing,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	fetches, err := c.Update(context.Background())
+	printFetches(fetches, start)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func init() {
+	RootCmd.AddCommand(lockCmd)
+
+	flags := lockCmd.PersistentFlags()
+	flags.StringVarP(&lockfilePath, "lockfile", "l", "goapt.lock", "path to the lockfile")
+	flags.StringVar(&lockCacheDir, "cache-dir", "", "directory caching the indices between runs (default <user cache dir>/goapt/lock)")
+}
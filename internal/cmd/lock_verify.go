@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anfernee/goapt/pkg/lock"
+	"github.com/spf13/cobra"
+)
+
+var lockVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the lockfile is still resolvable and consistent",
+	Long: `Check the lockfile against the current indices of its sources: every
+locked package must still be published with the same file, size and SHA256,
+every package of the manifest must be locked, and the locked packages must
+satisfy the dependencies of each other, without extra packages. The
+InRelease files may have changed since locking.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		l, err := lock.Read(lockfilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		c, err := newLockClient(&l.Manifest, filepath.Dir(lockfilePath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+		problems, err := c.VerifyLock(l)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "E: %s\n", problem)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("%s is up to date: %d packages\n", lockfilePath, len(l.Packages))
+	},
+}
+
+func init() {
+	lockCmd.AddCommand(lockVerifyCmd)
+}
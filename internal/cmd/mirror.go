@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/spf13/cobra"
+)
+
+// filterFlags are the flags selecting what a command mirrors.
+type filterFlags struct {
+	name   string
+	filter goapt.MirrorFilter
+}
+
+// add adds the flags to cmd.
+func (f *filterFlags) add(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringSliceVar(&f.filter.Components, "component", nil, "only mirror the components (default every component of the sources)")
+	flags.StringVar(&f.name, "name", "", "only mirror the packages whose name matches the regex")
+	flags.StringSliceVar(&f.filter.Priorities, "priority", nil, "only mirror the packages of the priorities, e.g. required,important")
+	flags.StringSliceVar(&f.filter.Roots, "package", nil, "mirror the packages, name[:arch][=version], and their dependencies")
+}
+
+// mirrorFilter returns the filter of the flags.
+func (f *filterFlags) mirrorFilter() (*goapt.MirrorFilter, error) {
+	filter := f.filter
+	if f.name != "" {
+		re, err := regexp.Compile(f.name)
+		if err != nil {
+			return nil, err
+		}
+		filter.Names = re
+	}
+	return &filter, nil
+}
+
+var mirrorFlags filterFlags
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <dir>",
+	Short: "Mirror the sources, or a filtered part of them, into a local directory",
+	Long: `Mirror the sources into a local directory with the upstream layout, e.g.
+<dir>/archive.ubuntu.com/ubuntu/dists/focal/InRelease, verifying every file
+against the signed InRelease files. Only the indices of the configured
+architectures are mirrored.
+
+The packages mirrored are the ones matching --name and --priority, or with
+--package, the given packages and the packages matching --name and --priority
+if set, with their dependencies.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		filter, err := mirrorFlags.mirrorFilter()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		start := time.Now()
+		fetches, err := c.Mirror(context.Background(), args[0], filter)
+		printFetches(fetches, start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mirrorCmd)
+	mirrorFlags.add(mirrorCmd)
+}
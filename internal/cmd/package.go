@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packageArch   string
+	packageSource string
+	packageOutput string
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "apt package related commands",
+}
+
+// printStanzas prints the stanzas of pkgs in the text or json format.
+func printStanzas(pkgs []pkg.Package, output string) error {
+	switch output {
+	case "json":
+		stanzas := []map[string]string{}
+		for _, p := range pkgs {
+			stanzas = append(stanzas, p.Control.Values)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stanzas)
+	case "text":
+		for _, p := range pkgs {
+			fmt.Println(p.Control.String())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(packageCmd)
+
+	flags := packageCmd.PersistentFlags()
+	flags.StringVar(&packageArch, "arch", "", "only packages of the architecture")
+	flags.StringVar(&packageSource, "source", "", "only packages built from the source package")
+	flags.StringVarP(&packageOutput, "output", "o", "text", "output format: text or json")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/why"
+	"github.com/spf13/cobra"
+)
+
+var rdependsInstalled bool
+
+// rdepend is a reverse dependency in the json output.
+type rdepend struct {
+	Name     string `json:"name"`
+	Arch     string `json:"arch"`
+	Version  string `json:"version"`
+	Field    string `json:"field"`
+	Relation string `json:"relation"`
+}
+
+var packageRDependsCmd = &cobra.Command{
+	Use:   "rdepends <name>[:arch]",
+	Short: "Show the packages depending on a package",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		var (
+			pkgs []pkg.Package
+			err  error
+		)
+		if rdependsInstalled {
+			pkgs, err = installedPackages()
+		} else {
+			pkgs, err = cachedPackages()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		g := why.NewGraph(pkgs, architectures().Native())
+		targets := g.Get(args[0])
+		if len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: package %s is not found\n", args[0])
+			os.Exit(1)
+		}
+
+		filter := &goapt.Filter{Arch: packageArch, Source: packageSource}
+		rdepends := []rdepend{}
+		seen := map[rdepend]bool{}
+		for _, target := range targets {
+			for _, e := range g.RDepends(target) {
+				if !filter.Match(e.From) {
+					continue
+				}
+				r := rdepend{
+					Name:     e.From.Name,
+					Arch:     e.From.Arch,
+					Version:  e.From.Version,
+					Field:    e.Field,
+					Relation: e.Relation.String(),
+				}
+				if !seen[r] {
+					seen[r] = true
+					rdepends = append(rdepends, r)
+				}
+			}
+		}
+
+		switch packageOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(rdepends)
+		case "text":
+			fmt.Println(args[0])
+			fmt.Println("Reverse Depends:")
+			for _, r := range rdepends {
+				fmt.Printf("  %s:%s (%s) %s %s\n", r.Name, r.Arch, r.Version, r.Field, r.Relation)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format %q\n", packageOutput)
+			os.Exit(1)
+		}
+	},
+}
+
+// cachedPackages returns the packages of the cached indices.
+func cachedPackages() ([]pkg.Package, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Packages()
+}
+
+func init() {
+	packageCmd.AddCommand(packageRDependsCmd)
+
+	packageRDependsCmd.Flags().BoolVar(&rdependsInstalled, "installed", false, "query the installed packages instead of the cached indices")
+}
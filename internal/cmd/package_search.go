@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/spf13/cobra"
+)
+
+var searchFull bool
+
+var packageSearchCmd = &cobra.Command{
+	Use:   "search <regex>",
+	Short: "Search the cached indices for packages whose name or description matches",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		// Searches are case insensitive like apt-cache search
+		re, err := regexp.Compile("(?i)" + args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		pkgs, err := c.Search(re, &goapt.Filter{Arch: packageArch, Source: packageSource})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if searchFull || packageOutput != "text" {
+			if err := printStanzas(pkgs, packageOutput); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		seen := map[string]bool{}
+		for _, p := range pkgs {
+			if seen[p.Name] {
+				continue
+			}
+			seen[p.Name] = true
+			fmt.Printf("%s - %s\n", p.Name, p.Summary())
+		}
+	},
+}
+
+func init() {
+	packageCmd.AddCommand(packageSearchCmd)
+
+	packageSearchCmd.Flags().BoolVar(&searchFull, "full", false, "print the full stanzas of the matching packages")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/spf13/cobra"
+)
+
+var packageShowCmd = &cobra.Command{
+	Use:   "show <name>[=version]",
+	Short: "Show the stanzas of a package from the cached indices",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		name, ver, _ := strings.Cut(args[0], "=")
+		pkgs, err := c.Show(name, ver, &goapt.Filter{Arch: packageArch, Source: packageSource})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(pkgs) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: package %s is not found, try goapt update\n", args[0])
+			os.Exit(1)
+		}
+
+		if err := printStanzas(pkgs, packageOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	packageCmd.AddCommand(packageShowCmd)
+}
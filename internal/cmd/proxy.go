@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/anfernee/goapt/pkg/proxy"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyListen string
+	proxyMaxAge time.Duration
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <cache-dir>",
+	Short: "Run a caching proxy of apt repositories",
+	Long: `Run a caching proxy of apt repositories, caching the files under a directory.
+apt clients use it either as their proxy:
+
+  Acquire::http::Proxy "http://localhost:3142/";
+
+or as the mirror of their sources, with the upstream host in the path:
+
+  deb http://localhost:3142/archive.ubuntu.com/ubuntu focal main
+
+Pool files are cached permanently, and indices are revalidated upstream. The
+InRelease and Release.gpg files are verified against the trusted keys before
+they are cached. Only http upstreams are supported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		keyRing, err := release.LoadKeyRing(aptConfig.TrustedPath(), aptConfig.TrustedDir())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(args[0], 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		l, err := net.Listen("tcp", proxyListen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Proxying on http://%s/, caching under %s\n", l.Addr(), args[0])
+		p := proxy.New(args[0], &proxy.Options{
+			Client:  goapt.NewFetcher(aptConfig).Client,
+			KeyRing: keyRing,
+			MaxAge:  proxyMaxAge,
+			Logger:  log.New(os.Stderr, "", log.LstdFlags),
+		})
+		if err := http.Serve(l, p); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(proxyCmd)
+
+	flags := proxyCmd.Flags()
+	flags.StringVar(&proxyListen, "listen", "localhost:3142", "address to listen on")
+	flags.DurationVar(&proxyMaxAge, "max-age", time.Minute, "how long a cached index is served without revalidating it upstream")
+}
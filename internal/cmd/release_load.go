@@ -2,15 +2,49 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/anfernee/goapt/pkg/release"
 	"github.com/spf13/cobra"
 )
 
 var releaseLoadCmd = &cobra.Command{
-	Use:   "load",
+	Use:   "load <release-path>",
 	Short: "Load apt release file",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("TODO: load")
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		r, err := release.LoadWith(goapt.NewFetcher(aptConfig), args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Origin: %s\n", r.Origin)
+		fmt.Printf("Label: %s\n", r.Label)
+		fmt.Printf("Suite: %s\n", r.Suite)
+		fmt.Printf("Version: %s\n", r.Version)
+		fmt.Printf("Codename: %s\n", r.Codename)
+		fmt.Printf("Date: %s\n", r.Date)
+		fmt.Printf("Architectures: %s\n", strings.Join(r.Archs, " "))
+		fmt.Printf("Components: %s\n", strings.Join(r.Components, " "))
+
+		var names []string
+		for name := range r.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("Files:")
+		for _, name := range names {
+			fmt.Printf(" %10d %s\n", r.Files[name].Size, name)
+		}
 	},
 }
 
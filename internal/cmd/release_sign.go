@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/spf13/cobra"
+)
+
+// signingFlags are the flags selecting the private key of a command
+// signing releases.
+type signingFlags struct {
+	keyPath        string
+	passphraseEnv  string
+	passphraseFile string
+}
+
+// add adds the flags to cmd.
+func (f *signingFlags) add(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&f.keyPath, "key", "", "path to the armored or binary private key signing the release")
+	flags.StringVar(&f.passphraseEnv, "passphrase-env", "", "environment variable holding the passphrase of the key")
+	flags.StringVar(&f.passphraseFile, "passphrase-file", "", "file holding the passphrase of the key")
+}
+
+// keyRing loads the private key, or returns nil if --key is not set.
+func (f *signingFlags) keyRing() (*crypto.KeyRing, error) {
+	if f.keyPath == "" {
+		return nil, nil
+	}
+
+	var passphrase []byte
+	switch {
+	case f.passphraseEnv != "" && f.passphraseFile != "":
+		return nil, fmt.Errorf("--passphrase-env and --passphrase-file are exclusive")
+	case f.passphraseEnv != "":
+		value, ok := os.LookupEnv(f.passphraseEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", f.passphraseEnv)
+		}
+		passphrase = []byte(value)
+	case f.passphraseFile != "":
+		d, err := os.ReadFile(f.passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		// Like gpg --passphrase-file, only the first line is the passphrase
+		passphrase = bytes.TrimRight(bytes.SplitN(d, []byte("\n"), 2)[0], "\r")
+	}
+	return release.LoadSigningKey(f.keyPath, passphrase)
+}
+
+var releaseSignFlags signingFlags
+
+var releaseSignCmd = &cobra.Command{
+	Use:   "sign <release-path>",
+	Short: "Sign apt release file into InRelease and Release.gpg next to it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || releaseSignFlags.keyPath == "" {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		keyRing, err := releaseSignFlags.keyRing()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inRelease, releaseGPG, err := release.Sign(content, keyRing)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		dir := filepath.Dir(args[0])
+		for name, data := range map[string][]byte{"InRelease": inRelease, "Release.gpg": releaseGPG} {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	releaseCmd.AddCommand(releaseSignCmd)
+
+	releaseSignFlags.add(releaseSignCmd)
+}
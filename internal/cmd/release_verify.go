@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/anfernee/goapt/pkg/goapt"
 	"github.com/anfernee/goapt/pkg/release"
 	"github.com/spf13/cobra"
 )
@@ -24,7 +25,11 @@ var releaseVerifyCmd = &cobra.Command{
 		}
 
 		path := args[0]
-		options := &release.VerifyOptions{}
+		options := &release.VerifyOptions{
+			TrustedPath: aptConfig.TrustedPath(),
+			TrustedDir:  aptConfig.TrustedDir(),
+			Fetcher:     goapt.NewFetcher(aptConfig),
+		}
 		if pubkeyPath == "" {
 			options.AutoDiscover = true
 		} else {
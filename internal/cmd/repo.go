@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var repoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Publish apt repositories",
+}
+
+func init() {
+	RootCmd.AddCommand(repoCmd)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/repo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	repoDir     string
+	repoDateArg string
+	repoOptions repo.Options
+	repoSigning signingFlags
+)
+
+var repoBuildCmd = &cobra.Command{
+	Use:   "build <pool-dir>",
+	Short: "Generate the Packages indices and the Release file of a directory of .deb files",
+	Long: `Generate the Packages indices and the Release file of a directory of .deb files.
+
+The indices and the Release file are written under <repo>/dists/<suite>. The
+component of a package is its first directory under the pool, e.g.
+pool/main/h/hello, unless --component is set. The output is deterministic: the
+Release file has no Date unless --date or SOURCE_DATE_EPOCH is set. With --key,
+the release is also signed into InRelease and Release.gpg.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		pool := args[0]
+		dir := repoDir
+		if dir == "" {
+			dir = filepath.Dir(filepath.Clean(pool))
+		}
+
+		options := repoOptions
+		date, err := repoDate(repoDateArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		options.Date = date
+		if options.KeyRing, err = repoSigning.keyRing(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		r, err := repo.Build(dir, pool, &options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var names []string
+		for name := range r.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(filepath.Join(dir, "dists", r.Suite, name))
+		}
+		fmt.Println(filepath.Join(dir, "dists", r.Suite, "Release"))
+		if options.KeyRing != nil {
+			fmt.Println(filepath.Join(dir, "dists", r.Suite, "InRelease"))
+			fmt.Println(filepath.Join(dir, "dists", r.Suite, "Release.gpg"))
+		}
+	},
+}
+
+// repoDate returns the date of a release, from an RFC 3339 date or else
+// SOURCE_DATE_EPOCH, or zero if neither is set.
+func repoDate(value string) (time.Time, error) {
+	if value != "" {
+		return time.Parse(time.RFC3339, value)
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %w", err)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Time{}, nil
+}
+
+func init() {
+	repoCmd.AddCommand(repoBuildCmd)
+
+	flags := repoBuildCmd.Flags()
+	flags.StringVar(&repoDir, "repo", "", "root directory of the repository, package file names are relative to it (default the parent of the pool directory)")
+	flags.StringVar(&repoOptions.Suite, "suite", "stable", "suite of the release")
+	flags.StringVar(&repoOptions.Codename, "codename", "", "codename of the release (default the suite)")
+	flags.StringVar(&repoOptions.Origin, "origin", "", "origin of the release")
+	flags.StringVar(&repoOptions.Label, "label", "", "label of the release")
+	flags.StringVar(&repoOptions.Version, "release-version", "", "version of the release")
+	flags.StringVar(&repoOptions.Description, "description", "", "description of the release")
+	flags.StringVar(&repoOptions.Component, "component", "", "component of every package (default the first directory under the pool)")
+	flags.StringSliceVar(&repoOptions.Architectures, "arch", nil, "architectures of the release (default the architectures of the packages)")
+	flags.StringVar(&repoDateArg, "date", "", "date of the release in RFC 3339 (default SOURCE_DATE_EPOCH, or no date)")
+
+	repoSigning.add(repoBuildCmd)
+}
@@ -4,4 +4,19 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var RootCmd = &cobra.Command{}
+var (
+	configPath string
+	rootDir    string
+)
+
+var RootCmd = &cobra.Command{
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(rootDir, configPath)
+	},
+}
+
+func init() {
+	flags := RootCmd.PersistentFlags()
+	flags.StringVarP(&configPath, "config", "c", "", "path to apt.conf, also reads the files under <path>.d (default <root>/etc/apt/apt.conf)")
+	flags.StringVar(&rootDir, "root", "", "root directory of the target system, all apt and dpkg paths are resolved under it")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/anfernee/goapt/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen  string
+	serveOptions server.Options
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve <dir>",
+	Short: "Serve a published or mirrored repository over HTTP",
+	Long: `Serve a published or mirrored repository over HTTP, with the content types
+of apt repositories and Range requests. The by-hash paths of the files, e.g.
+dists/focal/main/binary-amd64/by-hash/SHA256/<hash>, are served unless
+published, and with --compress, the missing compressed variants of the files,
+e.g. Packages.xz, are generated on the fly.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", args[0])
+			os.Exit(1)
+		}
+
+		l, err := net.Listen("tcp", serveListen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Serving %s on http://%s/\n", args[0], l.Addr())
+		if err := http.Serve(l, server.New(args[0], &serveOptions)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+
+	flags := serveCmd.Flags()
+	flags.StringVar(&serveListen, "listen", "localhost:8080", "address to listen on")
+	flags.BoolVar(&serveOptions.Compress, "compress", false, "generate the missing compressed variants of the files on the fly")
+	flags.Int64Var(&serveOptions.CacheSize, "cache-size", 0, "bytes of generated content kept in memory (default 64 MiB)")
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var snapshotStore string
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Take, inspect and publish immutable snapshots of the sources",
+	Long: `Take immutable point-in-time snapshots of the sources. The InRelease files,
+the indices and the pool files of a snapshot are stored content-addressed by
+SHA256 in the store, so files unchanged between snapshots are stored once.`,
+}
+
+func init() {
+	RootCmd.AddCommand(snapshotCmd)
+
+	flags := snapshotCmd.PersistentFlags()
+	flags.StringVar(&snapshotStore, "store", "snapshots", "directory of the snapshot store")
+}
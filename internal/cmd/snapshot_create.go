@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCreateFlags filterFlags
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Mirror the sources, or a filtered part of them, into a new snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		filter, err := snapshotCreateFlags.mirrorFilter()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		store, err := snapshot.Open(snapshotStore)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		start := time.Now()
+		snap, fetches, err := c.Snapshot(context.Background(), store, args[0], filter)
+		printFetches(fetches, start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created snapshot %s: %d files, %s\n", snap.Name, len(snap.Files), sizeToString(snap.Size()))
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCreateFlags.add(snapshotCreateCmd)
+}
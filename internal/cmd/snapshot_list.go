@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots of the store, oldest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := snapshot.Open(snapshotStore)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		snaps, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, snap := range snaps {
+			fmt.Printf("%s %s %d files %s\n", snap.Name, snap.Created.Format(time.RFC3339), len(snap.Files), sizeToString(snap.Size()))
+		}
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotListCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotPublishCmd = &cobra.Command{
+	Use:   "publish <name> <dir>",
+	Short: "Publish a snapshot into a directory as a static repository",
+	Long: `Publish the files of a snapshot into a directory, with the layout of the
+mirror it was taken from, e.g. <dir>/archive.ubuntu.com/ubuntu/dists/focal/InRelease.
+Serve the directory with any static web server and point the sources to it,
+e.g. "deb http://server/archive.ubuntu.com/ubuntu focal main". The files are
+signed by the upstream keys.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		store, err := snapshot.Open(snapshotStore)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		snap, err := store.Publish(args[0], args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Published snapshot %s to %s: %d files, %s\n", snap.Name, args[1], len(snap.Files), sizeToString(snap.Size()))
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotPublishCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotShowOutput string
+
+var snapshotShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show the sources and the files of a snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		store, err := snapshot.Open(snapshotStore)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		snap, err := store.Get(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch snapshotShowOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(snap); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case "text":
+			fmt.Printf("Name: %s\n", snap.Name)
+			fmt.Printf("Created: %s\n", snap.Created.Format(time.RFC3339))
+			fmt.Printf("Architectures: %s\n", strings.Join(snap.Architectures, " "))
+			fmt.Println("Sources:")
+			for _, source := range snap.Sources {
+				fmt.Printf(" %s\n", source)
+			}
+			fmt.Println("Files:")
+			for _, f := range snap.Files {
+				fmt.Printf(" %s %10d %s\n", f.SHA256, f.Size, f.Path)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q\n", snapshotShowOutput)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotShowCmd)
+
+	flags := snapshotShowCmd.Flags()
+	flags.StringVarP(&snapshotShowOutput, "output", "o", "text", "output format: text or json")
+}
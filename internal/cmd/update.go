@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/goapt"
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the package indices of the sources, like apt-get update",
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		start := time.Now()
+		fetches, err := c.Update(context.Background())
+		printFetches(fetches, start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "E: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// printFetches prints the fetches of an update started at start like apt,
+// e.g. "Get:1 http://archive.ubuntu.com/ubuntu focal InRelease [265 kB]".
+func printFetches(fetches []goapt.Fetch, start time.Time) {
+	var fetched int64
+	for i, f := range fetches {
+		line := fmt.Sprintf("%s:%d %s", f.Status, i+1, f.Description)
+		if f.Status == goapt.FetchGet {
+			line += fmt.Sprintf(" [%s]", sizeToString(f.Size))
+		}
+		fmt.Println(line)
+		if f.Err != nil {
+			fmt.Printf("  %v\n", f.Err)
+		}
+		fetched += f.Size
+	}
+
+	elapsed := time.Since(start)
+	seconds := int(elapsed.Round(time.Second) / time.Second)
+	if fetched > 0 {
+		rate := float64(fetched) / elapsed.Seconds()
+		fmt.Printf("Fetched %s in %ds (%s/s)\n", sizeToString(fetched), seconds, sizeToString(int64(rate)))
+	}
+}
+
+// sizeToString formats a number of bytes like apt, e.g. "8,628 B",
+// "1,275 kB" or "11.3 MB".
+func sizeToString(n int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	v, unit := float64(n), 0
+	for v >= 10000 && unit < len(units)-1 {
+		v /= 1000
+		unit++
+	}
+	if v >= 100 || unit == 0 {
+		return groupDigits(int64(v+0.5)) + " " + units[unit]
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + units[unit]
+}
+
+// groupDigits formats n with comma separated thousands.
+func groupDigits(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func init() {
+	RootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "apt upgrade related commands",
+}
+
+func init() {
+	RootCmd.AddCommand(upgradeCmd)
+}
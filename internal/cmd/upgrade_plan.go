@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/anfernee/goapt/pkg/dpkg"
+	"github.com/anfernee/goapt/pkg/goapt"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/upgrade"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeIndices []string
+	upgradeOutput  string
+)
+
+var upgradePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the upgrade plan of a root filesystem",
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, err := planUpgrade(upgradeIndices)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch upgradeOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(plan)
+		case "text":
+			printChanges("upgraded", plan.Upgrades)
+			printChanges("newly installed", plan.New)
+			printChanges("removed", plan.Removals)
+			printChanges("kept back", plan.Held)
+			fmt.Printf("%d upgraded, %d newly installed, %d to remove and %d not upgraded.\n",
+				len(plan.Upgrades), len(plan.New), len(plan.Removals), len(plan.Held))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format %q\n", upgradeOutput)
+			os.Exit(1)
+		}
+	},
+}
+
+// planUpgrade loads the dpkg status database and computes its upgrade plan
+// against indices, or the cached indices of the source list if empty, with
+// the pins of the apt preferences.
+func planUpgrade(indices []string) (*upgrade.Plan, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return c.PlanUpgrade()
+	}
+
+	db, err := dpkg.Load(dpkg.Find(aptConfig.StatusPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	var available []pkg.Package
+	for _, index := range indices {
+		pkgs, err := pkg.LoadWith(goapt.NewFetcher(aptConfig), index)
+		if err != nil {
+			return nil, err
+		}
+		available = append(available, pkgs...)
+	}
+
+	return upgrade.Compute(db.Packages(), available, c.Architectures(), &upgrade.Options{Preferences: c.Preferences()}), nil
+}
+
+func printChanges(title string, changes []upgrade.Change) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Printf("The following packages will be %s:\n", title)
+	for _, c := range changes {
+		switch {
+		case c.From != "" && c.To != "":
+			fmt.Printf("  %s:%s (%s => %s)\n", c.Name, c.Arch, c.From, c.To)
+		case c.To != "":
+			fmt.Printf("  %s:%s (%s) %s\n", c.Name, c.Arch, c.To, c.Reason)
+		default:
+			fmt.Printf("  %s:%s (%s) %s\n", c.Name, c.Arch, c.From, c.Reason)
+		}
+	}
+}
+
+func init() {
+	upgradeCmd.AddCommand(upgradePlanCmd)
+
+	flags := upgradePlanCmd.Flags()
+	flags.StringArrayVarP(&upgradeIndices, "index", "i", nil, "path or url of a Packages index, defaults to the cached indices of the root's sources")
+	flags.StringVarP(&upgradeOutput, "output", "o", "text", "output format: text or json")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/dpkg"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/why"
+	"github.com/spf13/cobra"
+)
+
+var whyCmd = &cobra.Command{
+	Use:   "why <name>[:arch]",
+	Short: "Explain which manually installed package pulls in an installed package",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		g, roots, err := installedGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		targets := g.Get(args[0])
+		if len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: package %s is not installed\n", args[0])
+			os.Exit(1)
+		}
+
+		for _, target := range targets {
+			printWhy(g, target, roots, "")
+		}
+	},
+}
+
+var whyNotCmd = &cobra.Command{
+	Use:   "why-not <name>[:arch]",
+	Short: "Explain which installed packages prevent a package from being installed",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Fprintf(os.Stderr, "Missing arguments\n")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		g, roots, err := installedGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		name, arch, _ := strings.Cut(args[0], ":")
+		// The candidate apt would install, with the pins of the preferences
+		candidate, err := c.Candidate(name, arch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if candidate == nil {
+			fmt.Fprintf(os.Stderr, "Error: package %s has no installation candidate, try goapt update\n", args[0])
+			os.Exit(1)
+		}
+
+		conflicts := g.WhyNot(candidate)
+		if len(conflicts) == 0 {
+			fmt.Printf("Nothing installed prevents %s:%s (%s) from being installed\n", candidate.Name, candidate.Arch, candidate.Version)
+			return
+		}
+		for _, conflict := range conflicts {
+			fmt.Println(conflict.String())
+			printWhy(g, conflict.Installed, roots, "  ")
+		}
+	},
+}
+
+// printWhy prints the dependency chain pulling in p.
+func printWhy(g *why.Graph, p *pkg.Package, roots []*pkg.Package, indent string) {
+	chain := g.Why(p, roots)
+	switch {
+	case chain == nil:
+		fmt.Printf("%sNo manually installed package depends on %s:%s\n", indent, p.Name, p.Arch)
+	case len(chain) == 0:
+		fmt.Printf("%s%s:%s is manually installed\n", indent, p.Name, p.Arch)
+	default:
+		for _, e := range chain {
+			fmt.Printf("%s%s\n", indent, e.String())
+		}
+	}
+}
+
+// installedGraph returns the dependency graph of the installed packages and
+// its roots, the packages not marked as automatically installed.
+func installedGraph() (*why.Graph, []*pkg.Package, error) {
+	pkgs, err := installedPackages()
+	if err != nil {
+		return nil, nil, err
+	}
+	auto, err := why.LoadAutoInstalled(aptConfig.ExtendedStatesPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g := why.NewGraph(pkgs, architectures().Native())
+	return g, g.Roots(auto), nil
+}
+
+// installedPackages returns the installed packages of the dpkg status
+// database.
+func installedPackages() ([]pkg.Package, error) {
+	db, err := dpkg.Load(dpkg.Find(aptConfig.StatusPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []pkg.Package
+	for _, e := range db.Installed() {
+		ret = append(ret, e.Package)
+	}
+	return ret, nil
+}
+
+func init() {
+	RootCmd.AddCommand(whyCmd)
+	RootCmd.AddCommand(whyNotCmd)
+}
@@ -0,0 +1,214 @@
+package aptconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultConfigPath = "/etc/apt/apt.conf"
+)
+
+// Config is an apt configuration tree. Keys are case insensitive and
+// separated by "::". Check apt.conf(5) for details.
+type Config struct {
+	values map[string]string
+	lists  map[string][]string
+}
+
+// New creates an empty configuration.
+func New() *Config {
+	return &Config{
+		values: map[string]string{},
+		lists:  map[string][]string{},
+	}
+}
+
+// Load loads /etc/apt/apt.conf and the files under /etc/apt/apt.conf.d.
+func Load() (*Config, error) {
+	return LoadFrom(defaultConfigPath)
+}
+
+// LoadFrom loads a configuration file at path and the files under path.d,
+// like apt does with apt.conf and apt.conf.d. Missing files are not an
+// error.
+func LoadFrom(path string) (*Config, error) {
+	c := New()
+	if err := c.loadDir(path + ".d"); err != nil {
+		return nil, err
+	}
+	if err := c.LoadFile(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadDir loads the files of a configuration directory in alphanumeric
+// order. Only files made of alphanumerics, "_", "-" and "." are read,
+// without extension or with the ".conf" extension, like apt does.
+func (c *Config) loadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !validFileName(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.LoadFile(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validFileName(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	if ext := filepath.Ext(name); ext != "" && ext != ".conf" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// LoadFile parses a single configuration file into c.
+func (c *Config) LoadFile(path string) error {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := c.Parse(string(d), filepath.Dir(path)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func normalize(key string) string {
+	return strings.ToLower(strings.Trim(key, ":"))
+}
+
+// Get returns the value of key, or an empty string.
+func (c *Config) Get(key string) string {
+	return c.values[normalize(key)]
+}
+
+// GetDefault returns the value of key, or def if it is not set.
+func (c *Config) GetDefault(key, def string) string {
+	if v, ok := c.values[normalize(key)]; ok {
+		return v
+	}
+	return def
+}
+
+// GetInt returns the integer value of key, or def if it is not set or
+// invalid.
+func (c *Config) GetInt(key string, def int) int {
+	v, err := strconv.Atoi(c.Get(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// GetBool returns the boolean value of key, or def if it is not set.
+func (c *Config) GetBool(key string, def bool) bool {
+	switch strings.ToLower(c.Get(key)) {
+	case "yes", "true", "with", "on", "enable", "1":
+		return true
+	case "no", "false", "without", "off", "disable", "0":
+		return false
+	}
+	return def
+}
+
+// GetList returns the list value of key. A scalar value is split by
+// commas.
+func (c *Config) GetList(key string) []string {
+	key = normalize(key)
+	if list, ok := c.lists[key]; ok {
+		return append([]string{}, list...)
+	}
+	var ret []string
+	for _, item := range strings.Split(c.values[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+// Set sets the value of key.
+func (c *Config) Set(key, value string) {
+	c.values[normalize(key)] = value
+}
+
+// Append appends a value to the list of key.
+func (c *Config) Append(key, value string) {
+	key = normalize(key)
+	c.lists[key] = append(c.lists[key], value)
+}
+
+// Clear removes key and all the keys under it.
+func (c *Config) Clear(key string) {
+	key = normalize(key)
+	for k := range c.values {
+		if k == key || strings.HasPrefix(k, key+"::") {
+			delete(c.values, k)
+		}
+	}
+	for k := range c.lists {
+		if k == key || strings.HasPrefix(k, key+"::") {
+			delete(c.lists, k)
+		}
+	}
+}
+
+// Clone returns a copy of the configuration, which can be changed without
+// changing c.
+func (c *Config) Clone() *Config {
+	ret := New()
+	for k, v := range c.values {
+		ret.values[k] = v
+	}
+	for k, v := range c.lists {
+		ret.lists[k] = append([]string{}, v...)
+	}
+	return ret
+}
+
+// Keys returns all the keys with a scalar or list value, sorted.
+func (c *Config) Keys() []string {
+	seen := map[string]bool{}
+	for k := range c.values {
+		seen[k] = true
+	}
+	for k := range c.lists {
+		seen[k] = true
+	}
+	var ret []string
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
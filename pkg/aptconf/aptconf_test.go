@@ -0,0 +1,149 @@
+package aptconf
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadFrom(t *testing.T) {
+	c, err := LoadFrom("testdata/apt.conf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := c.Retries(); got != 3 {
+		t.Errorf("expect 3 retries; got %d", got)
+	}
+	if expect, got := []string{"amd64", "i386", "arm64", "armhf"}, c.Architectures(); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if expect, got := []string{"fr"}, c.Languages(); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if got := c.Get("acquire::languages::old"); got != "" {
+		t.Errorf("expect cleared Acquire::Languages::Old; got %q", got)
+	}
+
+	paths := map[string]string{
+		c.SourceListPath():  "/srv/root/etc/apt/sources.list",
+		c.TrustedPath():     "/srv/root/etc/goapt/trusted.gpg",
+		c.TrustedDir():      "/srv/root/etc/apt/trusted.gpg.d",
+		c.PreferencesPath(): "/srv/root/etc/apt/preferences",
+		c.ListsDir():        "/srv/root/var/lib/apt/lists",
+	}
+	for got, expect := range paths {
+		if got != expect {
+			t.Errorf("expect %s; got %s", expect, got)
+		}
+	}
+
+	proxy := c.Proxy()
+	tests := map[string]string{
+		"http://archive.ubuntu.com/ubuntu":  "http://proxy.example.com:3128/",
+		"https://archive.ubuntu.com/ubuntu": "http://proxy.example.com:3128/",
+		"http://apt.example.com/debian":     "",
+	}
+	for target, expect := range tests {
+		u, _ := url.Parse(target)
+		got, err := proxy(&http.Request{URL: u})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", target, err)
+		}
+		if (got == nil && expect != "") || (got != nil && got.String() != expect) {
+			t.Errorf("%s: expect proxy %q; got %v", target, expect, got)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	c := New()
+	if got := c.SourceListPath(); got != "/etc/apt/sources.list" {
+		t.Errorf("expect /etc/apt/sources.list; got %s", got)
+	}
+	if got := c.Proxy(); got != nil {
+		t.Errorf("expect no proxy")
+	}
+	if expect, got := []string{"en"}, c.Languages(); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestSetRoot(t *testing.T) {
+	c := New()
+	c.SetRoot("/srv/image")
+
+	paths := map[string]string{
+		c.SourceListPath():     "/srv/image/etc/apt/sources.list",
+		c.SourceListDir():      "/srv/image/etc/apt/sources.list.d",
+		c.TrustedPath():        "/srv/image/etc/apt/trusted.gpg",
+		c.TrustedDir():         "/srv/image/etc/apt/trusted.gpg.d",
+		c.PreferencesPath():    "/srv/image/etc/apt/preferences",
+		c.PreferencesDir():     "/srv/image/etc/apt/preferences.d",
+		c.StatusPath():         "/srv/image/var/lib/dpkg/status",
+		c.ListsDir():           "/srv/image/var/lib/apt/lists",
+		c.ExtendedStatesPath(): "/srv/image/var/lib/apt/extended_states",
+	}
+	for got, expect := range paths {
+		if got != expect {
+			t.Errorf("expect %s; got %s", expect, got)
+		}
+	}
+
+	c.Set("Dir::State::status", "var/lib/dpkg/status.d")
+	if got := c.StatusPath(); got != "/srv/image/var/lib/apt/var/lib/dpkg/status.d" {
+		t.Errorf("expect status relative to Dir::State; got %s", got)
+	}
+
+	// Absolute paths are under the root too
+	c.Set("Dir::Etc::sourcelist", "/etc/apt/custom.list")
+	c.Set("Dir::Etc::preferencesparts", "/srv/prefs.d")
+	if got := c.SourceListPath(); got != "/srv/image/etc/apt/custom.list" {
+		t.Errorf("expect sources.list under the root; got %s", got)
+	}
+	if got := c.PreferencesDir(); got != "/srv/image/srv/prefs.d" {
+		t.Errorf("expect preferences.d under the root; got %s", got)
+	}
+	c.Set("Dir::Etc", "/opt/apt")
+	if got := c.TrustedPath(); got != "/srv/image/opt/apt/trusted.gpg" {
+		t.Errorf("expect Dir::Etc under the root; got %s", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text      string
+		key       string
+		expect    string
+		expectErr bool
+	}{
+		{text: `Foo::Bar "baz";`, key: "foo::bar", expect: "baz"},
+		{text: `Foo { Bar { Baz "qux"; }; };`, key: "Foo::Bar::Baz", expect: "qux"},
+		{text: `Foo "a"; Foo "b";`, key: "Foo", expect: "b"},
+		{text: `Foo bar;`, key: "Foo", expect: "bar"},
+		{text: "Foo \"x\" // comment\n;", key: "Foo", expect: "x"},
+		{text: `Foo "unterminated;`, expectErr: true},
+		{text: `Foo { Bar "x";`, expectErr: true},
+		{text: `}`, expectErr: true},
+		{text: `"value";`, expectErr: true},
+	}
+
+	for _, test := range tests {
+		c := New()
+		err := c.Parse(test.text, ".")
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%q: expect err; got nil", test.text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.text, err)
+		}
+		if got := c.Get(test.key); got != test.expect {
+			t.Errorf("%q: expect %q; got %q", test.text, test.expect, got)
+		}
+	}
+}
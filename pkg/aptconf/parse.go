@@ -0,0 +1,276 @@
+package aptconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth guards against #include loops.
+const maxIncludeDepth = 16
+
+type tokenType int
+
+const (
+	tokenWord tokenType = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenEnd
+	tokenDirective
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	line  int
+}
+
+// tokenize splits a configuration file into tokens, dropping comments.
+func tokenize(text string) ([]token, error) {
+	var (
+		ret       []token
+		line      = 1
+		lineStart = true
+	)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(text[i:], "//"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(text[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		case c == '#':
+			j := i
+			for j < len(text) && !strings.ContainsRune(" \t\r\n;", rune(text[j])) {
+				j++
+			}
+			word := text[i:j]
+			if lineStart && (word == "#include" || word == "#clear") {
+				ret = append(ret, token{typ: tokenDirective, value: word, line: line})
+				i = j
+			} else {
+				// Comment
+				for i < len(text) && text[i] != '\n' {
+					i++
+				}
+			}
+			lineStart = false
+			continue
+		}
+		lineStart = false
+
+		switch c {
+		case '{':
+			ret = append(ret, token{typ: tokenOpen, line: line})
+			i++
+		case '}':
+			ret = append(ret, token{typ: tokenClose, line: line})
+			i++
+		case ';':
+			ret = append(ret, token{typ: tokenEnd, line: line})
+			i++
+		case '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			value := text[i+1 : i+1+end]
+			line += strings.Count(value, "\n")
+			ret = append(ret, token{typ: tokenString, value: value, line: line})
+			i += end + 2
+		default:
+			j := i
+			for j < len(text) && !strings.ContainsRune(" \t\r\n;{}\"", rune(text[j])) {
+				j++
+			}
+			ret = append(ret, token{typ: tokenWord, value: text[i:j], line: line})
+			i = j
+		}
+	}
+	return ret, nil
+}
+
+// parser parses the tokens of one configuration file.
+type parser struct {
+	c      *Config
+	tokens []token
+	pos    int
+	dir    string
+	depth  int
+}
+
+// Parse parses configuration text into c. Relative #include paths are
+// resolved against dir.
+func (c *Config) Parse(text, dir string) error {
+	return c.parse(text, dir, 0)
+}
+
+func (c *Config) parse(text, dir string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("too many nested #include")
+	}
+	tokens, err := tokenize(text)
+	if err != nil {
+		return err
+	}
+	p := &parser{c: c, tokens: tokens, dir: dir, depth: depth}
+	if err := p.block(""); err != nil {
+		return err
+	}
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf("line %d: unexpected '}'", p.tokens[p.pos].line)
+	}
+	return nil
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+// expectEnd consumes a ";". A missing ";" before "}" or the end of file is
+// tolerated.
+func (p *parser) expectEnd() error {
+	t := p.peek()
+	switch {
+	case t == nil || t.typ == tokenClose:
+		return nil
+	case t.typ == tokenEnd:
+		p.pos++
+		return nil
+	}
+	return fmt.Errorf("line %d: expect ';'; got %q", t.line, t.value)
+}
+
+func join(prefix, key string) string {
+	key = strings.TrimPrefix(key, "::")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "::" + key
+}
+
+// block parses statements until the closing "}" of the block or the end of
+// file.
+func (p *parser) block(prefix string) error {
+	for {
+		t := p.peek()
+		if t == nil || t.typ == tokenClose {
+			return nil
+		}
+		p.pos++
+
+		switch t.typ {
+		case tokenEnd:
+			// Empty statement
+		case tokenDirective:
+			arg := p.next()
+			if arg == nil || (arg.typ != tokenWord && arg.typ != tokenString) {
+				return fmt.Errorf("line %d: missing argument of %s", t.line, t.value)
+			}
+			if err := p.directive(t.value, arg.value); err != nil {
+				return fmt.Errorf("line %d: %w", t.line, err)
+			}
+			if err := p.expectEnd(); err != nil {
+				return err
+			}
+		case tokenString:
+			// List item of the enclosing block
+			if prefix == "" {
+				return fmt.Errorf("line %d: value %q without a key", t.line, t.value)
+			}
+			p.c.Append(prefix, t.value)
+			if err := p.expectEnd(); err != nil {
+				return err
+			}
+		case tokenWord:
+			if err := p.statement(prefix, t); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("line %d: unexpected '{'", t.line)
+		}
+	}
+}
+
+// statement parses "key value;", "key:: value;" or "key { ... };".
+func (p *parser) statement(prefix string, key *token) error {
+	name := join(prefix, key.value)
+
+	t := p.next()
+	if t == nil {
+		return fmt.Errorf("line %d: missing value of %s", key.line, key.value)
+	}
+	switch t.typ {
+	case tokenOpen:
+		if err := p.block(name); err != nil {
+			return err
+		}
+		if c := p.next(); c == nil || c.typ != tokenClose {
+			return fmt.Errorf("line %d: missing '}' of %s", key.line, key.value)
+		}
+		return p.expectEnd()
+	case tokenString, tokenWord:
+		if strings.HasSuffix(key.value, "::") {
+			p.c.Append(name, t.value)
+		} else {
+			p.c.Set(name, t.value)
+		}
+		return p.expectEnd()
+	case tokenEnd:
+		p.c.Set(name, "")
+		return nil
+	}
+	return fmt.Errorf("line %d: unexpected token after %s", t.line, key.value)
+}
+
+func (p *parser) directive(name, arg string) error {
+	switch name {
+	case "#clear":
+		p.c.Clear(arg)
+		return nil
+	case "#include":
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.dir, path)
+		}
+		if strings.HasSuffix(arg, "/") {
+			return p.c.loadDir(path)
+		}
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return p.c.parse(string(d), filepath.Dir(path), p.depth+1)
+	}
+	return fmt.Errorf("unknown directive %s", name)
+}
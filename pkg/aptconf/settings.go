@@ -0,0 +1,171 @@
+package aptconf
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SetRoot sets the root directory, "Dir" in apt.conf, that every relative
+// path setting is resolved against, like "apt -o Dir=<root>".
+func (c *Config) SetRoot(root string) {
+	c.Set("Dir", root)
+}
+
+// Root returns the root directory.
+func (c *Config) Root() string {
+	return c.GetDefault("Dir", "/")
+}
+
+// File returns the path of a file setting under Dir::Etc, e.g.
+// "Dir::Etc::sourcelist", resolved against Dir and Dir::Etc the way apt's
+// FindFile does. Absolute values are joined under the root, Dir, too.
+func (c *Config) File(key, def string) string {
+	v := c.GetDefault(key, def)
+	if v == "" {
+		return v
+	}
+	if path.IsAbs(v) {
+		return path.Join(c.Root(), v)
+	}
+
+	parent := key[:strings.LastIndex(key, "::")]
+	if parent == "Dir" {
+		return path.Join(c.Root(), v)
+	}
+	return path.Join(c.File(parent, defaultDirs[parent]), v)
+}
+
+// defaultDirs are the default values of the Dir settings of apt.
+var defaultDirs = map[string]string{
+	"Dir::Etc":   "etc/apt/",
+	"Dir::State": "var/lib/apt/",
+	"Dir::Cache": "var/cache/apt/",
+}
+
+// SourceListPath returns the path of sources.list, Dir::Etc::sourcelist.
+func (c *Config) SourceListPath() string {
+	return c.File("Dir::Etc::sourcelist", "sources.list")
+}
+
+// SourceListDir returns the path of sources.list.d, Dir::Etc::sourceparts.
+func (c *Config) SourceListDir() string {
+	return c.File("Dir::Etc::sourceparts", "sources.list.d")
+}
+
+// TrustedPath returns the path of the legacy keyring, Dir::Etc::trusted.
+func (c *Config) TrustedPath() string {
+	return c.File("Dir::Etc::trusted", "trusted.gpg")
+}
+
+// TrustedDir returns the path of the keyring directory,
+// Dir::Etc::trustedparts.
+func (c *Config) TrustedDir() string {
+	return c.File("Dir::Etc::trustedparts", "trusted.gpg.d")
+}
+
+// PreferencesPath returns the path of the preferences file,
+// Dir::Etc::preferences.
+func (c *Config) PreferencesPath() string {
+	return c.File("Dir::Etc::preferences", "preferences")
+}
+
+// PreferencesDir returns the path of the preferences directory,
+// Dir::Etc::preferencesparts.
+func (c *Config) PreferencesDir() string {
+	return c.File("Dir::Etc::preferencesparts", "preferences.d")
+}
+
+// StatusPath returns the path of the dpkg status database,
+// Dir::State::status.
+func (c *Config) StatusPath() string {
+	if v := c.Get("Dir::State::status"); v != "" {
+		return c.File("Dir::State::status", "")
+	}
+	return path.Join(c.Root(), "var/lib/dpkg/status")
+}
+
+// ExtendedStatesPath returns the path of the apt state of the packages,
+// e.g. which are automatically installed, Dir::State::extended_states.
+func (c *Config) ExtendedStatesPath() string {
+	return c.File("Dir::State::extended_states", "extended_states")
+}
+
+// ListsDir returns the directory of the downloaded indices,
+// Dir::State::lists.
+func (c *Config) ListsDir() string {
+	return c.File("Dir::State::lists", "lists")
+}
+
+// Retries returns the number of retries of failed downloads,
+// Acquire::Retries.
+func (c *Config) Retries() int {
+	return c.GetInt("Acquire::Retries", 0)
+}
+
+// Architectures returns APT::Architectures, or APT::Architecture if the
+// list is not set. The first architecture is the native one.
+func (c *Config) Architectures() []string {
+	archs := c.GetList("APT::Architectures")
+	native := c.Get("APT::Architecture")
+	if native == "" {
+		return archs
+	}
+
+	ret := []string{native}
+	for _, arch := range archs {
+		if arch != native {
+			ret = append(ret, arch)
+		}
+	}
+	return ret
+}
+
+// Languages returns the languages of the description translations,
+// Acquire::Languages. It defaults to English, and is empty for "none".
+func (c *Config) Languages() []string {
+	langs := c.GetList("Acquire::Languages")
+	if len(langs) == 0 {
+		return []string{"en"}
+	}
+
+	var ret []string
+	for _, lang := range langs {
+		switch lang {
+		case "none":
+			return nil
+		case "environment":
+			// Not supported, the environment is the build host's
+			continue
+		}
+		ret = append(ret, lang)
+	}
+	return ret
+}
+
+// Proxy returns the proxy function of Acquire::http::Proxy and
+// Acquire::https::Proxy, including the per host overrides like
+// Acquire::http::Proxy::example.com "DIRECT". It returns nil if no proxy is
+// configured, so that the environment is used.
+func (c *Config) Proxy() func(*http.Request) (*url.URL, error) {
+	if c.Get("Acquire::http::Proxy") == "" && c.Get("Acquire::https::Proxy") == "" {
+		return nil
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		scheme := req.URL.Scheme
+		proxy := c.Get("Acquire::" + scheme + "::Proxy::" + req.URL.Hostname())
+		if proxy == "" {
+			proxy = c.Get("Acquire::" + scheme + "::Proxy")
+		}
+		if proxy == "" && scheme == "https" {
+			// apt uses the http proxy for https too
+			proxy = c.Get("Acquire::http::Proxy")
+		}
+		if proxy == "" || strings.EqualFold(proxy, "DIRECT") {
+			return nil, nil
+		}
+		return url.Parse(proxy)
+	}
+}
@@ -0,0 +1,190 @@
+// Package bazel generates Bazel repository declarations from a lockfile, so
+// Bazel fetches the exact locked .deb files, checking their SHA256, without
+// goapt at build time.
+package bazel
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/lock"
+)
+
+// invalidName matches the characters not allowed in repository names.
+var invalidName = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Options configures the generated repositories.
+type Options struct {
+	// Prefix prefixes the repository names. Defaults to "deb_".
+	Prefix string
+	// Mirrors are the mirrors tried after the URL of a package, in order.
+	// A mirror is either "<url>=<mirror>", replacing the URL prefix <url>
+	// of the packages by <mirror>, or a bare URL, serving every host under
+	// its path like goapt mirror, goapt proxy and published snapshots, e.g.
+	// http://proxy:3142/archive.ubuntu.com/ubuntu/pool/....
+	Mirrors []string
+	// Source is the name of the lockfile, for the header of the generated
+	// files.
+	Source string
+}
+
+// Repository is an http_file repository of a locked package.
+type Repository struct {
+	Name string
+	// Package is the locked package, "name:arch".
+	Package string
+	URLs    []string
+	SHA256  string
+	// DownloadedFilePath is the name of the downloaded file, the base name
+	// of the .deb file.
+	DownloadedFilePath string
+}
+
+// Repositories returns the repositories of the packages of l, in the order
+// of the lockfile.
+func Repositories(l *lock.Lockfile, options *Options) ([]Repository, error) {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	if o.Prefix == "" {
+		o.Prefix = "deb_"
+	}
+
+	var (
+		ret   []Repository
+		names = map[string]string{}
+	)
+	for _, p := range l.Packages {
+		key := p.Name + ":" + p.Arch
+		name := o.Prefix + invalidName.ReplaceAllString(strings.ReplaceAll(p.Name, "+", "plus"), "_") + "_" + p.Arch
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("packages %s and %s have the same repository name %s", other, key, name)
+		}
+		names[name] = key
+
+		urls := []string{p.URL}
+		for _, mirror := range o.Mirrors {
+			u, err := mirrorURL(mirror, p.URL)
+			if err != nil {
+				return nil, err
+			}
+			if u != "" && !contains(urls, u) {
+				urls = append(urls, u)
+			}
+		}
+		ret = append(ret, Repository{
+			Name:               name,
+			Package:            key,
+			URLs:               urls,
+			SHA256:             p.SHA256,
+			DownloadedFilePath: path.Base(p.Filename),
+		})
+	}
+	return ret, nil
+}
+
+// mirrorURL returns the URL of the file at u on mirror, or "" if the mirror
+// doesn't serve it.
+func mirrorURL(mirror, u string) (string, error) {
+	if prefix, to, ok := strings.Cut(mirror, "="); ok {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+		if !strings.HasPrefix(u, prefix) {
+			return "", nil
+		}
+		return strings.TrimSuffix(to, "/") + "/" + strings.TrimPrefix(u, prefix), nil
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	// The escaped path keeps the file names of the pool as published, e.g.
+	// with the %3a of an epoch
+	return strings.TrimSuffix(mirror, "/") + "/" + parsed.Host + parsed.EscapedPath(), nil
+}
+
+// WriteBzl writes a .bzl file declaring the repositories with http_file to
+// w. It defines:
+//
+//   - PACKAGES, the labels of the .deb files by "name:arch";
+//   - goapt_repositories(), a macro declaring the repositories, for
+//     WORKSPACE;
+//   - goapt, a module extension declaring the repositories, for
+//     MODULE.bazel.
+func WriteBzl(w io.Writer, repos []Repository, options *Options) error {
+	var b strings.Builder
+	writeHeader(&b, options)
+	b.WriteString(`load("@bazel_tools//tools/build_defs/repo:http.bzl", "http_file")` + "\n\n")
+
+	b.WriteString("PACKAGES = {\n")
+	for _, r := range repos {
+		fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(r.Package), strconv.Quote("@"+r.Name+"//file"))
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("def goapt_repositories():\n")
+	b.WriteString(`    """Declares a repository per locked .deb file."""` + "\n")
+	if len(repos) == 0 {
+		b.WriteString("    pass\n")
+	}
+	for _, r := range repos {
+		b.WriteString("    http_file(\n")
+		fmt.Fprintf(&b, "        name = %s,\n", strconv.Quote(r.Name))
+		b.WriteString("        urls = [\n")
+		for _, u := range r.URLs {
+			fmt.Fprintf(&b, "            %s,\n", strconv.Quote(u))
+		}
+		b.WriteString("        ],\n")
+		fmt.Fprintf(&b, "        sha256 = %s,\n", strconv.Quote(r.SHA256))
+		fmt.Fprintf(&b, "        downloaded_file_path = %s,\n", strconv.Quote(r.DownloadedFilePath))
+		b.WriteString("    )\n")
+	}
+
+	b.WriteString(`
+def _goapt_impl(module_ctx):
+    goapt_repositories()
+
+goapt = module_extension(implementation = _goapt_impl)
+`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteModule writes the MODULE.bazel lines using the module extension of
+// the .bzl file at label, e.g. "//third_party:debs.bzl", and importing the
+// repositories, to w.
+func WriteModule(w io.Writer, label string, repos []Repository, options *Options) error {
+	var b strings.Builder
+	writeHeader(&b, options)
+	fmt.Fprintf(&b, "goapt = use_extension(%s, \"goapt\")\n", strconv.Quote(label))
+	b.WriteString("use_repo(\n    goapt,\n")
+	for _, r := range repos {
+		fmt.Fprintf(&b, "    %s,\n", strconv.Quote(r.Name))
+	}
+	b.WriteString(")\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, options *Options) {
+	source := "a lockfile"
+	if options != nil && options.Source != "" {
+		source = options.Source
+	}
+	fmt.Fprintf(b, "# Code generated by goapt bazel from %s. DO NOT EDIT.\n\n", source)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
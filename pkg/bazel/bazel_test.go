@@ -0,0 +1,167 @@
+package bazel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/lock"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+var testLockfile = &lock.Lockfile{
+	Version: lock.Version,
+	Sources: []lock.Source{
+		{URL: "http://archive.ubuntu.com/ubuntu", Suite: "focal", Components: []string{"main"}, InRelease: testHash},
+	},
+	Packages: []lock.Package{
+		{
+			Name:      "hello",
+			Version:   "2.10-2ubuntu2",
+			Arch:      "amd64",
+			Filename:  "pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+			Size:      28188,
+			SHA256:    testHash,
+			URL:       "http://archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+			InRelease: testHash,
+		},
+		{
+			Name:      "libstdc++6",
+			Version:   "10.3.0-1ubuntu1~20.04",
+			Arch:      "amd64",
+			Filename:  "pool/main/g/gcc-10/libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+			Size:      500892,
+			SHA256:    testHash,
+			URL:       "http://archive.ubuntu.com/ubuntu/pool/main/g/gcc-10/libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+			InRelease: testHash,
+		},
+	},
+}
+
+func TestRepositories(t *testing.T) {
+	repos, err := Repositories(testLockfile, &Options{
+		Mirrors: []string{
+			"http://archive.ubuntu.com/ubuntu=https://mirror.example.com/ubuntu/",
+			"http://proxy:3142",
+			"http://security.ubuntu.com/ubuntu=https://mirror.example.com/ubuntu",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := []Repository{
+		{
+			Name:    "deb_hello_amd64",
+			Package: "hello:amd64",
+			URLs: []string{
+				"http://archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+				"https://mirror.example.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+				"http://proxy:3142/archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+			},
+			SHA256:             testHash,
+			DownloadedFilePath: "hello_2.10-2ubuntu2_amd64.deb",
+		},
+		{
+			Name:    "deb_libstdcplusplus6_amd64",
+			Package: "libstdc++6:amd64",
+			URLs: []string{
+				"http://archive.ubuntu.com/ubuntu/pool/main/g/gcc-10/libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+				"https://mirror.example.com/ubuntu/pool/main/g/gcc-10/libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+				"http://proxy:3142/archive.ubuntu.com/ubuntu/pool/main/g/gcc-10/libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+			},
+			SHA256:             testHash,
+			DownloadedFilePath: "libstdc++6_10.3.0-1ubuntu1~20.04_amd64.deb",
+		},
+	}
+	if !cmp.Equal(expect, repos) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, repos))
+	}
+
+	// Names are sanitized, which may collide
+	collide := &lock.Lockfile{Packages: append([]lock.Package{}, testLockfile.Packages...)}
+	collide.Packages[0].Name = "g++"
+	collide.Packages[1].Name = "gplusplus"
+	if _, err := Repositories(collide, nil); err == nil {
+		t.Errorf("expect err for colliding names; got nil")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	repos, err := Repositories(&lock.Lockfile{Packages: testLockfile.Packages[:1]}, &Options{Mirrors: []string{"http://proxy:3142"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options := &Options{Source: "goapt.lock"}
+
+	var b strings.Builder
+	if err := WriteBzl(&b, repos, options); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := `# Code generated by goapt bazel from goapt.lock. DO NOT EDIT.
+
+load("@bazel_tools//tools/build_defs/repo:http.bzl", "http_file")
+
+PACKAGES = {
+    "hello:amd64": "@deb_hello_amd64//file",
+}
+
+def goapt_repositories():
+    """Declares a repository per locked .deb file."""
+    http_file(
+        name = "deb_hello_amd64",
+        urls = [
+            "http://archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+            "http://proxy:3142/archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+        ],
+        sha256 = "` + testHash + `",
+        downloaded_file_path = "hello_2.10-2ubuntu2_amd64.deb",
+    )
+
+def _goapt_impl(module_ctx):
+    goapt_repositories()
+
+goapt = module_extension(implementation = _goapt_impl)
+`
+	if got := b.String(); got != expect {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	b.Reset()
+	if err := WriteModule(&b, "//third_party:debs.bzl", repos, options); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect = `# Code generated by goapt bazel from goapt.lock. DO NOT EDIT.
+
+goapt = use_extension("//third_party:debs.bzl", "goapt")
+use_repo(
+    goapt,
+    "deb_hello_amd64",
+)
+`
+	if got := b.String(); got != expect {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	deb := "http://archive.ubuntu.com/ubuntu/pool/main/s/shadow/passwd_1%3a4.8.1-1ubuntu5_amd64.deb"
+	tests := []struct {
+		mirror string
+		expect string
+	}{
+		{mirror: "http://proxy:3142", expect: "http://proxy:3142/archive.ubuntu.com/ubuntu/pool/main/s/shadow/passwd_1%3a4.8.1-1ubuntu5_amd64.deb"},
+		{mirror: "http://proxy:3142/", expect: "http://proxy:3142/archive.ubuntu.com/ubuntu/pool/main/s/shadow/passwd_1%3a4.8.1-1ubuntu5_amd64.deb"},
+		{mirror: "http://archive.ubuntu.com/ubuntu=https://mirror.example.com/ubuntu", expect: "https://mirror.example.com/ubuntu/pool/main/s/shadow/passwd_1%3a4.8.1-1ubuntu5_amd64.deb"},
+		{mirror: "http://security.ubuntu.com/ubuntu=https://mirror.example.com/ubuntu", expect: ""},
+	}
+	for _, test := range tests {
+		got, err := mirrorURL(test.mirror, deb)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != test.expect {
+			t.Errorf("%s: expect %s; got %s", test.mirror, test.expect, got)
+		}
+	}
+}
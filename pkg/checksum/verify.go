@@ -27,12 +27,17 @@ type Checksum struct {
 
 // Verify checksum of a file locally or hosted on http server.
 func Verify(pathOrUrl string, checksum Checksum) (bool, error) {
+	return VerifyWith(nil, pathOrUrl, checksum)
+}
+
+// VerifyWith verifies checksum of a file opened with f.
+func VerifyWith(f *common.Fetcher, pathOrUrl string, checksum Checksum) (bool, error) {
 	hash, ok := hashMap[checksum.Type]
 	if !ok {
 		return false, fmt.Errorf("unsupported checksum type %v", checksum.Type)
 	}
 
-	rc, err := common.ReaderOf(pathOrUrl)
+	rc, err := f.Open(pathOrUrl)
 	if err != nil {
 		return false, err
 	}
@@ -43,7 +48,5 @@ func Verify(pathOrUrl string, checksum Checksum) (bool, error) {
 		return false, err
 	}
 
-	fmt.Printf("%x\n", h.Sum(nil))
-
 	return fmt.Sprintf("%x", h.Sum(nil)) == checksum.Value, nil
 }
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Decompress returns a reader decompressing r according to the file
+// extension of name. Files without a known extension are returned as is.
+func Decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip: %w", err)
+		}
+		return zr, nil
+	case strings.HasSuffix(name, ".xz"):
+		zr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress xz: %w", err)
+		}
+		return io.NopCloser(zr), nil
+	case strings.HasSuffix(name, ".zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case strings.HasSuffix(name, ".bz2"):
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// nopWriteCloser is a writer with a no-op Close.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Compress returns a writer compressing to w according to the file
+// extension of name, the reverse of Decompress. The output is
+// deterministic, e.g. gzip headers carry no name or time. Files without a
+// known extension are written as is. Close flushes the compressed data but
+// doesn't close w.
+func Compress(name string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case strings.HasSuffix(name, ".xz"):
+		zw, err := xz.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress xz: %w", err)
+		}
+		return zw, nil
+	case strings.HasSuffix(name, ".zst"):
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress zstd: %w", err)
+		}
+		return zw, nil
+	case strings.HasSuffix(name, ".bz2"):
+		return nil, fmt.Errorf("bzip2 compression is not supported")
+	}
+	return nopWriteCloser{w}, nil
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Paragraph is a stanza of "Field: value" lines in deb822 format, as used
+// by debian control files, Packages indices and the dpkg status database.
+// Check deb822(5) for details.
+type Paragraph struct {
+	// Keys keeps the original order of the fields.
+	Keys   []string
+	Values map[string]string
+}
+
+// NewParagraph creates an empty paragraph.
+func NewParagraph() *Paragraph {
+	return &Paragraph{Values: map[string]string{}}
+}
+
+// Get returns the value of a field. Continuation lines of a multi-line
+// field are joined with "\n" and keep their leading space.
+func (p *Paragraph) Get(key string) string {
+	return p.Values[key]
+}
+
+// Set adds or updates a field.
+func (p *Paragraph) Set(key, value string) {
+	if _, ok := p.Values[key]; !ok {
+		p.Keys = append(p.Keys, key)
+	}
+	p.Values[key] = value
+}
+
+// Del removes a field.
+func (p *Paragraph) Del(key string) {
+	if _, ok := p.Values[key]; !ok {
+		return
+	}
+	delete(p.Values, key)
+	for i, k := range p.Keys {
+		if k == key {
+			p.Keys = append(p.Keys[:i], p.Keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// String formats the paragraph without the trailing empty line.
+func (p *Paragraph) String() string {
+	var b strings.Builder
+	for _, key := range p.Keys {
+		value := p.Values[key]
+		if strings.HasPrefix(value, "\n") {
+			b.WriteString(key + ":" + value + "\n")
+		} else {
+			b.WriteString(key + ": " + value + "\n")
+		}
+	}
+	return b.String()
+}
+
+// ReadParagraph reads the next paragraph from r. It returns io.EOF if no
+// more paragraph is available.
+func ReadParagraph(r *bufio.Reader) (*Paragraph, error) {
+	var (
+		p    = NewParagraph()
+		last string
+	)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(p.Keys) > 0 {
+				return p, nil
+			}
+		case strings.HasPrefix(line, "#"):
+			// Comment
+		case line[0] == ' ' || line[0] == '\t':
+			if last != "" {
+				p.Values[last] += "\n" + line
+			}
+		default:
+			i := strings.Index(line, ":")
+			if i < 0 {
+				break
+			}
+			last = line[:i]
+			p.Set(last, strings.TrimSpace(line[i+1:]))
+		}
+
+		if eof {
+			if len(p.Keys) > 0 {
+				return p, nil
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// ParseParagraphs parses all paragraphs from r.
+func ParseParagraphs(r io.Reader) ([]*Paragraph, error) {
+	var (
+		buf = bufio.NewReader(r)
+		ret []*Paragraph
+	)
+	for {
+		p, err := ReadParagraph(buf)
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+}
+
+// SplitList splits a comma separated field value like Depends or Conffiles
+// into trimmed items.
+func SplitList(value string) []string {
+	var ret []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
@@ -1,28 +1,96 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"strings"
 )
 
+// Fetcher opens local paths and http/https urls. A nil Fetcher uses
+// http.DefaultClient without retries.
+type Fetcher struct {
+	// Client is the client used to fetch http/https urls,
+	// http.DefaultClient if nil.
+	Client *http.Client
+	// Retries is the number of retries of a failed http request.
+	Retries int
+}
+
+// StatusError is the error of an http request answered with a status other
+// than 200 OK. It matches fs.ErrNotExist for 404 Not Found and 410 Gone,
+// like opening a missing local file.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to fetch %q, status: %v", e.URL, e.Status)
+}
+
+// Is reports whether the error matches target, fs.ErrNotExist for missing
+// files.
+func (e *StatusError) Is(target error) bool {
+	return target == fs.ErrNotExist && (e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone)
+}
+
 // ReaderOf loads io.ReadCloser from a path or url
 func ReaderOf(pathOrUrl string) (io.ReadCloser, error) {
+	return (*Fetcher)(nil).Open(pathOrUrl)
+}
+
+// Open loads io.ReadCloser from a path or url. Network errors and server
+// errors are retried.
+func (f *Fetcher) Open(pathOrUrl string) (io.ReadCloser, error) {
+	return f.OpenContext(context.Background(), pathOrUrl)
+}
+
+// OpenContext is Open with a context. Cancelling ctx aborts the request,
+// and the reads of the returned body.
+func (f *Fetcher) OpenContext(ctx context.Context, pathOrUrl string) (io.ReadCloser, error) {
 	if !strings.HasPrefix(pathOrUrl, "http") {
 		return os.Open(pathOrUrl)
 	}
 
-	resp, err := http.Get(pathOrUrl)
-	if err != nil {
-		return nil, err
+	client, retries := http.DefaultClient, 0
+	if f != nil {
+		client, retries = f.Client, f.Retries
+		if client == nil {
+			client = http.DefaultClient
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("failed to fetch %q, status: %v", pathOrUrl, resp.Status)
-	}
+	var err error
+	for i := 0; i <= retries; i++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, pathOrUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			err = &StatusError{URL: pathOrUrl, StatusCode: resp.StatusCode, Status: resp.Status}
+			if resp.StatusCode < http.StatusInternalServerError {
+				return nil, err
+			}
+			continue
+		}
 
-	return resp.Body, nil
+		return resp.Body, nil
+	}
+	return nil, err
 }
@@ -0,0 +1,208 @@
+// Package contents reads Contents indices, which map the files of a
+// repository to the packages shipping them, and stores them as compact
+// indices to search, like apt-file.
+package contents
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// indexMagic is the first line of a compact index.
+const indexMagic = "goapt-contents 1"
+
+// Parse streams the entries of a Contents index, calling fn with the path
+// of every file, without the leading "/", and the names of the packages
+// shipping it.
+//
+// Example:
+//
+//	usr/bin/perl                                 perl/perl-base
+//	usr/lib/x86_64-linux-gnu/libssl.so.1.1       libs/libssl1.1
+func Parse(r io.Reader, fn func(path string, pkgs []string) error) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t")
+		// The path may contain spaces, the location column doesn't
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		file, location := strings.TrimRight(line[:i], " \t"), line[i+1:]
+		if file == "" || (file == "FILE" && location == "LOCATION") {
+			continue
+		}
+
+		var pkgs []string
+		for _, l := range strings.Split(location, ",") {
+			// A location is [[area/]section/]name
+			pkgs = append(pkgs, l[strings.LastIndex(l, "/")+1:])
+		}
+		if err := fn(file, pkgs); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// WriteIndex converts the Contents index read from r to a compact index
+// written to w. The compact index stores every package name once, and is
+// written in a single pass over r.
+//
+// The format is the magic line, the number of packages, the names of the
+// packages, and a line per file of the path and the numbers of the
+// packages:
+//
+//	goapt-contents 1
+//	2
+//	perl-base
+//	libssl1.1
+//	usr/bin/perl	0
+//	usr/lib/x86_64-linux-gnu/libssl.so.1.1	1
+func WriteIndex(w io.Writer, r io.Reader) error {
+	body, err := os.CreateTemp("", "goapt-contents-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(body.Name())
+	defer body.Close()
+
+	var (
+		bw    = bufio.NewWriter(body)
+		names []string
+		ids   = map[string]int{}
+	)
+	err = Parse(r, func(file string, pkgs []string) error {
+		bw.WriteString(file)
+		for i, p := range pkgs {
+			id, ok := ids[p]
+			if !ok {
+				id = len(names)
+				ids[p] = id
+				names = append(names, p)
+			}
+			if i == 0 {
+				bw.WriteByte('\t')
+			} else {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(strconv.Itoa(id))
+		}
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%s\n%d\n", indexMagic, len(names))
+	for _, name := range names {
+		out.WriteString(name + "\n")
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, body); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// Match is a file matching a search, with the packages shipping it.
+type Match struct {
+	Path     string   `json:"path"`
+	Packages []string `json:"packages"`
+}
+
+// SearchIndex streams the compact index read from r and returns the files
+// whose absolute path matches.
+func SearchIndex(r io.Reader, match func(path string) bool) ([]Match, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !s.Scan() || s.Text() != indexMagic {
+		return nil, fmt.Errorf("not a contents index")
+	}
+	if !s.Scan() {
+		return nil, fmt.Errorf("truncated contents index")
+	}
+	n, err := strconv.Atoi(s.Text())
+	if err != nil {
+		return nil, fmt.Errorf("invalid contents index: %w", err)
+	}
+	names := make([]string, 0, n)
+	for i := 0; i < n && s.Scan(); i++ {
+		names = append(names, s.Text())
+	}
+	if len(names) != n {
+		return nil, fmt.Errorf("truncated contents index")
+	}
+
+	var ret []Match
+	for s.Scan() {
+		line := s.Text()
+		i := strings.LastIndexByte(line, '\t')
+		if i < 0 {
+			continue
+		}
+		file := "/" + line[:i]
+		if !match(file) {
+			continue
+		}
+
+		m := Match{Path: file}
+		for _, id := range strings.Split(line[i+1:], ",") {
+			j, err := strconv.Atoi(id)
+			if err != nil || j >= len(names) {
+				return nil, fmt.Errorf("invalid contents index line %q", line)
+			}
+			m.Packages = append(m.Packages, names[j])
+		}
+		ret = append(ret, m)
+	}
+	return ret, s.Err()
+}
+
+// Matcher returns the matcher of a pattern like apt-file: a glob if it has
+// glob characters, an exact absolute path if it starts with "/", or a
+// substring of the path otherwise. With regex, the pattern is a regular
+// expression instead.
+func Matcher(pattern string, regex bool) (func(path string) bool, error) {
+	switch {
+	case regex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(pattern, "/") {
+			// Relative globs match the base name, e.g. "libssl.so.*"
+			return func(p string) bool {
+				ok, _ := path.Match(pattern, path.Base(p))
+				return ok
+			}, nil
+		}
+		return func(p string) bool {
+			ok, _ := path.Match(pattern, p)
+			return ok
+		}, nil
+	case strings.HasPrefix(pattern, "/"):
+		return func(p string) bool { return p == pattern }, nil
+	default:
+		return func(p string) bool { return strings.Contains(p, pattern) }, nil
+	}
+}
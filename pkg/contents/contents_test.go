@@ -0,0 +1,102 @@
+package contents
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("testdata/Contents-amd64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	got := map[string][]string{}
+	err = Parse(f, func(path string, pkgs []string) error {
+		got[path] = pkgs
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := map[string][]string{
+		"etc/perl/sitecustomize.pl":                 {"perl-base"},
+		"usr/bin/perl":                              {"perl-base", "perl"},
+		"usr/lib/x86_64-linux-gnu/libssl.so.1.1":    {"libssl1.1"},
+		"usr/share/doc/file with spaces/README":     {"spaced-doc"},
+		"usr/lib/x86_64-linux-gnu/libcrypto.so.1.1": {"libssl1.1"},
+	}
+	if !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	f, err := os.Open("testdata/Contents-amd64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	var idx bytes.Buffer
+	if err := WriteIndex(&idx, f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		pattern string
+		regex   bool
+		expect  []Match
+	}{
+		{
+			pattern: "/usr/bin/perl",
+			expect:  []Match{{Path: "/usr/bin/perl", Packages: []string{"perl-base", "perl"}}},
+		},
+		{
+			pattern: "libssl.so",
+			expect:  []Match{{Path: "/usr/lib/x86_64-linux-gnu/libssl.so.1.1", Packages: []string{"libssl1.1"}}},
+		},
+		{
+			pattern: "lib*.so.1.1",
+			expect: []Match{
+				{Path: "/usr/lib/x86_64-linux-gnu/libssl.so.1.1", Packages: []string{"libssl1.1"}},
+				{Path: "/usr/lib/x86_64-linux-gnu/libcrypto.so.1.1", Packages: []string{"libssl1.1"}},
+			},
+		},
+		{
+			pattern: "/etc/*/*.pl",
+			expect:  []Match{{Path: "/etc/perl/sitecustomize.pl", Packages: []string{"perl-base"}}},
+		},
+		{
+			pattern: "with spaces/[A-Z]+$",
+			regex:   true,
+			expect:  []Match{{Path: "/usr/share/doc/file with spaces/README", Packages: []string{"spaced-doc"}}},
+		},
+		{
+			pattern: "/usr/bin/python3",
+		},
+	}
+
+	for _, test := range tests {
+		match, err := Matcher(test.pattern, test.regex)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.pattern, err)
+		}
+		got, err := SearchIndex(bytes.NewReader(idx.Bytes()), match)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.pattern, err)
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.pattern, cmp.Diff(test.expect, got))
+		}
+	}
+
+	if _, err := SearchIndex(bytes.NewReader([]byte("garbage\n")), func(string) bool { return true }); err == nil {
+		t.Errorf("expect err for an invalid index; got nil")
+	}
+}
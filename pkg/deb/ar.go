@@ -0,0 +1,87 @@
+package deb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// arHeader is the header of a member in an ar archive.
+type arHeader struct {
+	Name string
+	Size int64
+}
+
+// arReader reads the members of a common ar archive, the container format
+// of a .deb file. Check deb(5) for details.
+type arReader struct {
+	r       *bufio.Reader
+	current io.Reader
+	pad     int64
+}
+
+func newArReader(r io.Reader) (*arReader, error) {
+	b := bufio.NewReader(r)
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(b, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != arMagic {
+		return nil, fmt.Errorf("not an ar archive")
+	}
+	return &arReader{r: b}, nil
+}
+
+// Next advances to the next member. It returns io.EOF at the end of the
+// archive.
+func (a *arReader) Next() (*arHeader, error) {
+	if a.current != nil {
+		if _, err := io.Copy(io.Discard, a.current); err != nil {
+			return nil, err
+		}
+	}
+	if a.pad > 0 {
+		if _, err := a.r.Discard(int(a.pad)); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, arHeaderSize)
+	if _, err := io.ReadFull(a.r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated ar header")
+		}
+		return nil, err
+	}
+	if string(buf[58:60]) != "`\n" {
+		return nil, fmt.Errorf("invalid ar header")
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(buf[48:58])), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ar member size: %w", err)
+	}
+	hdr := &arHeader{
+		// GNU ar terminates names with '/'
+		Name: strings.TrimSuffix(strings.TrimSpace(string(buf[0:16])), "/"),
+		Size: size,
+	}
+	a.current = io.LimitReader(a.r, size)
+	a.pad = size % 2
+	return hdr, nil
+}
+
+// Read reads the content of the current member.
+func (a *arReader) Read(p []byte) (int, error) {
+	if a.current == nil {
+		return 0, io.EOF
+	}
+	return a.current.Read(p)
+}
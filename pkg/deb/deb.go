@@ -0,0 +1,209 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// MaintainerScripts are the control files run by dpkg on install and remove.
+var MaintainerScripts = []string{"preinst", "postinst", "prerm", "postrm", "config"}
+
+// Deb is a binary deb package file. Check deb(5) for details.
+type Deb struct {
+	Path    string
+	Size    int64
+	Control *common.Paragraph
+	// Scripts are the maintainer scripts, keyed by name.
+	Scripts map[string][]byte
+	// Conffiles are the absolute paths of the configuration files.
+	Conffiles []string
+	// MD5Sums maps the path of a file, relative to root, to its md5 sum.
+	MD5Sums map[string]string
+}
+
+// Open opens a .deb file and reads its control archive.
+func Open(path string) (*Deb, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deb{
+		Path:    path,
+		Size:    info.Size(),
+		Scripts: map[string][]byte{},
+		MD5Sums: map[string]string{},
+	}
+
+	tr, closer, err := openMember(f, "control.tar")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer closer.Close()
+
+	if err := d.readControl(tr); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if d.Control == nil {
+		return nil, fmt.Errorf("%s: missing control file", path)
+	}
+	return d, nil
+}
+
+func (d *Deb) readControl(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(filepath.Clean(hdr.Name), "./")
+		switch name {
+		case "control":
+			d.Control, err = common.ReadParagraph(bufio.NewReader(bytes.NewReader(content)))
+			if err != nil {
+				return fmt.Errorf("invalid control file: %w", err)
+			}
+		case "conffiles":
+			for _, line := range strings.Split(string(content), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					d.Conffiles = append(d.Conffiles, line)
+				}
+			}
+		case "md5sums":
+			for _, line := range strings.Split(string(content), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 {
+					d.MD5Sums[fields[1]] = fields[0]
+				}
+			}
+		default:
+			for _, script := range MaintainerScripts {
+				if name == script {
+					d.Scripts[name] = content
+				}
+			}
+		}
+	}
+}
+
+// Package returns the package metadata from the control file.
+func (d *Deb) Package() pkg.Package {
+	p := pkg.NewPackage(d.Control)
+	p.Filename = filepath.Base(d.Path)
+	p.Size = int(d.Size)
+	return p
+}
+
+// Check checks that the deb file matches the metadata of p from a
+// repository index.
+func (d *Deb) Check(p pkg.Package) error {
+	got := d.Package()
+	switch {
+	case got.Name != p.Name:
+		return fmt.Errorf("expect package %q; got %q", p.Name, got.Name)
+	case got.Version != p.Version:
+		return fmt.Errorf("expect version %q; got %q", p.Version, got.Version)
+	case got.Arch != p.Arch:
+		return fmt.Errorf("expect architecture %q; got %q", p.Arch, got.Arch)
+	case p.Size != 0 && got.Size != p.Size:
+		return fmt.Errorf("expect size %d; got %d", p.Size, got.Size)
+	}
+	return nil
+}
+
+// DataReader iterates over the entries of data.tar.
+type DataReader struct {
+	*tar.Reader
+	closers []io.Closer
+}
+
+// Close closes the underlying file.
+func (r *DataReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if e := r.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Data opens data.tar for reading. The caller must close the returned reader.
+func (d *Deb) Data() (*DataReader, error) {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	tr, closer, err := openMember(f, "data.tar")
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", d.Path, err)
+	}
+	return &DataReader{Reader: tr, closers: []io.Closer{f, closer}}, nil
+}
+
+// openMember finds the ar member with the given prefix, e.g. "data.tar",
+// and returns a tar reader over its decompressed content.
+func openMember(r io.Reader, prefix string) (*tar.Reader, io.Closer, error) {
+	ar, err := newArReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		hdr, err := ar.Next()
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("missing %s member", prefix)
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if hdr.Name == "debian-binary" {
+			version, err := io.ReadAll(ar)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !strings.HasPrefix(string(version), "2.") {
+				return nil, nil, fmt.Errorf("unsupported deb format version %q", strings.TrimSpace(string(version)))
+			}
+			continue
+		}
+
+		if hdr.Name != prefix && !strings.HasPrefix(hdr.Name, prefix+".") {
+			continue
+		}
+
+		rc, err := common.Decompress(hdr.Name, ar)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(rc), rc, nil
+	}
+}
@@ -0,0 +1,114 @@
+package deb
+
+import (
+	"archive/tar"
+	"io"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/google/go-cmp/cmp"
+)
+
+var fixtures = []string{
+	"testdata/hello_1.0-1_amd64.gzip.deb",
+	"testdata/hello_1.0-1_amd64.xz.deb",
+	"testdata/hello_1.0-1_amd64.zstd.deb",
+	"testdata/hello_1.0-1_amd64.none.deb",
+}
+
+func TestOpen(t *testing.T) {
+	for _, path := range fixtures {
+		d, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		if got := d.Control.Get("Depends"); got != "libc6 (>= 2.31)" {
+			t.Errorf("%s: expect Depends %q; got %q", path, "libc6 (>= 2.31)", got)
+		}
+		if got := d.Control.Get("Description"); got != "example package for goapt tests\n A tiny package with a binary, a conffile, a symlink and a hardlink.\n .\n It is built by build.sh." {
+			t.Errorf("%s: unexpected Description %q", path, got)
+		}
+		if _, ok := d.Scripts["postinst"]; !ok {
+			t.Errorf("%s: expect postinst script", path)
+		}
+		if expect := []string{"/etc/hello/hello.conf"}; !cmp.Equal(expect, d.Conffiles) {
+			t.Errorf("%s: unexpected diff: %v", path, cmp.Diff(expect, d.Conffiles))
+		}
+		if got := d.MD5Sums["usr/bin/hello"]; got == "" {
+			t.Errorf("%s: expect md5sum of usr/bin/hello", path)
+		}
+
+		p := d.Package()
+		expect := pkg.Package{
+			Metadata: common.Metadata{
+				Name:     "hello",
+				Version:  "1.0-1",
+				Section:  "utils",
+				Homepage: "https://example.com/hello",
+			},
+			Filename: p.Filename,
+			Size:     int(d.Size),
+			Arch:     "amd64",
+		}
+		if !cmp.Equal(expect.Metadata, p.Metadata) {
+			t.Errorf("%s: unexpected diff: %v", path, cmp.Diff(expect.Metadata, p.Metadata))
+		}
+		if p.Arch != "amd64" || p.Size != int(d.Size) {
+			t.Errorf("%s: unexpected package %v", path, p)
+		}
+		if got := p.Depends.String(); got != "libc6 (>= 2.31)" {
+			t.Errorf("%s: expect Depends %q; got %q", path, "libc6 (>= 2.31)", got)
+		}
+		if err := d.Check(expect); err != nil {
+			t.Errorf("%s: expect nil err; got %v", path, err)
+		}
+		expect.Version = "2.0"
+		if err := d.Check(expect); err == nil {
+			t.Errorf("%s: expect version mismatch; got nil err", path)
+		}
+	}
+}
+
+func TestData(t *testing.T) {
+	for _, path := range fixtures {
+		d, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		r, err := d.Data()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		entries := map[string]*tar.Header{}
+		for {
+			hdr, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: unexpected error: %v", path, err)
+			}
+			entries[hdr.Name] = hdr
+		}
+		r.Close()
+
+		if hdr := entries["./usr/bin/hello"]; hdr == nil || hdr.Mode&0111 == 0 {
+			t.Errorf("%s: expect executable ./usr/bin/hello; got %v", path, hdr)
+		}
+		if hdr := entries["./usr/bin/hi"]; hdr == nil || hdr.Typeflag != tar.TypeSymlink || hdr.Linkname != "hello" {
+			t.Errorf("%s: expect symlink ./usr/bin/hi; got %v", path, hdr)
+		}
+		if hdr := entries["./etc/hello/hello.conf"]; hdr == nil {
+			t.Errorf("%s: expect ./etc/hello/hello.conf", path)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if _, err := Open("testdata/build.sh"); err == nil {
+		t.Errorf("expect err; got nil")
+	}
+}
@@ -0,0 +1,253 @@
+package deb
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks is the maximum number of symlinks followed when resolving a
+// path inside the root, same as the linux limit.
+const maxSymlinks = 40
+
+// ExtractOptions specifies the options to extract a deb file.
+type ExtractOptions struct {
+	// MapOwner extracts all files as the current user instead of the
+	// owner recorded in the archive. It is required on unprivileged hosts.
+	MapOwner bool
+}
+
+// Extract unpacks data.tar into root and returns the absolute paths, as
+// seen from inside root, of the extracted entries. Device nodes and fifos
+// are skipped. No entry, symlink or hardlink is allowed to write outside
+// root; absolute symlinks are resolved relative to root.
+func (d *Deb) Extract(root string, options *ExtractOptions) ([]string, error) {
+	if options == nil {
+		options = &ExtractOptions{}
+	}
+
+	r, err := d.Data()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	var (
+		ret  []string
+		dirs []*tar.Header
+	)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return ret, err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		created, err := extractEntry(root, name, hdr, r.Reader, options)
+		if err != nil {
+			return ret, fmt.Errorf("%s: %s: %w", d.Path, hdr.Name, err)
+		}
+		if !created {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+		ret = append(ret, name)
+	}
+
+	// Directory modes are applied last, so that read only directories
+	// don't prevent their content from being extracted. Symlinks kept in
+	// place of directories are left untouched, like their targets.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		name := path.Clean("/" + hdr.Name)
+		parent, err := securePath(root, path.Dir(name))
+		if err != nil {
+			return ret, err
+		}
+		target := filepath.Join(parent, path.Base(name))
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		if err := applyMetadata(target, hdr, options); err != nil {
+			return ret, err
+		}
+	}
+	return ret, nil
+}
+
+// extractEntry extracts a single tar entry. It returns false if the entry is
+// skipped.
+func extractEntry(root, name string, hdr *tar.Header, r io.Reader, options *ExtractOptions) (bool, error) {
+	if name == "/" {
+		return false, nil
+	}
+
+	parent, err := securePath(root, path.Dir(name))
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return false, err
+	}
+	target := filepath.Join(parent, path.Base(name))
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		info, err := os.Lstat(target)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			// Like dpkg, keep a symlink to a directory, e.g. /lib -> usr/lib
+			// on usrmerged roots. It's resolved inside root.
+			resolved, err := securePath(root, name)
+			if err != nil {
+				return false, err
+			}
+			if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+				return true, nil
+			}
+		}
+		if err == nil && !info.IsDir() {
+			if err := os.Remove(target); err != nil {
+				return false, err
+			}
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case tar.TypeReg, tar.TypeRegA:
+		if err := removeIfExists(target); err != nil {
+			return false, err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return false, err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return false, err
+		}
+		if err := f.Close(); err != nil {
+			return false, err
+		}
+		return true, applyMetadata(target, hdr, options)
+
+	case tar.TypeSymlink:
+		if err := removeIfExists(target); err != nil {
+			return false, err
+		}
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return false, err
+		}
+		if !options.MapOwner {
+			if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case tar.TypeLink:
+		source, err := securePath(root, path.Clean("/"+hdr.Linkname))
+		if err != nil {
+			return false, err
+		}
+		if err := removeIfExists(target); err != nil {
+			return false, err
+		}
+		return true, os.Link(source, target)
+	}
+
+	// Device nodes, fifos and unknown types are skipped.
+	return false, nil
+}
+
+func removeIfExists(target string) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", target)
+	}
+	return os.Remove(target)
+}
+
+func applyMetadata(target string, hdr *tar.Header, options *ExtractOptions) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+	if !options.MapOwner {
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+		if hdr.Mode&04000 != 0 {
+			mode |= os.ModeSetuid
+		}
+		if hdr.Mode&02000 != 0 {
+			mode |= os.ModeSetgid
+		}
+	}
+	if hdr.Mode&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// securePath resolves name, an absolute path inside root, to a path on the
+// host. Symlinks in name are followed as if root was the filesystem root,
+// so the result never points outside root.
+func securePath(root, name string) (string, error) {
+	var (
+		resolved = "/"
+		parts    = strings.Split(strings.TrimPrefix(path.Clean("/"+name), "/"), "/")
+		links    = 0
+	)
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", name)
+		}
+		link, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(link) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(strings.Trim(link, "/"), "/"), parts...)
+	}
+	return filepath.Join(root, resolved), nil
+}
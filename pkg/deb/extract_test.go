@@ -0,0 +1,134 @@
+package deb
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	for _, path := range fixtures {
+		d, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		root := t.TempDir()
+		files, err := d.Extract(root, &ExtractOptions{MapOwner: true})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if len(files) != 12 {
+			t.Errorf("%s: expect 12 extracted files; got %v", path, files)
+		}
+
+		info, err := os.Stat(filepath.Join(root, "usr/bin/hello"))
+		if err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("%s: expect usr/bin/hello with mode 0755; got %v, %v", path, info, err)
+		}
+		if link, err := os.Readlink(filepath.Join(root, "usr/bin/hi")); err != nil || link != "hello" {
+			t.Errorf("%s: expect symlink to hello; got %q, %v", path, link, err)
+		}
+		hard, err := os.Stat(filepath.Join(root, "usr/bin/hello-hard"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if hard.Sys().(*syscall.Stat_t).Ino != info.Sys().(*syscall.Stat_t).Ino {
+			t.Errorf("%s: expect hello-hard to be a hardlink of hello", path)
+		}
+		if d, err := os.ReadFile(filepath.Join(root, "etc/hello/hello.conf")); err != nil || string(d) != "greeting=hello\n" {
+			t.Errorf("%s: unexpected conffile %q, %v", path, d, err)
+		}
+	}
+}
+
+func TestExtractEscape(t *testing.T) {
+	var (
+		base    = t.TempDir()
+		root    = filepath.Join(base, "root")
+		outside = filepath.Join(base, "outside")
+		options = &ExtractOptions{MapOwner: true}
+	)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.MkdirAll(outside, 0755)
+	os.Symlink(outside, filepath.Join(root, "abs"))
+	os.Symlink("../../outside", filepath.Join(root, "etc", "rel"))
+
+	tests := []struct {
+		desc string
+		hdr  *tar.Header
+	}{
+		{
+			desc: "dot dot",
+			hdr:  &tar.Header{Name: "../../outside/dotdot", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		{
+			desc: "absolute symlink parent",
+			hdr:  &tar.Header{Name: "./abs/abs", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		{
+			desc: "relative symlink parent",
+			hdr:  &tar.Header{Name: "./etc/rel/rel", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		{
+			desc: "symlink to outside",
+			hdr:  &tar.Header{Name: "./abs", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		{
+			desc: "device",
+			hdr:  &tar.Header{Name: "./dev/null", Typeflag: tar.TypeChar, Mode: 0644},
+		},
+	}
+
+	for _, test := range tests {
+		name := filepath.Clean("/" + test.hdr.Name)
+		if _, err := extractEntry(root, name, test.hdr, strings.NewReader(""), options); err != nil {
+			t.Errorf("%s: expect nil err; got %v", test.desc, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 0 {
+		t.Errorf("expect nothing written outside root; got %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dev/null")); !os.IsNotExist(err) {
+		t.Errorf("expect device node to be skipped; got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, outside, "abs")); err != nil {
+		t.Errorf("expect absolute symlink resolved inside root; got %v", err)
+	}
+}
+
+func TestExtractUsrMerge(t *testing.T) {
+	d, err := Open(fixtures[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Like a usrmerged root, with /usr a symlink to a directory
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "merged"), 0750)
+	os.Symlink("merged", filepath.Join(root, "usr"))
+	// A symlink to a file is replaced by the directory
+	os.WriteFile(filepath.Join(root, "hello.conf"), nil, 0644)
+	os.Symlink("../hello.conf", filepath.Join(root, "etc"))
+
+	if _, err := d.Extract(root, &ExtractOptions{MapOwner: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(root, "usr")); err != nil || link != "merged" {
+		t.Errorf("expect the usr symlink kept; got %q, %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "merged/bin/hello")); err != nil {
+		t.Errorf("expect the files extracted through the symlink; got %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "merged")); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("expect the symlink target untouched; got %v, %v", info, err)
+	}
+	if info, err := os.Lstat(filepath.Join(root, "etc")); err != nil || !info.IsDir() {
+		t.Errorf("expect the symlink to a file replaced by a directory; got %v, %v", info, err)
+	}
+}
@@ -0,0 +1,40 @@
+#!/usr/bin/bash
+# Builds the .deb fixtures used by the tests with dpkg-deb.
+
+set -e
+
+ROOT=$(mktemp -d)
+trap "rm -rf ${ROOT}" EXIT
+
+mkdir -p ${ROOT}/DEBIAN ${ROOT}/usr/bin ${ROOT}/usr/share/doc/hello ${ROOT}/etc/hello
+cat > ${ROOT}/DEBIAN/control <<CONTROL
+Package: hello
+Version: 1.0-1
+Architecture: amd64
+Maintainer: goapt <goapt@example.com>
+Installed-Size: 1
+Depends: libc6 (>= 2.31)
+Section: utils
+Priority: optional
+Homepage: https://example.com/hello
+Description: example package for goapt tests
+ A tiny package with a binary, a conffile, a symlink and a hardlink.
+ .
+ It is built by build.sh.
+CONTROL
+printf '#!/bin/sh\nset -e\necho configured\n' > ${ROOT}/DEBIAN/postinst
+chmod 0755 ${ROOT}/DEBIAN/postinst
+echo /etc/hello/hello.conf > ${ROOT}/DEBIAN/conffiles
+
+printf '#!/bin/sh\necho hello\n' > ${ROOT}/usr/bin/hello
+chmod 0755 ${ROOT}/usr/bin/hello
+ln -s hello ${ROOT}/usr/bin/hi
+ln ${ROOT}/usr/bin/hello ${ROOT}/usr/bin/hello-hard
+echo "greeting=hello" > ${ROOT}/etc/hello/hello.conf
+echo "hello copyright" > ${ROOT}/usr/share/doc/hello/copyright
+
+(cd ${ROOT} && find usr -type f -exec md5sum {} \; | sort -k2 > DEBIAN/md5sums)
+
+for c in gzip xz zstd none; do
+	SOURCE_DATE_EPOCH=1600000000 dpkg-deb --root-owner-group -Z${c} --build ${ROOT} hello_1.0-1_amd64.${c}.deb
+done
@@ -0,0 +1,198 @@
+// Package diff compares two states of apt repositories, e.g. two snapshots,
+// and reports the packages added, removed, upgraded and downgraded, and the
+// changed metadata of their releases.
+package diff
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// State is the state of repositories: the releases of their suites and
+// the packages of their Packages indices.
+type State struct {
+	// Releases are the releases by location, e.g.
+	// "archive.ubuntu.com/ubuntu/dists/focal".
+	Releases map[string]*release.Release
+	Packages []pkg.Package
+}
+
+// Change is the change of a package between two states.
+type Change struct {
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+	// From is the old version, empty for added packages.
+	From string `json:"from,omitempty"`
+	// To is the new version, empty for removed packages.
+	To string `json:"to,omitempty"`
+}
+
+// ReleaseChange is the change of a field of a release between two states.
+type ReleaseChange struct {
+	// Suite is the suite of the release, or its codename if unset.
+	Suite string `json:"suite"`
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// Diff is the difference between two states.
+type Diff struct {
+	// Releases are the changed fields of the releases. A release only in
+	// one of the states is reported as a change of its Suite field.
+	Releases   []ReleaseChange `json:"releases"`
+	Added      []Change        `json:"added"`
+	Removed    []Change        `json:"removed"`
+	Upgraded   []Change        `json:"upgraded"`
+	Downgraded []Change        `json:"downgraded"`
+}
+
+// Empty returns whether the states are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Releases)+len(d.Added)+len(d.Removed)+len(d.Upgraded)+len(d.Downgraded) == 0
+}
+
+// Compare compares the states before and after. A package is compared by the
+// highest of its versions in each state, like the candidate of apt.
+// Releases are paired by suite, or paired together if each state has a
+// single release, e.g. when a base image moves from focal to jammy.
+func Compare(before, after *State) *Diff {
+	d := &Diff{
+		Added:      []Change{},
+		Removed:    []Change{},
+		Upgraded:   []Change{},
+		Downgraded: []Change{},
+	}
+	d.Releases = compareReleases(before.Releases, after.Releases)
+
+	from, to := candidates(before.Packages), candidates(after.Packages)
+	for key, p := range from {
+		q := to[key]
+		switch {
+		case q == nil:
+			d.Removed = append(d.Removed, Change{Name: p.Name, Arch: p.Arch, From: p.Version})
+		case version.Compare(p.Version, q.Version) < 0:
+			d.Upgraded = append(d.Upgraded, Change{Name: p.Name, Arch: p.Arch, From: p.Version, To: q.Version})
+		case version.Compare(p.Version, q.Version) > 0:
+			d.Downgraded = append(d.Downgraded, Change{Name: p.Name, Arch: p.Arch, From: p.Version, To: q.Version})
+		}
+	}
+	for key, q := range to {
+		if from[key] == nil {
+			d.Added = append(d.Added, Change{Name: q.Name, Arch: q.Arch, To: q.Version})
+		}
+	}
+	for _, changes := range [][]Change{d.Added, d.Removed, d.Upgraded, d.Downgraded} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Name != changes[j].Name {
+				return changes[i].Name < changes[j].Name
+			}
+			return changes[i].Arch < changes[j].Arch
+		})
+	}
+	return d
+}
+
+// candidates returns the highest version of every package by name and
+// architecture.
+func candidates(pkgs []pkg.Package) map[string]*pkg.Package {
+	ret := map[string]*pkg.Package{}
+	for i := range pkgs {
+		p := &pkgs[i]
+		key := p.Name + ":" + p.Arch
+		if q := ret[key]; q == nil || version.Compare(q.Version, p.Version) < 0 {
+			ret[key] = p
+		}
+	}
+	return ret
+}
+
+// suiteOf returns the suite of r, or its codename if unset.
+func suiteOf(r *release.Release) string {
+	if r.Suite != "" {
+		return r.Suite
+	}
+	return r.Codename
+}
+
+// compareReleases compares the fields of the releases paired by suite.
+func compareReleases(before, after map[string]*release.Release) []ReleaseChange {
+	bySuite := func(releases map[string]*release.Release) map[string]*release.Release {
+		ret := map[string]*release.Release{}
+		for _, r := range releases {
+			ret[suiteOf(r)] = r
+		}
+		return ret
+	}
+	from, to := bySuite(before), bySuite(after)
+
+	var suites []string
+	seen := map[string]bool{}
+	for _, m := range []map[string]*release.Release{from, to} {
+		for suite := range m {
+			if !seen[suite] {
+				seen[suite] = true
+				suites = append(suites, suite)
+			}
+		}
+	}
+	sort.Strings(suites)
+
+	ret := []ReleaseChange{}
+	if len(from) == 1 && len(to) == 1 {
+		for _, a := range from {
+			for _, b := range to {
+				return append(ret, compareRelease(a, b)...)
+			}
+		}
+	}
+	for _, suite := range suites {
+		a, b := from[suite], to[suite]
+		switch {
+		case a == nil:
+			ret = append(ret, ReleaseChange{Suite: suite, Field: "Suite", To: suite})
+		case b == nil:
+			ret = append(ret, ReleaseChange{Suite: suite, Field: "Suite", From: suite})
+		default:
+			ret = append(ret, compareRelease(a, b)...)
+		}
+	}
+	return ret
+}
+
+// compareRelease compares the metadata of two releases of a suite.
+func compareRelease(a, b *release.Release) []ReleaseChange {
+	date := func(r *release.Release) string {
+		if r.Date.IsZero() {
+			return ""
+		}
+		return r.Date.UTC().Format(time.RFC1123)
+	}
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"Origin", a.Origin, b.Origin},
+		{"Label", a.Label, b.Label},
+		{"Suite", a.Suite, b.Suite},
+		{"Version", a.Version, b.Version},
+		{"Codename", a.Codename, b.Codename},
+		{"Date", date(a), date(b)},
+		{"Architectures", strings.Join(a.Archs, " "), strings.Join(b.Archs, " ")},
+		{"Components", strings.Join(a.Components, " "), strings.Join(b.Components, " ")},
+		{"Description", a.Description, b.Description},
+	}
+
+	var ret []ReleaseChange
+	for _, f := range fields {
+		if f.from != f.to {
+			ret = append(ret, ReleaseChange{Suite: suiteOf(b), Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/server"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// writeTestSuite writes a suite under dir/dists/<suite> with a gzipped
+// Packages index of the packages, "name arch version", per architecture.
+func writeTestSuite(t *testing.T, dir, suite, version string, pkgs ...string) {
+	t.Helper()
+
+	indices := map[string]*strings.Builder{}
+	for _, p := range pkgs {
+		fields := strings.Fields(p)
+		if indices[fields[1]] == nil {
+			indices[fields[1]] = &strings.Builder{}
+		}
+		fmt.Fprintf(indices[fields[1]], "Package: %s\nArchitecture: %s\nVersion: %s\n\n", fields[0], fields[1], fields[2])
+	}
+
+	var files strings.Builder
+	for arch, index := range indices {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		w.Write([]byte(index.String()))
+		w.Close()
+
+		name := "main/binary-" + arch + "/Packages"
+		writeTestFile(t, filepath.Join(dir, "dists", suite, name+".gz"), gz.String())
+		fmt.Fprintf(&files, " 0 %d %s.gz\n 0 %d %s\n", gz.Len(), name, index.Len(), name)
+	}
+	writeTestFile(t, filepath.Join(dir, "dists", suite, "Release"), fmt.Sprintf("Origin: Test\nSuite: %s\nVersion: %s\nComponents: main\nMD5Sum:\n%s", suite, version, files.String()))
+}
+
+func TestCompare(t *testing.T) {
+	state := func(version string, pkgs ...string) *State {
+		s := &State{Releases: map[string]*release.Release{
+			"dists/focal": {Suite: "focal", Version: version, Files: map[string]*release.File{}},
+		}}
+		for _, p := range pkgs {
+			fields := strings.Fields(p)
+			s.Packages = append(s.Packages, pkg.Package{Metadata: common.Metadata{Name: fields[0], Version: fields[2]}, Arch: fields[1]})
+		}
+		return s
+	}
+
+	before := state("20.04", "app amd64 1.0", "libfoo amd64 1.0", "libfoo amd64 1.1", "make i386 4.2", "old all 1")
+	after := state("20.04.1", "app amd64 1.0", "libfoo amd64 1.2", "make i386 4.1", "new all 2")
+
+	got := Compare(before, after)
+	expect := &Diff{
+		Releases:   []ReleaseChange{{Suite: "focal", Field: "Version", From: "20.04", To: "20.04.1"}},
+		Added:      []Change{{Name: "new", Arch: "all", To: "2"}},
+		Removed:    []Change{{Name: "old", Arch: "all", From: "1"}},
+		Upgraded:   []Change{{Name: "libfoo", Arch: "amd64", From: "1.1", To: "1.2"}},
+		Downgraded: []Change{{Name: "make", Arch: "i386", From: "4.2", To: "4.1"}},
+	}
+	if !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	if d := Compare(before, before); !d.Empty() {
+		t.Errorf("expect no difference; got %+v", d)
+	}
+
+	// Releases of other suites are added and removed.
+	after.Releases = map[string]*release.Release{
+		"dists/focal":         {Suite: "focal", Version: "20.04"},
+		"dists/focal-updates": {Suite: "focal-updates"},
+	}
+	expectReleases := []ReleaseChange{{Suite: "focal-updates", Field: "Suite", To: "focal-updates"}}
+	if got := Compare(before, after).Releases; !cmp.Equal(expectReleases, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expectReleases, got))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeTestSuite(t, dir, "focal", "20.04", "app amd64 1.0", "make i386 4.2", "doc all 1")
+
+	s, err := LoadDir(dir, []string{"amd64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range s.Packages {
+		got = append(got, p.Name+":"+p.Arch+"="+p.Version)
+	}
+	if expect := []string{"doc:all=1", "app:amd64=1.0"}; !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if r := s.Releases["dists/focal"]; r == nil || r.Version != "20.04" {
+		t.Errorf("expect the focal release; got %v", s.Releases)
+	}
+
+	// The cached lists of apt are named after their URL, uncompressed.
+	lists := t.TempDir()
+	release, _ := os.ReadFile(filepath.Join(dir, "dists/focal/Release"))
+	writeTestFile(t, filepath.Join(lists, "example.com_ubuntu_dists_focal_InRelease"), string(release))
+	writeTestFile(t, filepath.Join(lists, "example.com_ubuntu_dists_focal_main_binary-amd64_Packages"), "Package: app\nArchitecture: amd64\nVersion: 1.1\n")
+	writeTestFile(t, filepath.Join(lists, "lock"), "")
+	cached, err := LoadDir(lists, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := &Diff{
+		Releases:   []ReleaseChange{},
+		Added:      []Change{},
+		Removed:    []Change{{Name: "doc", Arch: "all", From: "1"}, {Name: "make", Arch: "i386", From: "4.2"}},
+		Upgraded:   []Change{{Name: "app", Arch: "amd64", From: "1.0", To: "1.1"}},
+		Downgraded: []Change{},
+	}
+	full, err := LoadDir(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Compare(full, cached); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	srv := httptest.NewServer(server.New(dir, nil))
+	t.Cleanup(srv.Close)
+	remote, err := LoadURL(nil, srv.URL+"/dists/focal/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := Compare(full, remote); !d.Empty() {
+		t.Errorf("expect no difference; got %+v", d)
+	}
+
+	if _, err := LoadDir(t.TempDir(), nil); err == nil {
+		t.Errorf("expect err without a release; got nil")
+	}
+}
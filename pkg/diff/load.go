@@ -0,0 +1,257 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/snapshot"
+)
+
+// packagesIndex matches the Packages indices listed in a Release file, and
+// captures their base name and architecture.
+var packagesIndex = regexp.MustCompile(`^(.*/)?(binary-([^/]+)/Packages)(?:\.(?:gz|xz|zst|bz2))?$`)
+
+// indexExts are the extensions of the Packages indices, in the order they
+// are loaded.
+var indexExts = []string{".xz", ".gz", ".zst", ".bz2", ""}
+
+// opener opens the file name of a state.
+type opener func(name string) (io.ReadCloser, error)
+
+// LoadDir loads the state of the suites under dir: a mirror or a published
+// snapshot with the upstream layout, e.g. dists/focal/InRelease, or the
+// cached lists of apt, e.g. /var/lib/apt/lists. Only the Packages indices
+// of archs, and of "all", are loaded; nil loads every architecture.
+func LoadDir(dir string, archs []string) (*State, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, p)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loadFiles(names, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	}, archs)
+}
+
+// LoadSnapshot loads the state of the snapshot name of store.
+func LoadSnapshot(store *snapshot.Store, name string, archs []string) (*State, error) {
+	snap, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		names []string
+		files = map[string]snapshot.File{}
+	)
+	for _, f := range snap.Files {
+		names = append(names, f.Path)
+		files[f.Path] = f
+	}
+	return loadFiles(names, func(name string) (io.ReadCloser, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return store.Open(f)
+	}, archs)
+}
+
+// LoadURL loads the state of the suite at u, e.g.
+// http://archive.ubuntu.com/ubuntu/dists/focal, fetched with f.
+func LoadURL(f *common.Fetcher, u string, archs []string) (*State, error) {
+	u = strings.TrimSuffix(u, "/")
+	s := &State{Releases: map[string]*release.Release{}}
+	open := func(name string) (io.ReadCloser, error) {
+		return f.Open(u + "/" + name)
+	}
+
+	err := loadSuite(s, u, "", "InRelease", false, open, archs)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = loadSuite(s, u, "", "Release", false, open, archs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadFiles loads the state of the suites among the files names, opened by
+// open. The InRelease file of a suite is preferred over its Release file.
+func loadFiles(names []string, open opener, archs []string) (*State, error) {
+	type suite struct {
+		prefix, name string
+		flat         bool
+	}
+	var (
+		suites   []*suite
+		byPrefix = map[string]*suite{}
+	)
+	for _, name := range names {
+		base := path.Base(name)
+		s := &suite{}
+		switch {
+		case base == "InRelease" || base == "Release":
+			s.prefix, s.name = strings.TrimSuffix(name, base), base
+		case strings.HasSuffix(base, "_InRelease"):
+			s.prefix, s.name, s.flat = strings.TrimSuffix(name, "InRelease"), "InRelease", true
+		case strings.HasSuffix(base, "_Release"):
+			s.prefix, s.name, s.flat = strings.TrimSuffix(name, "Release"), "Release", true
+		default:
+			continue
+		}
+		if prev := byPrefix[s.prefix]; prev == nil {
+			byPrefix[s.prefix] = s
+			suites = append(suites, s)
+		} else if s.name == "InRelease" {
+			*prev = *s
+		}
+	}
+	sort.Slice(suites, func(i, j int) bool {
+		return suites[i].prefix < suites[j].prefix
+	})
+
+	ret := &State{Releases: map[string]*release.Release{}}
+	for _, s := range suites {
+		key := strings.TrimRight(s.prefix, "/_")
+		if err := loadSuite(ret, key, s.prefix, s.name, s.flat, open, archs); err != nil {
+			return nil, err
+		}
+	}
+	if len(ret.Releases) == 0 {
+		return nil, fmt.Errorf("no release found")
+	}
+	return ret, nil
+}
+
+// loadSuite loads the release file prefix+name, and the Packages indices it
+// lists for archs, into s. The indices of flat suites are named like the
+// cached lists of apt, e.g. <prefix>main_binary-amd64_Packages, uncompressed.
+// Releases without files, like the Release files of components, are
+// skipped, and so are indices missing, like the uncompressed indices listed
+// but not published by Ubuntu.
+func loadSuite(s *State, key, prefix, name string, flat bool, open opener, archs []string) error {
+	r, err := loadRelease(open, prefix+name)
+	if err != nil {
+		return err
+	}
+	if len(r.Files) == 0 {
+		return nil
+	}
+	s.Releases[key] = r
+
+	listed := map[string]bool{}
+	var bases []string
+	for file := range r.Files {
+		m := packagesIndex.FindStringSubmatch(file)
+		if m == nil || !matchArch(m[3], archs) {
+			continue
+		}
+		base := m[1] + m[2]
+		if !listed[base] {
+			bases = append(bases, base)
+		}
+		listed[base] = true
+		listed[file] = true
+	}
+	sort.Strings(bases)
+
+	for _, base := range bases {
+		var candidates []string
+		if flat {
+			candidates = append(candidates, prefix+strings.ReplaceAll(base, "/", "_"))
+		}
+		for _, ext := range indexExts {
+			if listed[base+ext] {
+				candidates = append(candidates, prefix+base+ext)
+			}
+		}
+
+		for _, candidate := range candidates {
+			pkgs, err := loadPackages(open, candidate)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("%s: %w", candidate, err)
+			}
+			s.Packages = append(s.Packages, pkgs...)
+			break
+		}
+	}
+	return nil
+}
+
+// matchArch returns whether the indices of arch are loaded.
+func matchArch(arch string, archs []string) bool {
+	if len(archs) == 0 || arch == pkg.ArchAll {
+		return true
+	}
+	for _, a := range archs {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRelease loads a Release file, or the text of an InRelease file. The
+// signature is not verified.
+func loadRelease(open opener, name string) (*release.Release, error) {
+	rc, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	d, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(d)
+	if strings.HasPrefix(strings.TrimSpace(text), "-----BEGIN PGP SIGNED MESSAGE-----") {
+		msg, err := crypto.NewClearTextMessageFromArmored(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		text = msg.GetString()
+	}
+	return release.Parse(strings.NewReader(text))
+}
+
+// loadPackages loads a Packages index, decompressed according to its name.
+func loadPackages(open opener, name string) ([]pkg.Package, error) {
+	rc, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	r, err := common.Decompress(name, rc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return pkg.Parse(r)
+}
@@ -0,0 +1,364 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/common"
+)
+
+const (
+	defaultConcurrency        = 8
+	defaultPerHostConcurrency = 4
+)
+
+// Request is a single file to fetch from a path or url into Path.
+type Request struct {
+	URL  string
+	Path string
+}
+
+// EventType is the kind of a progress event.
+type EventType string
+
+const (
+	EventStart    EventType = "start"
+	EventProgress EventType = "progress"
+	EventDone     EventType = "done"
+	EventFailed   EventType = "failed"
+)
+
+// Event is a progress event emitted while a request is downloading.
+type Event struct {
+	Type  EventType
+	URL   string
+	Bytes int64
+	// Total is the expected size, or -1 if unknown.
+	Total int64
+	// Rate is the average transfer rate in bytes per second.
+	Rate float64
+	// ETA is the estimated time left, or 0 if unknown.
+	ETA time.Duration
+	Err error
+}
+
+// Options specifies the options of a download Manager.
+type Options struct {
+	// Concurrency is the maximum number of transfers in flight.
+	Concurrency int
+	// PerHostConcurrency is the maximum number of transfers in flight
+	// against a single host.
+	PerHostConcurrency int
+	// Client is the http client used for http/https urls.
+	Client *http.Client
+	// Progress, if set, is called for every progress event. It is
+	// called from multiple goroutines.
+	Progress func(Event)
+	// Interval is the minimum interval between two progress events of
+	// the same transfer.
+	Interval time.Duration
+	// Retries is the number of retries of a transfer failed by a network
+	// error or a server error.
+	Retries int
+}
+
+// Manager schedules concurrent downloads.
+type Manager struct {
+	options Options
+	global  chan struct{}
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// New creates a download manager. A nil options uses the defaults.
+func New(options *Options) *Manager {
+	m := &Manager{hosts: map[string]chan struct{}{}}
+	if options != nil {
+		m.options = *options
+	}
+	if m.options.Concurrency <= 0 {
+		m.options.Concurrency = defaultConcurrency
+	}
+	if m.options.PerHostConcurrency <= 0 {
+		m.options.PerHostConcurrency = defaultPerHostConcurrency
+	}
+	if m.options.Client == nil {
+		m.options.Client = http.DefaultClient
+	}
+	if m.options.Interval <= 0 {
+		m.options.Interval = 200 * time.Millisecond
+	}
+	m.global = make(chan struct{}, m.options.Concurrency)
+	return m
+}
+
+// Errors is a list of errors of failed requests.
+type Errors []error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d download(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Download downloads all the requests and waits until they finish. Requests
+// with the same url are only fetched once. The returned error is of type
+// Errors if any request fails.
+func (m *Manager) Download(ctx context.Context, reqs []Request) error {
+	var (
+		order []string
+		paths = map[string][]string{}
+	)
+	for _, req := range reqs {
+		if _, ok := paths[req.URL]; !ok {
+			order = append(order, req.URL)
+		}
+		paths[req.URL] = append(paths[req.URL], req.Path)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs Errors
+	)
+	for _, u := range order {
+		wg.Add(1)
+		go func(u string, dsts []string) {
+			defer wg.Done()
+			if err := m.fetch(ctx, u, dsts); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(u, paths[u])
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Fetch downloads a single request, within the limits of the manager, and
+// waits until it finishes. Unlike Download, every caller gets the error of
+// its own request, so requests are scheduled concurrently by calling Fetch
+// from multiple goroutines.
+func (m *Manager) Fetch(ctx context.Context, req Request) error {
+	return m.fetch(ctx, req.URL, []string{req.Path})
+}
+
+// hostOf returns the key of the per host semaphore.
+func hostOf(pathOrUrl string) string {
+	u, err := url.Parse(pathOrUrl)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Host
+}
+
+func (m *Manager) hostSemaphore(host string) chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sem, ok := m.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, m.options.PerHostConcurrency)
+		m.hosts[host] = sem
+	}
+	return sem
+}
+
+func acquire(ctx context.Context, sem chan struct{}) error {
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch downloads u into the first of dsts and copies it to the rest.
+func (m *Manager) fetch(ctx context.Context, u string, dsts []string) error {
+	host := m.hostSemaphore(hostOf(u))
+	if err := acquire(ctx, host); err != nil {
+		return fmt.Errorf("%s: %w", u, err)
+	}
+	defer func() { <-host }()
+	if err := acquire(ctx, m.global); err != nil {
+		return fmt.Errorf("%s: %w", u, err)
+	}
+	defer func() { <-m.global }()
+
+	var err error
+	for i := 0; i <= m.options.Retries; i++ {
+		if err = m.transfer(ctx, u, dsts[0]); err == nil || ctx.Err() != nil || !retryable(err) {
+			break
+		}
+	}
+	for _, dst := range dsts[1:] {
+		if err != nil {
+			break
+		}
+		err = copyFile(dsts[0], dst)
+	}
+	if err != nil {
+		m.emit(Event{Type: EventFailed, URL: u, Err: err})
+		return fmt.Errorf("%s: %w", u, err)
+	}
+	return nil
+}
+
+// retryable returns whether a transfer failed by err is worth retrying, like
+// common.Fetcher: network errors and server errors are, missing files and
+// other client errors aren't.
+func retryable(err error) bool {
+	var status *common.StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (m *Manager) open(ctx context.Context, pathOrUrl string) (io.ReadCloser, int64, error) {
+	if !strings.HasPrefix(pathOrUrl, "http") {
+		f, err := os.Open(pathOrUrl)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pathOrUrl, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := m.options.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, &common.StatusError{URL: pathOrUrl, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// transfer downloads u into dst through a temporary file, so that dst is
+// never left half written.
+func (m *Manager) transfer(ctx context.Context, u, dst string) error {
+	rc, total, err := m.open(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	p := &progress{m: m, url: u, total: total, start: time.Now()}
+	m.emit(p.event(EventStart))
+	if _, err := io.Copy(io.MultiWriter(f, p), rc); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// CreateTemp creates the file with mode 0600
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), dst); err != nil {
+		return err
+	}
+	m.emit(p.event(EventDone))
+	return nil
+}
+
+func (m *Manager) emit(e Event) {
+	if m.options.Progress != nil {
+		m.options.Progress(e)
+	}
+}
+
+// progress is an io.Writer counting the transferred bytes.
+type progress struct {
+	m     *Manager
+	url   string
+	total int64
+	bytes int64
+	start time.Time
+	last  time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.bytes += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= p.m.options.Interval {
+		p.last = now
+		p.m.emit(p.event(EventProgress))
+	}
+	return len(b), nil
+}
+
+func (p *progress) event(t EventType) Event {
+	e := Event{
+		Type:  t,
+		URL:   p.url,
+		Bytes: p.bytes,
+		Total: p.total,
+	}
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		e.Rate = float64(p.bytes) / elapsed
+	}
+	if e.Rate > 0 && p.total > p.bytes {
+		e.ETA = time.Duration(float64(p.total-p.bytes) / e.Rate * float64(time.Second))
+	}
+	return e
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
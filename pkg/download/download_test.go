@@ -0,0 +1,147 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	var (
+		hits     int32
+		inflight int32
+		peak     int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	reqs := []Request{}
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, Request{
+			URL:  fmt.Sprintf("%s/file-%d", server.URL, i),
+			Path: filepath.Join(dir, fmt.Sprintf("file-%d", i)),
+		})
+	}
+	// Duplicated url
+	reqs = append(reqs, Request{URL: server.URL + "/file-0", Path: filepath.Join(dir, "copy")})
+
+	var (
+		mu     sync.Mutex
+		events = map[EventType]int{}
+	)
+	m := New(&Options{
+		Concurrency:        8,
+		PerHostConcurrency: 2,
+		Progress: func(e Event) {
+			mu.Lock()
+			events[e.Type]++
+			mu.Unlock()
+		},
+	})
+	if err := m.Download(context.Background(), reqs); err != nil {
+		t.Fatalf("expect nil err; got %v", err)
+	}
+
+	if hits != 10 {
+		t.Errorf("expect 10 requests; got %d", hits)
+	}
+	if peak > 2 {
+		t.Errorf("expect at most 2 concurrent requests; got %d", peak)
+	}
+	if events[EventStart] != 10 || events[EventDone] != 10 {
+		t.Errorf("expect 10 start and done events; got %v", events)
+	}
+	for _, req := range reqs {
+		d, err := os.ReadFile(req.Path)
+		if err != nil {
+			t.Errorf("failed to read %s: %v", req.Path, err)
+		}
+		if expect := req.URL[len(server.URL):]; string(d) != expect {
+			t.Errorf("expect %q; got %q", expect, d)
+		}
+	}
+	if info, err := os.Stat(reqs[0].Path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expect mode 0644; got %v, %v", info.Mode(), err)
+	}
+
+	err := m.Download(context.Background(), []Request{
+		{URL: server.URL + "/missing", Path: filepath.Join(dir, "missing")},
+		{URL: server.URL + "/file-1", Path: filepath.Join(dir, "file-1")},
+	})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 {
+		t.Errorf("expect 1 error; got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expect no file for failed download; got %v", err)
+	}
+
+	if err := m.Fetch(context.Background(), Request{URL: server.URL + "/missing", Path: filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("expect err for a missing file; got nil")
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	var hits sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := hits.LoadOrStore(r.URL.Path, new(int32))
+		switch hit := atomic.AddInt32(n.(*int32), 1); {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/flaky" && hit == 1:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	m := New(&Options{Retries: 2})
+	count := func(path string) int32 {
+		n, _ := hits.Load(path)
+		return atomic.LoadInt32(n.(*int32))
+	}
+
+	err := m.Fetch(context.Background(), Request{URL: server.URL + "/missing", Path: filepath.Join(dir, "missing")})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expect fs.ErrNotExist for a missing file; got %v", err)
+	}
+	if got := count("/missing"); got != 1 {
+		t.Errorf("expect a missing file fetched once; got %d requests", got)
+	}
+
+	if err := m.Fetch(context.Background(), Request{URL: server.URL + "/flaky", Path: filepath.Join(dir, "flaky")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := count("/flaky"); got != 2 {
+		t.Errorf("expect a server error retried once; got %d requests", got)
+	}
+}
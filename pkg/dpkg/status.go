@@ -0,0 +1,328 @@
+package dpkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+const (
+	// DefaultStatusPath is the dpkg status database.
+	DefaultStatusPath = "/var/lib/dpkg/status"
+	// DefaultStatusDir is the status database of distroless images, one
+	// control file per package.
+	DefaultStatusDir = "/var/lib/dpkg/status.d"
+)
+
+// Find returns path if the status file exists, or the status.d directory
+// next to it otherwise, e.g. in a distroless root filesystem.
+func Find(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path + ".d"
+	}
+	return path
+}
+
+// Status is the "Status:" field of an entry: the selection state (want),
+// error flag and package state. Check dpkg-query(1) for details.
+type Status struct {
+	Want  string
+	Flag  string
+	State string
+}
+
+// Installed is the status of a normally installed package.
+var Installed = Status{Want: "install", Flag: "ok", State: "installed"}
+
+// ParseStatus parses a "want flag state" triple.
+func ParseStatus(s string) (Status, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Status{}, fmt.Errorf("invalid status %q", s)
+	}
+	return Status{Want: fields[0], Flag: fields[1], State: fields[2]}, nil
+}
+
+func (s Status) String() string {
+	return s.Want + " " + s.Flag + " " + s.State
+}
+
+// IsInstalled returns whether the package files are on the system.
+func (s Status) IsInstalled() bool {
+	return s.State != "not-installed" && s.State != "config-files"
+}
+
+// Entry is a package entry of the status database. Its stanza is the
+// Control of the package.
+type Entry struct {
+	pkg.Package
+	Status Status
+	// File is the name of the status.d file of the entry, e.g. "libssl1.1".
+	// It is empty for the entries of a status file.
+	File string
+}
+
+// Key identifies an entry in the database: the package name, qualified by
+// the architecture for Multi-Arch: same packages.
+func (e *Entry) Key() string {
+	if e.Control.Get("Multi-Arch") == "same" {
+		return e.Name + ":" + e.Arch
+	}
+	return e.Name
+}
+
+// NewEntry creates an entry from a control paragraph. Paragraphs without
+// a Status field, like in status.d, are considered installed.
+func NewEntry(control *common.Paragraph) (*Entry, error) {
+	e := &Entry{
+		Package: pkg.NewPackage(control),
+		Status:  Installed,
+	}
+	if e.Name == "" {
+		return nil, fmt.Errorf("missing Package field")
+	}
+	if s := control.Get("Status"); s != "" {
+		status, err := ParseStatus(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name, err)
+		}
+		e.Status = status
+	}
+	return e, nil
+}
+
+// Database is a dpkg status database, either a single status file or a
+// status.d directory.
+type Database struct {
+	Path    string
+	Dir     bool
+	entries map[string]*Entry
+	removed []string
+}
+
+// Load loads the status database at path, which can be a status file or a
+// status.d directory. A missing path yields an empty database.
+func Load(path string) (*Database, error) {
+	db := &Database{Path: path, entries: map[string]*Entry{}}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		db.Dir = strings.HasSuffix(path, ".d")
+		return db, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return db, db.read(f, path, "")
+	}
+
+	db.Dir = true
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || strings.HasSuffix(file.Name(), ".md5sums") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(path, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		err = db.read(f, f.Name(), file.Name())
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func (db *Database) read(r io.Reader, name, file string) error {
+	buf := bufio.NewReader(r)
+	for {
+		p, err := common.ReadParagraph(buf)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		e, err := NewEntry(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		e.File = file
+		db.entries[e.Key()] = e
+	}
+}
+
+// Packages returns all entries sorted by key.
+func (db *Database) Packages() []*Entry {
+	var ret []*Entry
+	for _, key := range db.keys() {
+		ret = append(ret, db.entries[key])
+	}
+	return ret
+}
+
+// Installed returns the entries whose files are on the system.
+func (db *Database) Installed() []*Entry {
+	var ret []*Entry
+	for _, e := range db.Packages() {
+		if e.Status.IsInstalled() {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// Get returns the entry of a package name, or name:arch.
+func (db *Database) Get(key string) *Entry {
+	if e, ok := db.entries[key]; ok {
+		return e
+	}
+	for _, e := range db.entries {
+		if e.Name == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// Add adds or replaces an entry from a control paragraph, e.g. the control
+// file of a deb. The Status field is set to "install ok installed" if
+// missing.
+func (db *Database) Add(control *common.Paragraph) (*Entry, error) {
+	e, err := NewEntry(control)
+	if err != nil {
+		return nil, err
+	}
+	if control.Get("Status") == "" && !db.Dir {
+		control.Set("Status", Installed.String())
+		// Keep Status right after Package like dpkg does
+		keys := []string{"Package", "Status"}
+		for _, key := range control.Keys {
+			if key != "Package" && key != "Status" {
+				keys = append(keys, key)
+			}
+		}
+		control.Keys = keys
+	}
+	if db.Dir {
+		e.File = db.fileOf(e)
+	}
+	db.entries[e.Key()] = e
+	return e, nil
+}
+
+// Remove removes the entry of a package name, or name:arch. It returns
+// false if the package is not in the database.
+func (db *Database) Remove(key string) bool {
+	e := db.Get(key)
+	if e == nil {
+		return false
+	}
+	delete(db.entries, e.Key())
+	db.removed = append(db.removed, e.File)
+	return true
+}
+
+// fileOf returns the status.d file of a new entry: the file of the entry it
+// replaces, or the package name, qualified by the architecture if another
+// entry already uses it.
+func (db *Database) fileOf(e *Entry) string {
+	if old, ok := db.entries[e.Key()]; ok && old.File != "" {
+		return old.File
+	}
+	for key, other := range db.entries {
+		if key != e.Key() && other.File == e.Name {
+			return e.Name + ":" + e.Arch
+		}
+	}
+	return e.Name
+}
+
+// Save writes the database back to its path.
+func (db *Database) Save() error {
+	if !db.Dir {
+		return db.saveFile()
+	}
+
+	if err := os.MkdirAll(db.Path, 0755); err != nil {
+		return err
+	}
+	// A file may hold several entries
+	files := map[string][]*Entry{}
+	for _, e := range db.Packages() {
+		files[e.File] = append(files[e.File], e)
+	}
+	for _, file := range db.removed {
+		if _, ok := files[file]; ok {
+			continue
+		}
+		for _, name := range []string{file, file + ".md5sums"} {
+			if err := os.Remove(filepath.Join(db.Path, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	db.removed = nil
+
+	for file, entries := range files {
+		var b strings.Builder
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(e.Control.String())
+		}
+		if err := writeFile(filepath.Join(db.Path, file), b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) saveFile() error {
+	var b strings.Builder
+	for _, e := range db.Packages() {
+		b.WriteString(e.Control.String())
+		b.WriteString("\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(db.Path), 0755); err != nil {
+		return err
+	}
+	db.removed = nil
+	return writeFile(db.Path, b.String())
+}
+
+// writeFile atomically replaces path with content.
+func writeFile(path, content string) error {
+	tmp := path + ".goapt-new"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (db *Database) keys() []string {
+	keys := make([]string, 0, len(db.entries))
+	for key := range db.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package dpkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func keysOf(entries []*Entry) []string {
+	var ret []string
+	for _, e := range entries {
+		ret = append(ret, e.Key())
+	}
+	return ret
+}
+
+func TestLoadStatusFile(t *testing.T) {
+	db, err := Load("testdata/status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := []string{"base-files", "libc6:amd64", "nano", "zlib1g:amd64"}
+	if got := keysOf(db.Packages()); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	expect = []string{"base-files", "libc6:amd64", "zlib1g:amd64"}
+	if got := keysOf(db.Installed()); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	zlib := db.Get("zlib1g")
+	if zlib == nil {
+		t.Fatalf("expect zlib1g; got nil")
+	}
+	if expect := (Status{Want: "hold", Flag: "ok", State: "installed"}); zlib.Status != expect {
+		t.Errorf("expect %v; got %v", expect, zlib.Status)
+	}
+	if zlib.Version != "1:1.2.11.dfsg-2ubuntu1.5" {
+		t.Errorf("expect version 1:1.2.11.dfsg-2ubuntu1.5; got %s", zlib.Version)
+	}
+}
+
+func TestLoadStatusDir(t *testing.T) {
+	db, err := Load("testdata/status.d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := []string{"libssl1.1:amd64", "tzdata"}
+	if got := keysOf(db.Installed()); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestSave(t *testing.T) {
+	hello := common.NewParagraph()
+	hello.Set("Package", "hello")
+	hello.Set("Version", "1.0-1")
+	hello.Set("Architecture", "amd64")
+
+	// Status file
+	d, _ := os.ReadFile("testdata/status")
+	path := filepath.Join(t.TempDir(), "status")
+	os.WriteFile(path, d, 0644)
+
+	db, _ := Load(path)
+	if _, err := db.Add(hello); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !db.Remove("nano") {
+		t.Errorf("expect nano to be removed")
+	}
+	if err := db.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db, _ = Load(path)
+	expect := []string{"base-files", "hello", "libc6:amd64", "zlib1g:amd64"}
+	if got := keysOf(db.Packages()); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if got := db.Get("hello").Control.Keys[:2]; !cmp.Equal([]string{"Package", "Status"}, got) {
+		t.Errorf("expect Status after Package; got %v", got)
+	}
+
+	// Status directory
+	dir := filepath.Join(t.TempDir(), "status.d")
+	db, _ = Load(dir)
+	db.Add(hello)
+	if err := db.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello")); err != nil {
+		t.Errorf("expect status.d/hello; got %v", err)
+	}
+	db.Remove("hello")
+	db.Save()
+	if _, err := os.Stat(filepath.Join(dir, "hello")); !os.IsNotExist(err) {
+		t.Errorf("expect status.d/hello to be removed; got %v", err)
+	}
+}
+
+func TestSaveStatusDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "status.d")
+	os.Mkdir(dir, 0755)
+	for _, name := range []string{"libssl1.1", "tzdata", "tzdata.md5sums"} {
+		d, _ := os.ReadFile(filepath.Join("testdata/status.d", name))
+		os.WriteFile(filepath.Join(dir, name), d, 0644)
+	}
+
+	db, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.Get("libssl1.1").File; got != "libssl1.1" {
+		t.Errorf("expect file libssl1.1; got %q", got)
+	}
+
+	// Entries are saved to the files they were loaded from
+	control := db.Get("libssl1.1").Control
+	control.Set("Version", "1.1.1w-0+deb11u1")
+	if _, err := db.Add(control); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db.Remove("tzdata")
+	if err := db.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if expect := []string{"libssl1.1"}; !cmp.Equal(expect, names) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, names))
+	}
+	db, _ = Load(dir)
+	if got := db.Get("libssl1.1:amd64").Version; got != "1.1.1w-0+deb11u1" {
+		t.Errorf("expect version 1.1.1w-0+deb11u1; got %s", got)
+	}
+
+	db.Remove("libssl1.1")
+	db.Save()
+	if _, err := os.Stat(filepath.Join(dir, "libssl1.1")); !os.IsNotExist(err) {
+		t.Errorf("expect status.d/libssl1.1 to be removed; got %v", err)
+	}
+}
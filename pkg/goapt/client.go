@@ -0,0 +1,184 @@
+// Package goapt is a client of apt repositories for a target system. Unlike
+// the package level functions of pkg, release and checksum, a Client owns
+// all of its configuration, so independently configured clients can run in
+// the same process.
+package goapt
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/anfernee/goapt/pkg/aptconf"
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/preferences"
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// Options specifies the options of a Client. Unset options default to the
+// settings of Config.
+type Options struct {
+	// Root is the root directory of the target system, "/" if empty.
+	Root string
+	// Config is the apt configuration. If nil, <Root>/etc/apt/apt.conf and
+	// the files under apt.conf.d are loaded.
+	Config *aptconf.Config
+	// Architectures are the architectures of the target system, native
+	// first. Defaults to APT::Architectures, or the host architecture.
+	Architectures pkg.Architectures
+	// Languages are the languages of the Translation indices to download.
+	// Defaults to Acquire::Languages.
+	Languages []string
+	// Fetcher opens the files of the repositories. Defaults to a fetcher
+	// honoring the proxy and retry settings of Config.
+	Fetcher *common.Fetcher
+	// CacheDir is the directory of the downloaded indices. Defaults to
+	// Dir::State::lists.
+	CacheDir string
+	// KeyRing holds the keys trusted to sign repositories. Defaults to the
+	// keys of Dir::Etc::trusted and Dir::Etc::trustedparts.
+	KeyRing *crypto.KeyRing
+	// Preferences pins the candidate versions of packages. Defaults to the
+	// pins of Dir::Etc::preferences and Dir::Etc::preferencesparts.
+	Preferences preferences.Preferences
+	// Logger logs the progress of the client. Defaults to discarding.
+	Logger *log.Logger
+}
+
+// Client is a client of the apt repositories of a target system.
+type Client struct {
+	config    *aptconf.Config
+	archs     pkg.Architectures
+	languages []string
+	fetcher   *common.Fetcher
+	cacheDir  string
+	keyRing   *crypto.KeyRing
+	prefs     preferences.Preferences
+	logger    *log.Logger
+}
+
+// New creates a client. A nil options configures a client for the running
+// system, like apt.
+func New(options *Options) (*Client, error) {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+
+	c := &Client{
+		config:    o.Config,
+		archs:     o.Architectures,
+		languages: o.Languages,
+		fetcher:   o.Fetcher,
+		cacheDir:  o.CacheDir,
+		keyRing:   o.KeyRing,
+		prefs:     o.Preferences,
+		logger:    o.Logger,
+	}
+
+	root := o.Root
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		root = abs
+	}
+	if c.config == nil {
+		config, err := aptconf.LoadFrom(filepath.Join("/", root, "etc/apt/apt.conf"))
+		if err != nil {
+			return nil, err
+		}
+		c.config = config
+	} else {
+		// The client owns its configuration
+		c.config = c.config.Clone()
+	}
+	if root != "" {
+		c.config.SetRoot(root)
+	}
+
+	if len(c.archs) == 0 {
+		c.archs = c.config.Architectures()
+	}
+	if len(c.archs) == 0 {
+		c.archs = pkg.Architectures{pkg.HostArch()}
+	}
+	if c.languages == nil {
+		c.languages = c.config.Languages()
+	}
+	if c.fetcher == nil {
+		c.fetcher = NewFetcher(c.config)
+	}
+	if c.cacheDir == "" {
+		c.cacheDir = c.config.ListsDir()
+	}
+	if c.keyRing == nil {
+		keyRing, err := release.LoadKeyRing(c.config.TrustedPath(), c.config.TrustedDir())
+		if err != nil {
+			return nil, err
+		}
+		c.keyRing = keyRing
+	}
+	if c.prefs == nil {
+		prefs, err := preferences.LoadFrom(c.config.PreferencesPath(), c.config.PreferencesDir())
+		if err != nil {
+			return nil, err
+		}
+		c.prefs = prefs
+	}
+	if c.logger == nil {
+		c.logger = log.New(io.Discard, "", 0)
+	}
+	return c, nil
+}
+
+// NewFetcher creates a fetcher honoring the proxy settings and
+// Acquire::Retries of config.
+func NewFetcher(config *aptconf.Config) *common.Fetcher {
+	f := &common.Fetcher{
+		Client:  http.DefaultClient,
+		Retries: config.Retries(),
+	}
+	if proxy := config.Proxy(); proxy != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = proxy
+		f.Client = &http.Client{Transport: transport}
+	}
+	return f
+}
+
+// Config returns the apt configuration of the client.
+func (c *Client) Config() *aptconf.Config {
+	return c.config
+}
+
+// Preferences returns the pins of the client.
+func (c *Client) Preferences() preferences.Preferences {
+	return c.prefs
+}
+
+// Architectures returns the architectures of the target system, native
+// first.
+func (c *Client) Architectures() pkg.Architectures {
+	return c.archs
+}
+
+// Root returns the root directory of the target system.
+func (c *Client) Root() string {
+	return c.config.Root()
+}
+
+// CacheDir returns the directory of the downloaded indices.
+func (c *Client) CacheDir() string {
+	return c.cacheDir
+}
+
+// Sources returns the sources of the target system, from
+// Dir::Etc::sourcelist and Dir::Etc::sourceparts.
+func (c *Client) Sources() (pkg.DebianSourceList, error) {
+	return pkg.LoadDebianSourceListFromDir(c.config.SourceListPath(), c.config.SourceListDir())
+}
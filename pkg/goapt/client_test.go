@@ -0,0 +1,335 @@
+package goapt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/anfernee/goapt/pkg/aptconf"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/server"
+	"github.com/anfernee/goapt/pkg/upgrade"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testPackages are the packages of the test repository, by file name.
+var testPackages = map[string]string{
+	"app_1.0_amd64.deb":    "Package: app\nVersion: 1.0\nArchitecture: amd64\nDepends: libfoo (>= 1.0), make\nDescription: example application\n An application using the foo library.\n",
+	"libfoo_1.1_amd64.deb": "Package: libfoo\nSource: foo\nVersion: 1.1\nArchitecture: amd64\nMulti-Arch: same\nDescription: foo library\n",
+	"libfoo_1.0_amd64.deb": "Package: libfoo\nSource: foo\nVersion: 1.0\nArchitecture: amd64\nMulti-Arch: same\nDescription: foo library\n",
+	"make_4.2_i386.deb":    "Package: make\nVersion: 4.2\nArchitecture: i386\nMulti-Arch: foreign\nDescription: build utility\n",
+	"app-data_1.0_all.deb": "Package: app-data\nSource: app\nVersion: 1.0\nArchitecture: all\nDescription: example application data\n",
+}
+
+// testContents is the Contents index of the amd64 packages of testPackages.
+const testContents = `usr/bin/app					devel/app
+usr/lib/x86_64-linux-gnu/libfoo.so.1	libs/libfoo
+usr/share/doc/libfoo/copyright		libs/libfoo,devel/app
+`
+
+// testTranslation is the English Translation index of testPackages, with
+// the long description of libfoo.
+var testTranslation = fmt.Sprintf("Package: libfoo\nDescription-md5: %x\nDescription-en: foo library\n The foo library does foo.\n", md5.Sum([]byte("foo library\n")))
+
+// testRepo is a repository served for tests.
+type testRepo struct {
+	url string
+	dir string
+	// keyRing trusts the key signing the repository.
+	keyRing *crypto.KeyRing
+}
+
+// newTestRepo serves a repository with a focal suite signed by a new key.
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+
+	dir := t.TempDir()
+	indices := map[string]*bytes.Buffer{}
+	for name, control := range testPackages {
+		content := "deb " + name
+		filename := "pool/main/" + name
+		writeTestFile(t, filepath.Join(dir, filename), content)
+
+		arch := strings.TrimSuffix(name[strings.LastIndex(name, "_")+1:], ".deb")
+		if indices[arch] == nil {
+			indices[arch] = &bytes.Buffer{}
+		}
+		fmt.Fprintf(indices[arch], "%sFilename: %s\nSize: %d\nSHA256: %x\n\n", control, filename, len(content), sha256Sum(content))
+	}
+
+	var files strings.Builder
+	for arch, index := range indices {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		w.Write(index.Bytes())
+		w.Close()
+
+		name := "main/binary-" + arch + "/Packages"
+		writeTestFile(t, filepath.Join(dir, "dists/focal", name+".gz"), gz.String())
+		fmt.Fprintf(&files, " %x %d %s\n", md5.Sum(gz.Bytes()), gz.Len(), name+".gz")
+		// Like Ubuntu, the uncompressed index is listed but not published
+		fmt.Fprintf(&files, " %x %d %s\n", md5.Sum(index.Bytes()), index.Len(), name)
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testContents))
+	w.Close()
+	writeTestFile(t, filepath.Join(dir, "dists/focal/Contents-amd64.gz"), gz.String())
+	fmt.Fprintf(&files, " %x %d %s\n", md5.Sum(gz.Bytes()), gz.Len(), "Contents-amd64.gz")
+
+	writeTestFile(t, filepath.Join(dir, "dists/focal/main/i18n/Translation-en"), testTranslation)
+	fmt.Fprintf(&files, " %x %d %s\n", md5.Sum([]byte(testTranslation)), len(testTranslation), "main/i18n/Translation-en")
+
+	key, err := crypto.GenerateKey("goapt", "goapt@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signed, err := helper.SignCleartextMessage(keyRing, "Origin: Test\nSuite: focal\nCodename: focal\nArchitectures: amd64 i386\nComponents: main\nMD5Sum:\n"+files.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeTestFile(t, filepath.Join(dir, "dists/focal/InRelease"), signed)
+
+	srv := httptest.NewServer(server.New(dir, nil))
+	t.Cleanup(srv.Close)
+
+	public, err := key.ToPublic()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	publicRing, err := crypto.NewKeyRing(public)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &testRepo{url: srv.URL + "/", dir: dir, keyRing: publicRing}
+}
+
+// newTestRoot creates a root directory whose sources.list points to url.
+func newTestRoot(t *testing.T, url string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "etc/apt/sources.list"), "deb "+url+" focal main\n")
+	return root
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	repo := newTestRepo(t)
+	root := newTestRoot(t, repo.url)
+
+	c, err := New(&Options{
+		Root:          root,
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, expect := c.CacheDir(), filepath.Join(root, "var/lib/apt/lists"); got != expect {
+		t.Errorf("expect cache dir %s; got %s", expect, got)
+	}
+
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pkgs, err := c.Packages()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pkgs) != len(testPackages) {
+		t.Errorf("expect %d packages; got %d", len(testPackages), len(pkgs))
+	}
+
+	resolved, err := c.Resolve([]string{"app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range resolved {
+		got = append(got, p.Name+":"+p.Arch+"="+p.Version)
+	}
+	if expect := []string{"app:amd64=1.0", "libfoo:amd64=1.1", "make:i386=4.2"}; !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	if _, err := c.Resolve([]string{"libfoo=0.9"}); err == nil {
+		t.Errorf("expect err for missing version; got nil")
+	}
+
+	dir := t.TempDir()
+	paths, err := c.Download(context.Background(), resolved, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expect %s downloaded: %v", path, err)
+		}
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	repo := newTestRepo(t)
+	root := newTestRoot(t, repo.url)
+	writeTestFile(t, filepath.Join(root, "etc/apt/preferences.d/libfoo"), "Package: libfoo\nPin: version 1.0\nPin-Priority: 1001\n")
+
+	c, err := New(&Options{
+		Root:          root,
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The pinned version is resolved, as a dependency too
+	resolved, err := c.Resolve([]string{"app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range resolved {
+		got = append(got, p.Name+":"+p.Arch+"="+p.Version)
+	}
+	if expect := []string{"app:amd64=1.0", "libfoo:amd64=1.0", "make:i386=4.2"}; !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	// The candidate is shown first
+	pkgs, err := c.Show("libfoo", "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Version != "1.0" {
+		t.Errorf("expect libfoo 1.0 first; got %v", pkgs)
+	}
+	if cand, err := c.Candidate("libfoo", ""); err != nil || cand == nil || cand.Version != "1.0" {
+		t.Errorf("expect the candidate libfoo 1.0; got %v, %v", cand, err)
+	}
+
+	// Release pins match the cached InRelease files, and keep the
+	// installed version below the installed priority
+	writeTestFile(t, filepath.Join(root, "var/lib/dpkg/status"), "Package: libfoo\nStatus: install ok installed\nArchitecture: amd64\nVersion: 1.0\n")
+	for _, test := range []struct {
+		prefs  string
+		expect []upgrade.Change
+	}{
+		{prefs: "", expect: []upgrade.Change{{Name: "libfoo", Arch: "amd64", From: "1.0", To: "1.1"}}},
+		{prefs: "Package: libfoo\nPin: release o=Test, n=focal\nPin-Priority: 50\n", expect: []upgrade.Change{}},
+	} {
+		writeTestFile(t, filepath.Join(root, "etc/apt/preferences.d/libfoo"), test.prefs)
+		c, err := New(&Options{Root: root, Architectures: []string{"amd64", "i386"}, KeyRing: repo.keyRing})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		plan, err := c.PlanUpgrade()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cmp.Equal(test.expect, plan.Upgrades) {
+			t.Errorf("%q: unexpected diff: %v", test.prefs, cmp.Diff(test.expect, plan.Upgrades))
+		}
+	}
+}
+
+func TestNewKeepsConfig(t *testing.T) {
+	config := aptconf.New()
+	root := t.TempDir()
+	c, err := New(&Options{Root: root, Config: config})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := config.Root(); got != "/" {
+		t.Errorf("expect the config of the caller unchanged; got root %s", got)
+	}
+	if got := c.Root(); got != root {
+		t.Errorf("expect root %s; got %s", root, got)
+	}
+}
+
+func TestNewRelativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "rootfs/etc/apt/apt.conf"), `APT::Architecture "arm64";`+"\n")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	c, err := New(&Options{Root: "rootfs"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := filepath.Join(dir, "rootfs"); c.Root() != expect {
+		t.Errorf("expect root %s; got %s", expect, c.Root())
+	}
+	if expect := (pkg.Architectures{"arm64"}); !cmp.Equal(expect, c.Architectures()) {
+		t.Errorf("expect the apt.conf of the root; got %v", c.Architectures())
+	}
+}
+
+func TestClientsAreIndependent(t *testing.T) {
+	repo := newTestRepo(t)
+	other := newTestRepo(t)
+
+	trusted, err := New(&Options{Root: newTestRoot(t, repo.url), Architectures: []string{"amd64"}, KeyRing: repo.keyRing})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	untrusted, err := New(&Options{Root: newTestRoot(t, repo.url), Architectures: []string{"i386"}, KeyRing: other.keyRing})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := untrusted.Update(context.Background()); err == nil {
+		t.Errorf("expect err with an untrusted key; got nil")
+	}
+	if _, err := trusted.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pkgs, err := trusted.Packages()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range pkgs {
+		if p.Arch != "amd64" && p.Arch != "all" {
+			t.Errorf("expect only amd64 and all packages; got %s:%s", p.Name, p.Arch)
+		}
+	}
+}
+
+func sha256Sum(s string) [32]byte {
+	return sha256.Sum256([]byte(s))
+}
@@ -0,0 +1,251 @@
+package goapt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/anfernee/goapt/pkg/contents"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// contentsIndexExt is the extension of the cached compact contents
+// indices.
+const contentsIndexExt = ".idx"
+
+// cachedRelease verifies and parses the cached InRelease file of a suite,
+// and returns it with its modification time.
+func (c *Client) cachedRelease(s *suite) (*release.Release, time.Time, error) {
+	path := filepath.Join(c.cacheDir, ListName(s.sources[0].DirectorySignedURL()))
+	signed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, fmt.Errorf("%s %s is not updated, run goapt update", s.url, s.name)
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	text, err := release.VerifyCleartext(signed, c.keyRing)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to verify %s: %w", path, err)
+	}
+	r, err := release.Parse(strings.NewReader(text))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return r, fi.ModTime(), nil
+}
+
+// contentsIndices returns the Contents indices of a suite for arch, both at
+// the suite level like Ubuntu and at the component level like Debian.
+func (c *Client) contentsIndices(s *suite, arch string) []index {
+	base := strings.TrimSuffix(s.url, "/")
+	add := func(ret []index, name, description string) []index {
+		u, _ := url.JoinPath(s.url, "dists", s.name, name)
+		return append(ret, index{url: u, name: name, description: base + " " + description})
+	}
+
+	ret := add(nil, "Contents-"+arch, s.name+" "+arch+" Contents (deb)")
+	seen := map[string]bool{}
+	for _, source := range s.sources {
+		if source.Type != pkg.DebianSourceTypeDeb || seen[source.Component] {
+			continue
+		}
+		seen[source.Component] = true
+		ret = add(ret, source.Component+"/Contents-"+arch, s.name+"/"+source.Component+" "+arch+" Contents (deb)")
+	}
+	return ret
+}
+
+// UpdateContents downloads the Contents indices of the client's
+// architectures listed in the cached InRelease files, checks their hashes,
+// and caches them as compact indices to search with SearchFiles. The
+// indices are large, so they are streamed and never held in memory. Run
+// Update first.
+func (c *Client) UpdateContents(ctx context.Context) ([]Fetch, error) {
+	list, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ret    []Fetch
+		failed int
+	)
+	for _, s := range suites(list) {
+		r, updated, err := c.cachedRelease(s)
+		if err != nil {
+			return ret, err
+		}
+
+		for _, arch := range c.archs {
+			if arch == pkg.ArchAll {
+				continue
+			}
+			found := false
+			for _, idx := range c.contentsIndices(s, arch) {
+				if err := ctx.Err(); err != nil {
+					return ret, err
+				}
+				f := c.updateContents(ctx, r, updated, idx)
+				if f == nil {
+					continue
+				}
+				found = true
+				c.logger.Printf("%s %s", f.Status, f.URL)
+				if f.Status == FetchErr {
+					failed++
+				}
+				ret = append(ret, *f)
+			}
+			if !found {
+				idx := c.contentsIndices(s, arch)[0]
+				ret = append(ret, Fetch{Status: FetchIgn, URL: idx.url, Description: idx.description})
+			}
+		}
+	}
+
+	if failed > 0 {
+		return ret, fmt.Errorf("%d file(s) failed to download", failed)
+	}
+	return ret, nil
+}
+
+// updateContents downloads a Contents index, in the best compression listed
+// in r, into a compact index. It returns nil if r doesn't list the index.
+func (c *Client) updateContents(ctx context.Context, r *release.Release, updated time.Time, idx index) *Fetch {
+	cachePath := filepath.Join(c.cacheDir, ListName(idx.url)+contentsIndexExt)
+
+	for _, ext := range indexCompressions {
+		file, ok := r.Files[idx.name+ext]
+		if !ok {
+			continue
+		}
+
+		result := &Fetch{URL: idx.url + ext, Description: idx.description}
+		if fi, err := os.Stat(cachePath); err == nil && !fi.ModTime().Before(updated) {
+			result.Status = FetchHit
+			return result
+		}
+
+		if err := c.fetchContents(ctx, result.URL, file, cachePath); err != nil {
+			result.Status, result.Err = FetchErr, err
+			return result
+		}
+		result.Status, result.Size = FetchGet, int64(file.Size)
+		return result
+	}
+	return nil
+}
+
+// fetchContents streams a Contents index at u into a compact index at path,
+// checking its hash against file.
+func (c *Client) fetchContents(ctx context.Context, u string, file *release.File, path string) error {
+	rc, err := c.fetcher.OpenContext(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	v := newVerifier(file)
+	dr, err := common.Decompress(u, io.TeeReader(rc, v))
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = contents.WriteIndex(tmp, dr)
+	if err == nil {
+		// Hash the rest of the stream, e.g. the compression trailer
+		_, err = io.Copy(io.Discard, io.TeeReader(rc, v))
+	}
+	if err == nil {
+		err = v.Check()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SearchFiles searches the cached compact contents indices of the client's
+// architectures for the files whose absolute path matches, and returns them
+// sorted by path with the packages shipping them. Run UpdateContents first.
+func (c *Client) SearchFiles(match func(path string) bool) ([]contents.Match, error) {
+	list, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		found  bool
+		byPath = map[string]map[string]bool{}
+	)
+	for _, s := range suites(list) {
+		for _, arch := range c.archs {
+			for _, idx := range c.contentsIndices(s, arch) {
+				f, err := os.Open(filepath.Join(c.cacheDir, ListName(idx.url)+contentsIndexExt))
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				found = true
+
+				matches, err := contents.SearchIndex(f, match)
+				f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", idx.url, err)
+				}
+				for _, m := range matches {
+					if byPath[m.Path] == nil {
+						byPath[m.Path] = map[string]bool{}
+					}
+					for _, p := range m.Packages {
+						byPath[m.Path][p] = true
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no contents index is cached, run goapt file update")
+	}
+
+	ret := []contents.Match{}
+	for path, pkgs := range byPath {
+		m := contents.Match{Path: path}
+		for p := range pkgs {
+			m.Packages = append(m.Packages, p)
+		}
+		sort.Strings(m.Packages)
+		ret = append(ret, m)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Path < ret[j].Path
+	})
+	return ret, nil
+}
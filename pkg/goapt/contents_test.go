@@ -0,0 +1,90 @@
+package goapt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/contents"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSearchFiles(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.UpdateContents(context.Background()); err == nil {
+		t.Errorf("expect err before update; got nil")
+	}
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.SearchFiles(func(string) bool { return true }); err == nil {
+		t.Errorf("expect err before file update; got nil")
+	}
+
+	statuses := func(fetches []Fetch) []string {
+		var ret []string
+		for _, f := range fetches {
+			ret = append(ret, string(f.Status)+" "+f.Description[len(repo.url):])
+		}
+		return ret
+	}
+
+	for _, expect := range [][]string{
+		{"Get focal amd64 Contents (deb)", "Ign focal i386 Contents (deb)"},
+		{"Hit focal amd64 Contents (deb)", "Ign focal i386 Contents (deb)"},
+	} {
+		fetches, err := c.UpdateContents(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := statuses(fetches); !cmp.Equal(expect, got) {
+			t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		expect  []contents.Match
+	}{
+		{
+			pattern: "/usr/bin/app",
+			expect:  []contents.Match{{Path: "/usr/bin/app", Packages: []string{"app"}}},
+		},
+		{
+			pattern: "libfoo",
+			expect: []contents.Match{
+				{Path: "/usr/lib/x86_64-linux-gnu/libfoo.so.1", Packages: []string{"libfoo"}},
+				{Path: "/usr/share/doc/libfoo/copyright", Packages: []string{"app", "libfoo"}},
+			},
+		},
+		{
+			pattern: "*.so.*",
+			expect:  []contents.Match{{Path: "/usr/lib/x86_64-linux-gnu/libfoo.so.1", Packages: []string{"libfoo"}}},
+		},
+		{
+			pattern: "/usr/bin/make",
+			expect:  []contents.Match{},
+		},
+	}
+	for _, test := range tests {
+		match, err := contents.Matcher(test.pattern, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.pattern, err)
+		}
+		got, err := c.SearchFiles(match)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.pattern, err)
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.pattern, cmp.Diff(test.expect, got))
+		}
+	}
+}
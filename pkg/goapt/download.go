@@ -0,0 +1,90 @@
+package goapt
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/anfernee/goapt/pkg/download"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// Download downloads the .deb files of pkgs into dir, and returns their
+// paths in the order of pkgs. Packages with a SHA256 are verified.
+func (c *Client) Download(ctx context.Context, pkgs []pkg.Package, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var (
+		reqs  []download.Request
+		paths []string
+	)
+	for _, p := range pkgs {
+		if p.BaseURL == "" || p.Filename == "" {
+			return nil, fmt.Errorf("unknown location of package %s", p.Name)
+		}
+		u, err := url.JoinPath(p.BaseURL, p.Filename)
+		if err != nil {
+			return nil, err
+		}
+		dest := filepath.Join(dir, path.Base(p.Filename))
+		reqs = append(reqs, download.Request{URL: u, Path: dest})
+		paths = append(paths, dest)
+	}
+
+	m := c.downloader(func(e download.Event) {
+		switch e.Type {
+		case download.EventDone:
+			c.logger.Printf("Get %s [%d B]", e.URL, e.Bytes)
+		case download.EventFailed:
+			c.logger.Printf("Err %s: %v", e.URL, e.Err)
+		}
+	})
+	if err := m.Download(ctx, reqs); err != nil {
+		return nil, err
+	}
+
+	for i, p := range pkgs {
+		if p.SHA256 == "" {
+			continue
+		}
+		sum, err := sha256File(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		if sum != p.SHA256 {
+			return nil, fmt.Errorf("%s: SHA256 mismatch, expect %s; got %s", paths[i], p.SHA256, sum)
+		}
+	}
+	return paths, nil
+}
+
+// downloader returns a download manager fetching with the client's http
+// client and retries, calling progress, if set, for every event.
+func (c *Client) downloader(progress func(download.Event)) *download.Manager {
+	return download.New(&download.Options{
+		Client:   c.fetcher.Client,
+		Retries:  c.fetcher.Retries,
+		Progress: progress,
+	})
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
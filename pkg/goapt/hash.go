@@ -0,0 +1,110 @@
+package goapt
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// strongestHash returns the strongest hash listed for file, with its
+// expected value.
+func strongestHash(file *release.File) (string, hash.Hash, string) {
+	switch {
+	case file.SHA512 != "":
+		return "SHA512", sha512.New(), file.SHA512
+	case file.SHA256 != "":
+		return "SHA256", sha256.New(), file.SHA256
+	case file.SHA1 != "":
+		return "SHA1", sha1.New(), file.SHA1
+	case file.MD5 != "":
+		return "MD5Sum", md5.New(), file.MD5
+	}
+	return "", nil, ""
+}
+
+// verifier checks the size and the strongest hash of the data written to
+// it against a file of a release.
+type verifier struct {
+	file   *release.File
+	name   string
+	h      hash.Hash
+	expect string
+	n      int64
+}
+
+func newVerifier(file *release.File) *verifier {
+	v := &verifier{file: file}
+	v.name, v.h, v.expect = strongestHash(file)
+	return v
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+	v.n += int64(len(p))
+	if v.h != nil {
+		v.h.Write(p)
+	}
+	return len(p), nil
+}
+
+// Check checks the data written so far.
+func (v *verifier) Check() error {
+	if v.h == nil {
+		return fmt.Errorf("%s: no hash in release", v.file.Name)
+	}
+	if v.n != int64(v.file.Size) {
+		return fmt.Errorf("%s: size mismatch, expect %d; got %d", v.file.Name, v.file.Size, v.n)
+	}
+	if got := fmt.Sprintf("%x", v.h.Sum(nil)); got != v.expect {
+		return fmt.Errorf("%s: %s mismatch, expect %s; got %s", v.file.Name, v.name, v.expect, got)
+	}
+	return nil
+}
+
+// checkedReader reads through a verifier, and fails at the end of the data
+// if the verifier fails, so a file written from it is discarded.
+type checkedReader struct {
+	r io.Reader
+	v *verifier
+}
+
+func newCheckedReader(r io.Reader, file *release.File) io.Reader {
+	v := newVerifier(file)
+	return &checkedReader{r: io.TeeReader(r, v), v: v}
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		if cerr := c.v.Check(); cerr != nil {
+			return n, cerr
+		}
+	}
+	return n, err
+}
+
+// check checks the size and the strongest hash of the content of r against
+// file.
+func check(r io.Reader, file *release.File) error {
+	v := newVerifier(file)
+	if _, err := io.Copy(v, r); err != nil {
+		return err
+	}
+	return v.Check()
+}
+
+func checkFile(path string, file *release.File) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return check(f, file)
+}
@@ -0,0 +1,160 @@
+package goapt
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/anfernee/goapt/pkg/lock"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// Lock resolves the packages of the manifest m, and their dependencies, from
+// the cached indices like Resolve, and pins them to their exact versions,
+// files and hashes, along with the InRelease files they came from. The
+// packages are sorted by name and architecture. The client is expected to
+// be configured with the sources and architectures of m, and updated.
+func (c *Client) Lock(m *lock.Manifest) (*lock.Lockfile, error) {
+	list, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := c.resolve(c.index(a), m.Packages)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &lock.Lockfile{Version: lock.Version, Manifest: *m}
+	digests := map[string]string{}
+	for _, s := range suites(list) {
+		if s.sources[0].Type != pkg.DebianSourceTypeDeb {
+			continue
+		}
+		inRelease := s.sources[0].DirectorySignedURL()
+		sum, err := sha256File(filepath.Join(c.cacheDir, ListName(inRelease)))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not cached, run update first: %w", inRelease, err)
+		}
+		components := make([]string, len(s.sources))
+		for i, source := range s.sources {
+			components[i] = source.Component
+		}
+		ret.Sources = append(ret.Sources, lock.Source{URL: s.url, Suite: s.name, Components: components, InRelease: sum})
+		digests[s.url+" "+s.name] = sum
+	}
+
+	// selected points into a.packages, which a.sources runs parallel to
+	index := make(map[*pkg.Package]int, len(a.packages))
+	for i := range a.packages {
+		index[&a.packages[i]] = i
+	}
+	for _, p := range selected {
+		if p.Filename == "" || p.SHA256 == "" {
+			return nil, fmt.Errorf("package %s:%s has no Filename or SHA256", p.Name, p.Arch)
+		}
+		u, err := url.JoinPath(p.BaseURL, p.Filename)
+		if err != nil {
+			return nil, err
+		}
+		source := a.sources[index[p]]
+		ret.Packages = append(ret.Packages, lock.Package{
+			Name:      p.Name,
+			Version:   p.Version,
+			Arch:      p.Arch,
+			Filename:  p.Filename,
+			Size:      p.Size,
+			SHA256:    p.SHA256,
+			URL:       u,
+			InRelease: digests[source.URL+" "+source.Suite],
+		})
+	}
+	sort.Slice(ret.Packages, func(i, j int) bool {
+		if ret.Packages[i].Name != ret.Packages[j].Name {
+			return ret.Packages[i].Name < ret.Packages[j].Name
+		}
+		return ret.Packages[i].Arch < ret.Packages[j].Arch
+	})
+	if err := ret.Validate(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// VerifyLock checks the lockfile l against the cached indices, and returns
+// its problems:
+//
+//   - locked packages no longer available, or whose files changed;
+//   - packages of the manifest missing from the lockfile;
+//   - dependencies of locked packages the lockfile doesn't satisfy;
+//   - locked packages nothing in the manifest depends on.
+//
+// A lockfile without problems still resolves to the same files, even if the
+// InRelease files changed since.
+func (c *Client) VerifyLock(l *lock.Lockfile) ([]string, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	available, err := c.Packages()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[string][]*pkg.Package{}
+	for i := range available {
+		p := &available[i]
+		key := p.Name + ":" + p.Arch + "=" + p.Version
+		byVersion[key] = append(byVersion[key], p)
+	}
+
+	var (
+		problems []string
+		locked   = make([]pkg.Package, 0, len(l.Packages))
+	)
+	for _, lp := range l.Packages {
+		key := lp.Name + ":" + lp.Arch + "=" + lp.Version
+		var found *pkg.Package
+		for _, p := range byVersion[key] {
+			if p.Filename == lp.Filename && p.Size == lp.Size && p.SHA256 == lp.SHA256 {
+				found = p
+				break
+			}
+		}
+		switch {
+		case found != nil:
+			locked = append(locked, *found)
+		case len(byVersion[key]) > 0:
+			problems = append(problems, fmt.Sprintf("%s: file changed, expect %s with SHA256 %s", key, lp.Filename, lp.SHA256))
+			locked = append(locked, *byVersion[key][0])
+		default:
+			problems = append(problems, fmt.Sprintf("%s: no longer available", key))
+			// Keep the package, without its dependencies, to report it once
+			locked = append(locked, pkg.Package{Metadata: common.Metadata{Name: lp.Name, Version: lp.Version}, Arch: lp.Arch})
+		}
+	}
+
+	idx := pkg.NewIndex(locked)
+	var seeds []*pkg.Package
+	for _, name := range l.Manifest.Packages {
+		p, err := c.lookup(idx, name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: not locked", name))
+			continue
+		}
+		seeds = append(seeds, p)
+	}
+	selected, _ := closure(idx, seeds, c.archs, func(p *pkg.Package, group []pkg.Relation) error {
+		problems = append(problems, fmt.Sprintf("%s:%s=%s: depends on %s, which is not locked", p.Name, p.Arch, p.Version, alternatives(group)))
+		return nil
+	})
+	for _, p := range locked {
+		if selected[p.Name+":"+p.Arch] == nil {
+			problems = append(problems, fmt.Sprintf("%s:%s=%s: not required by the manifest", p.Name, p.Arch, p.Version))
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,118 @@
+package goapt
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/lock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLock(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := &lock.Manifest{
+		Sources:       []string{"deb " + repo.url + " focal main"},
+		Architectures: []string{"amd64", "i386"},
+		Packages:      []string{"app"},
+	}
+
+	if _, err := c.Lock(m); err == nil {
+		t.Errorf("expect err before update; got nil")
+	}
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l, err := c.Lock(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inRelease, err := sha256File(filepath.Join(repo.dir, "dists/focal/InRelease"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := []lock.Source{{URL: repo.url, Suite: "focal", Components: []string{"main"}, InRelease: inRelease}}; !cmp.Equal(expect, l.Sources) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, l.Sources))
+	}
+	var expect []lock.Package
+	for _, p := range []struct{ name, version, arch string }{
+		{"app", "1.0", "amd64"},
+		{"libfoo", "1.1", "amd64"},
+		{"make", "4.2", "i386"},
+	} {
+		filename := fmt.Sprintf("pool/main/%s_%s_%s.deb", p.name, p.version, p.arch)
+		content := "deb " + filepath.Base(filename)
+		expect = append(expect, lock.Package{
+			Name:      p.name,
+			Version:   p.version,
+			Arch:      p.arch,
+			Filename:  filename,
+			Size:      len(content),
+			SHA256:    fmt.Sprintf("%x", sha256Sum(content)),
+			URL:       repo.url + filename,
+			InRelease: inRelease,
+		})
+	}
+	if !cmp.Equal(expect, l.Packages) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, l.Packages))
+	}
+
+	// A round trip through the lockfile verifies
+	path := filepath.Join(t.TempDir(), "goapt.lock")
+	if err := l.Write(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l, err = lock.Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	problems, err := c.VerifyLock(l)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("expect no problems; got %v", problems)
+	}
+
+	// Broken lockfiles
+	broken := *l
+	broken.Packages = []lock.Package{l.Packages[0], l.Packages[2]}
+	broken.Packages[1].SHA256 = inRelease
+	broken.Manifest.Packages = []string{"app", "libfoo=9"}
+	problems, err = c.VerifyLock(&broken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectProblems := []string{
+		"make:i386=4.2: file changed, expect pool/main/make_4.2_i386.deb with SHA256 " + inRelease,
+		"libfoo=9: not locked",
+		"app:amd64=1.0: depends on libfoo (>= 1.0), which is not locked",
+	}
+	if !cmp.Equal(expectProblems, problems) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expectProblems, problems))
+	}
+
+	broken.Packages = l.Packages
+	broken.Manifest.Packages = []string{"libfoo"}
+	problems, err = c.VerifyLock(&broken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectProblems = []string{
+		"app:amd64=1.0: not required by the manifest",
+		"make:i386=4.2: not required by the manifest",
+	}
+	if !cmp.Equal(expectProblems, problems) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expectProblems, problems))
+	}
+}
@@ -0,0 +1,410 @@
+package goapt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/anfernee/goapt/pkg/download"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// indexArch matches the architecture of an architecture specific index,
+// e.g. "main/binary-amd64/Packages.gz" or "Contents-udeb-amd64.gz".
+var indexArch = regexp.MustCompile(`(?:^|/)(?:binary-|Contents-(?:udeb-)?|Commands-|Components-)([a-z0-9]+(?:-[a-z0-9]+)?)(?:[./]|$)`)
+
+// MirrorFilter selects what Mirror downloads. Empty fields select
+// everything.
+type MirrorFilter struct {
+	// Components are the components to mirror, among the components of the
+	// sources.
+	Components []string
+	// Names selects packages by name.
+	Names *regexp.Regexp
+	// Priorities selects packages by priority, e.g. "required".
+	Priorities []string
+	// Roots selects the dependency closure of packages: the named packages,
+	// the packages selected by Names and Priorities if any is set, and their
+	// Depends and Pre-Depends, recursively. A root may be qualified with an
+	// architecture and a version like the names of Resolve.
+	Roots []string
+}
+
+// narrows returns whether Names or Priorities are set.
+func (f *MirrorFilter) narrows() bool {
+	return f.Names != nil || len(f.Priorities) > 0
+}
+
+// match returns whether p is selected by Names and Priorities.
+func (f *MirrorFilter) match(p *pkg.Package) bool {
+	if f.Names != nil && !f.Names.MatchString(p.Name) {
+		return false
+	}
+	if len(f.Priorities) == 0 {
+		return true
+	}
+	for _, priority := range f.Priorities {
+		if p.Priority == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// Mirror downloads the sources into dir with the upstream layout, e.g.
+// dir/archive.ubuntu.com/ubuntu/dists/focal/InRelease: the InRelease file
+// of every suite, the indices it lists for the selected components and the
+// client's architectures, and the pool files of the selected packages.
+// Every file is verified against the signed InRelease file, and files
+// already mirrored are kept. Indices listed but not published upstream are
+// ignored. It returns the result of every file fetched, and an error if
+// any of them failed.
+func (c *Client) Mirror(ctx context.Context, dir string, filter *MirrorFilter) ([]Fetch, error) {
+	fetches, _, err := c.mirror(ctx, dir, filter)
+	return fetches, err
+}
+
+// mirror mirrors the sources like Mirror, and also returns the paths of the
+// mirrored files, relative to dir, that are verified as up to date.
+func (c *Client) mirror(ctx context.Context, dir string, filter *MirrorFilter) ([]Fetch, []string, error) {
+	if filter == nil {
+		filter = &MirrorFilter{}
+	}
+	list, err := c.Sources()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		ret      []Fetch
+		failed   int
+		universe []pkg.Package
+		mirrored []string
+	)
+	record := func(fetches []Fetch, paths []string) {
+		for _, path := range paths {
+			rel, _ := filepath.Rel(dir, path)
+			mirrored = append(mirrored, filepath.ToSlash(rel))
+		}
+		for _, f := range fetches {
+			c.logger.Printf("%s %s", f.Status, f.URL)
+			if f.Status == FetchErr {
+				failed++
+			}
+			ret = append(ret, f)
+		}
+	}
+
+	for _, s := range suites(list) {
+		if err := ctx.Err(); err != nil {
+			return ret, mirrored, err
+		}
+		pkgs, fetches, paths := c.mirrorSuite(ctx, dir, s, filter)
+		record(fetches, paths)
+		universe = append(universe, pkgs...)
+	}
+
+	selected, err := c.mirrorSelect(universe, filter)
+	if err != nil {
+		return ret, mirrored, err
+	}
+	record(c.mirrorPool(ctx, dir, selected))
+
+	if failed > 0 {
+		return ret, mirrored, fmt.Errorf("%d file(s) failed to download", failed)
+	}
+	return ret, mirrored, nil
+}
+
+// mirrorDir returns the directory mirroring the repository at u.
+func mirrorDir(dir, u string) string {
+	return filepath.Join(dir, filepath.FromSlash(hostPath(strings.TrimSuffix(u, "/"))))
+}
+
+// mirrorSuite mirrors the InRelease file and the indices of a suite, and
+// returns the packages of its mirrored Packages indices, and the paths of
+// the mirrored files.
+func (c *Client) mirrorSuite(ctx context.Context, dir string, s *suite, filter *MirrorFilter) ([]pkg.Package, []Fetch, []string) {
+	base := strings.TrimSuffix(s.url, "/")
+	result := Fetch{
+		URL:         s.sources[0].DirectorySignedURL(),
+		Description: base + " " + s.name + " InRelease",
+	}
+	signed, r, err := c.fetchRelease(ctx, s)
+	if err != nil {
+		result.Status, result.Err = FetchErr, err
+		return nil, []Fetch{result}, nil
+	}
+
+	suiteDir := filepath.Join(mirrorDir(dir, s.url), "dists", s.name)
+	inRelease := filepath.Join(suiteDir, "InRelease")
+	if cached, err := os.ReadFile(inRelease); err == nil && string(cached) == string(signed) {
+		result.Status = FetchHit
+	} else {
+		result.Status, result.Size = FetchGet, int64(len(signed))
+	}
+	ret := []Fetch{result}
+	var paths []string
+
+	components := c.mirrorComponents(s, filter)
+	var names []string
+	for name := range r.Files {
+		if c.mirrorsIndex(name, components) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := r.Files[name]
+		local := filepath.Join(suiteDir, filepath.FromSlash(name))
+		if checkFile(local, file) == nil {
+			paths = append(paths, local)
+			continue
+		}
+
+		u, _ := url.JoinPath(s.url, "dists", s.name, name)
+		f := Fetch{URL: u, Description: base + " " + s.name + "/" + name}
+		switch err := c.fetchVerified(ctx, u, file, local); {
+		case errors.Is(err, fs.ErrNotExist):
+			// Some indices are listed but only published compressed
+			f.Status = FetchIgn
+		case err != nil:
+			f.Status, f.Err = FetchErr, err
+		default:
+			f.Status, f.Size = FetchGet, int64(file.Size)
+			paths = append(paths, local)
+		}
+		ret = append(ret, f)
+	}
+
+	if result.Status == FetchGet {
+		if err := os.MkdirAll(suiteDir, 0755); err != nil {
+			ret[0].Status, ret[0].Err = FetchErr, err
+		} else if err := writeFile(inRelease, strings.NewReader(string(signed))); err != nil {
+			ret[0].Status, ret[0].Err = FetchErr, err
+		}
+	}
+	if ret[0].Status != FetchErr {
+		paths = append(paths, inRelease)
+	}
+
+	var pkgs []pkg.Package
+	for _, component := range components {
+		for _, arch := range c.indexArchs() {
+			name := component + "/binary-" + arch + "/Packages"
+			p, err := loadMirrored(suiteDir, name, r)
+			if err != nil {
+				u, _ := url.JoinPath(s.url, "dists", s.name, name)
+				ret = append(ret, Fetch{Status: FetchErr, URL: u, Description: base + " " + s.name + "/" + name, Err: err})
+				continue
+			}
+			for i := range p {
+				p[i].BaseURL = s.url
+			}
+			pkgs = append(pkgs, p...)
+		}
+	}
+	return pkgs, ret, paths
+}
+
+// mirrorComponents returns the components of the sources of a suite
+// selected by filter.
+func (c *Client) mirrorComponents(s *suite, filter *MirrorFilter) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, source := range s.sources {
+		if seen[source.Component] {
+			continue
+		}
+		seen[source.Component] = true
+		if len(filter.Components) == 0 || contains(filter.Components, source.Component) {
+			ret = append(ret, source.Component)
+		}
+	}
+	return ret
+}
+
+// mirrorsIndex returns whether an index listed in a Release file belongs to
+// the components and to the client's architectures.
+func (c *Client) mirrorsIndex(name string, components []string) bool {
+	if component, _, ok := strings.Cut(name, "/"); ok && !contains(components, component) {
+		return false
+	}
+	if m := indexArch.FindStringSubmatch(name); m != nil && m[1] != "source" {
+		return m[1] == pkg.ArchAll || c.archs.Has(m[1])
+	}
+	return true
+}
+
+// loadMirrored loads the packages of a mirrored Packages index, in any of
+// the compressions listed in r, that matches its hash. A missing index has
+// no packages.
+func loadMirrored(suiteDir, name string, r *release.Release) ([]pkg.Package, error) {
+	for _, ext := range indexCompressions {
+		file, ok := r.Files[name+ext]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(suiteDir, filepath.FromSlash(name+ext))
+		if checkFile(path, file) != nil {
+			continue
+		}
+		return pkg.Load(path)
+	}
+	return nil, nil
+}
+
+// mirrorSelect returns the packages selected by filter.
+func (c *Client) mirrorSelect(universe []pkg.Package, filter *MirrorFilter) ([]*pkg.Package, error) {
+	var seeds []*pkg.Package
+	for i := range universe {
+		p := &universe[i]
+		if (len(filter.Roots) == 0 || filter.narrows()) && filter.match(p) {
+			seeds = append(seeds, p)
+		}
+	}
+	if len(filter.Roots) == 0 {
+		return seeds, nil
+	}
+
+	idx := pkg.NewIndex(universe)
+	for _, name := range filter.Roots {
+		p, err := c.lookup(idx, name)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, p)
+	}
+	selected, err := closure(idx, seeds, c.archs, func(p *pkg.Package, group []pkg.Relation) error {
+		c.logger.Printf("%s:%s depends on %s, which is not mirrored", p.Name, p.Arch, alternatives(group))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The closure keeps a version per package, the seeds keep every version
+	ret := seeds
+	seen := map[*pkg.Package]bool{}
+	for _, p := range seeds {
+		seen[p] = true
+	}
+	for _, p := range selected {
+		if !seen[p] {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// mirrorPool downloads the pool files of pkgs that are not mirrored yet,
+// and verifies them against the hashes of their Packages index. It returns
+// the paths of the mirrored pool files.
+func (c *Client) mirrorPool(ctx context.Context, dir string, pkgs []*pkg.Package) ([]Fetch, []string) {
+	type poolFile struct {
+		p     *pkg.Package
+		file  *release.File
+		local string
+		url   string
+	}
+
+	var (
+		files []*poolFile
+		paths []string
+		seen  = map[string]bool{}
+	)
+	for _, p := range pkgs {
+		u, err := url.JoinPath(p.BaseURL, p.Filename)
+		if err != nil || p.Filename == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+
+		f := &poolFile{
+			p:     p,
+			file:  &release.File{Name: p.Filename, Size: p.Size, MD5: p.MD5, SHA256: p.SHA256},
+			local: filepath.Join(mirrorDir(dir, p.BaseURL), filepath.FromSlash(p.Filename)),
+			url:   u,
+		}
+		if checkFile(f.local, f.file) == nil {
+			paths = append(paths, f.local)
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].url < files[j].url
+	})
+
+	var (
+		reqs     []download.Request
+		mu       sync.Mutex
+		failures = map[string]error{}
+	)
+	for _, f := range files {
+		reqs = append(reqs, download.Request{URL: f.url, Path: f.local})
+	}
+	m := c.downloader(func(e download.Event) {
+		if e.Type == download.EventFailed {
+			mu.Lock()
+			failures[e.URL] = e.Err
+			mu.Unlock()
+		}
+	})
+	m.Download(ctx, reqs)
+
+	var ret []Fetch
+	for _, f := range files {
+		result := Fetch{
+			URL:         f.url,
+			Description: fmt.Sprintf("%s %s %s %s", strings.TrimSuffix(f.p.BaseURL, "/"), f.p.Name, f.p.Arch, f.p.Version),
+		}
+		err := failures[f.url]
+		if err == nil {
+			if err = checkFile(f.local, f.file); err != nil {
+				os.Remove(f.local)
+			}
+		}
+		if err != nil {
+			result.Status, result.Err = FetchErr, err
+		} else {
+			result.Status, result.Size = FetchGet, int64(f.file.Size)
+			paths = append(paths, f.local)
+		}
+		ret = append(ret, result)
+	}
+	return ret, paths
+}
+
+// fetchVerified downloads u into path, checking it against file.
+func (c *Client) fetchVerified(ctx context.Context, u string, file *release.File, path string) error {
+	rc, err := c.fetcher.OpenContext(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFile(path, newCheckedReader(rc, file))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
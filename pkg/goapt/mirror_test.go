@@ -0,0 +1,172 @@
+package goapt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMirror(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	statuses := func(fetches []Fetch) []string {
+		var ret []string
+		for _, f := range fetches {
+			ret = append(ret, string(f.Status)+" "+f.Description[len(repo.url):])
+		}
+		return ret
+	}
+
+	dir := t.TempDir()
+	fetches, err := c.Mirror(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := []string{
+		"Get focal InRelease",
+		"Get focal/Contents-amd64.gz",
+		"Ign focal/main/binary-all/Packages",
+		"Get focal/main/binary-all/Packages.gz",
+		"Ign focal/main/binary-amd64/Packages",
+		"Get focal/main/binary-amd64/Packages.gz",
+		"Get focal/main/i18n/Translation-en",
+		"Get app-data all 1.0",
+		"Get app amd64 1.0",
+		"Get libfoo amd64 1.0",
+		"Get libfoo amd64 1.1",
+	}
+	if got := statuses(fetches); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	// The mirror has the upstream layout
+	mirror := mirrorDir(dir, repo.url)
+	for _, path := range []string{"dists/focal/InRelease", "dists/focal/main/binary-amd64/Packages.gz", "pool/main/app_1.0_amd64.deb"} {
+		want, err := os.ReadFile(filepath.Join(repo.dir, path))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join(mirror, path))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: expect the upstream file", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "dists/focal/main/binary-i386/Packages.gz")); !os.IsNotExist(err) {
+		t.Errorf("expect the i386 index to be filtered out; got %v", err)
+	}
+
+	fetches, err = c.Mirror(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect = []string{
+		"Hit focal InRelease",
+		"Ign focal/main/binary-all/Packages",
+		"Ign focal/main/binary-amd64/Packages",
+	}
+	if got := statuses(fetches); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	// A tampered pool file fails to verify and isn't kept
+	writeTestFile(t, filepath.Join(repo.dir, "pool/main/make_4.2_i386.deb"), "tampered")
+	c, err = New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fetches, err = c.Mirror(context.Background(), dir, &MirrorFilter{Names: regexp.MustCompile("^make$")})
+	if err == nil {
+		t.Errorf("expect err for a tampered pool file; got nil")
+	}
+	if got := statuses(fetches); got[len(got)-1] != "Err make i386 4.2" {
+		t.Errorf("expect Err for the tampered pool file; got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "pool/main/make_4.2_i386.deb")); !os.IsNotExist(err) {
+		t.Errorf("expect the tampered pool file to be removed; got %v", err)
+	}
+}
+
+func TestMirrorFilter(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		filter *MirrorFilter
+		expect []string
+	}{
+		{
+			desc:   "names",
+			filter: &MirrorFilter{Names: regexp.MustCompile("^lib")},
+			expect: []string{"libfoo_1.0_amd64.deb", "libfoo_1.1_amd64.deb"},
+		},
+		{
+			desc:   "priorities",
+			filter: &MirrorFilter{Priorities: []string{"required"}},
+		},
+		{
+			desc:   "roots",
+			filter: &MirrorFilter{Roots: []string{"app"}},
+			expect: []string{"app_1.0_amd64.deb", "libfoo_1.1_amd64.deb", "make_4.2_i386.deb"},
+		},
+		{
+			desc:   "roots and names",
+			filter: &MirrorFilter{Roots: []string{"make:i386"}, Names: regexp.MustCompile("^libfoo$")},
+			expect: []string{"libfoo_1.0_amd64.deb", "libfoo_1.1_amd64.deb", "make_4.2_i386.deb"},
+		},
+		{
+			desc:   "components",
+			filter: &MirrorFilter{Components: []string{"universe"}},
+		},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		if _, err := c.Mirror(context.Background(), dir, test.filter); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.desc, err)
+		}
+
+		var got []string
+		entries, _ := os.ReadDir(filepath.Join(mirrorDir(dir, repo.url), "pool/main"))
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				got = append(got, entry.Name())
+			}
+		}
+		sort.Strings(got)
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.desc, cmp.Diff(test.expect, got))
+		}
+	}
+
+	if _, err := c.Mirror(context.Background(), t.TempDir(), &MirrorFilter{Roots: []string{"missing"}}); err == nil {
+		t.Errorf("expect err for a missing root; got nil")
+	}
+}
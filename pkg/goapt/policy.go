@@ -0,0 +1,41 @@
+package goapt
+
+import (
+	"net/url"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/preferences"
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// newOrigin returns the origin of the packages of the arch index of source,
+// from the release r of its suite, nil if it is not cached.
+func newOrigin(r *release.Release, source pkg.DebianSource, arch string) preferences.Origin {
+	var site string
+	if u, err := url.Parse(source.URL); err == nil {
+		site = u.Hostname()
+	}
+	if r == nil {
+		return preferences.Origin{Component: source.Component, Arch: arch, Site: site}
+	}
+	return preferences.NewOrigin(r, source.Component, arch, site)
+}
+
+// index returns an index of the available packages, picking the candidates
+// with the client's preferences like apt.
+func (c *Client) index(a *available) *pkg.Index {
+	idx := pkg.NewIndex(a.packages)
+	idx.SetPolicy(c.prefs.Policy(a.originsOf(), nil))
+	return idx
+}
+
+// originsOf returns a function returning the origin of the packages of a.
+func (a *available) originsOf() func(p *pkg.Package) []preferences.Origin {
+	origins := make(map[*pkg.Package]preferences.Origin, len(a.packages))
+	for i := range a.packages {
+		origins[&a.packages[i]] = a.origins[i]
+	}
+	return func(p *pkg.Package) []preferences.Origin {
+		return []preferences.Origin{origins[p]}
+	}
+}
@@ -0,0 +1,162 @@
+package goapt
+
+import (
+	"fmt"
+	"strings"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// Resolve returns the packages to install for names, and their Depends and
+// Pre-Depends, from the cached indices. A name may be qualified with an
+// architecture, "name:arch", and a version, "name=version"; otherwise the
+// candidate for the native architecture is picked, the highest version
+// unless pinned by the client's preferences. Packages are sorted by name and
+// architecture.
+func (c *Client) Resolve(names []string) ([]pkg.Package, error) {
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := c.resolve(c.index(a), names)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]pkg.Package, 0, len(selected))
+	for _, p := range selected {
+		ret = append(ret, *p)
+	}
+	sortPackages(ret)
+	return ret, nil
+}
+
+// Candidate returns the version of a package apt would install on arch, the
+// native architecture if empty, from the cached indices: the highest version
+// unless pinned by the client's preferences. It returns nil if there is no
+// candidate.
+func (c *Client) Candidate(name, arch string) (*pkg.Package, error) {
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+	if arch == "" {
+		arch = c.archs.Native()
+	}
+	return c.index(a).Candidate(name, arch), nil
+}
+
+// resolve returns the packages of idx to install for names, and their
+// dependencies, like Resolve.
+func (c *Client) resolve(idx *pkg.Index, names []string) ([]*pkg.Package, error) {
+	var seeds []*pkg.Package
+	for _, name := range names {
+		p, err := c.lookup(idx, name)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, p)
+	}
+
+	selected, err := closure(idx, seeds, c.archs, func(p *pkg.Package, group []pkg.Relation) error {
+		return fmt.Errorf("%s:%s depends on %s, which is not installable", p.Name, p.Arch, alternatives(group))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*pkg.Package, 0, len(selected))
+	for _, p := range selected {
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// closure returns seeds and, recursively, the packages satisfying their
+// Pre-Depends and Depends, keyed by "name:arch". The candidate of the first
+// installable alternative is picked. A dependency nothing satisfies is passed
+// to missing, which may abort with an error or skip it.
+func closure(idx *pkg.Index, seeds []*pkg.Package, archs pkg.Architectures, missing func(p *pkg.Package, group []pkg.Relation) error) (map[string]*pkg.Package, error) {
+	var (
+		selected = map[string]*pkg.Package{}
+		queue    []*pkg.Package
+	)
+	add := func(p *pkg.Package) {
+		key := p.Name + ":" + p.Arch
+		if selected[key] == nil {
+			selected[key] = p
+			queue = append(queue, p)
+		}
+	}
+	for _, p := range seeds {
+		add(p)
+	}
+
+	native := archs.Native()
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		for _, group := range append(append(pkg.Relations{}, p.PreDepends...), p.Depends...) {
+			if satisfied(selected, group, p.Arch, native) {
+				continue
+			}
+			var found *pkg.Package
+			for _, r := range group {
+				if cands := idx.Satisfy(r, p.Arch, archs); len(cands) > 0 {
+					found = cands[0]
+					break
+				}
+			}
+			if found == nil {
+				if err := missing(p, group); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			add(found)
+		}
+	}
+	return selected, nil
+}
+
+// lookup finds the package of a "name[:arch][=version]" argument.
+func (c *Client) lookup(idx *pkg.Index, arg string) (*pkg.Package, error) {
+	name, ver, _ := strings.Cut(arg, "=")
+	name, arch, _ := strings.Cut(name, ":")
+	if arch == "" {
+		arch = c.archs.Native()
+	}
+
+	if ver == "" {
+		if p := idx.Candidate(name, arch); p != nil {
+			return p, nil
+		}
+		return nil, fmt.Errorf("unable to locate package %s", arg)
+	}
+	for _, p := range idx.Get(name) {
+		if p.Version == ver && p.InstallableOn(arch) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("version %s of package %s is not found", ver, name)
+}
+
+func satisfied(selected map[string]*pkg.Package, group []pkg.Relation, arch, native string) bool {
+	for _, r := range group {
+		for _, p := range selected {
+			if p.SatisfiesOn(r, arch, native) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func alternatives(group []pkg.Relation) string {
+	var ret []string
+	for _, r := range group {
+		ret = append(ret, r.String())
+	}
+	return strings.Join(ret, " | ")
+}
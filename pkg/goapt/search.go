@@ -0,0 +1,104 @@
+package goapt
+
+import (
+	"regexp"
+	"sort"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// Filter selects packages. Empty fields match every package.
+type Filter struct {
+	// Arch is the architecture of the packages. Architecture independent
+	// packages always match.
+	Arch string
+	// Source is the name of the source package of the packages.
+	Source string
+}
+
+// Match returns whether the filter selects p.
+func (f *Filter) Match(p *pkg.Package) bool {
+	if f == nil {
+		return true
+	}
+	if f.Arch != "" && !p.InstallableOn(f.Arch) {
+		return false
+	}
+	return f.Source == "" || p.SourceName() == f.Source
+}
+
+// Search returns the packages of the cached indices selected by filter whose
+// name or description matches re, sorted by name, architecture and version,
+// the candidate of each package first.
+func (c *Client) Search(re *regexp.Regexp, filter *Filter) ([]pkg.Package, error) {
+	return c.find(filter, func(p *pkg.Package) bool {
+		return re.MatchString(p.Name) || re.MatchString(p.Description)
+	})
+}
+
+// Show returns the versions of a package of the cached indices selected by
+// filter, or only the given version if not empty, sorted by architecture
+// and version, the candidate first like apt show.
+func (c *Client) Show(name, ver string, filter *Filter) ([]pkg.Package, error) {
+	return c.find(filter, func(p *pkg.Package) bool {
+		return p.Name == name && (ver == "" || p.Version == ver)
+	})
+}
+
+func (c *Client) find(filter *Filter, match func(*pkg.Package) bool) ([]pkg.Package, error) {
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []pkg.Package
+	seen := map[string]bool{}
+	for i := range a.packages {
+		p := &a.packages[i]
+		// The same package may be published by several suites
+		key := p.Name + ":" + p.Arch + "=" + p.Version
+		if seen[key] || !filter.Match(p) || !match(p) {
+			continue
+		}
+		seen[key] = true
+		ret = append(ret, *p)
+	}
+
+	var (
+		idx        = c.index(a)
+		candidates = map[string]string{}
+	)
+	for _, p := range ret {
+		key := p.Name + ":" + p.Arch
+		if _, ok := candidates[key]; !ok {
+			if cand := idx.Candidate(p.Name, p.Arch); cand != nil {
+				candidates[key] = cand.Version
+			}
+		}
+	}
+	sortPackages(ret)
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Name != b.Name || a.Arch != b.Arch {
+			return a.Name < b.Name || a.Name == b.Name && a.Arch < b.Arch
+		}
+		return a.Version == candidates[a.Name+":"+a.Arch] && b.Version != candidates[b.Name+":"+b.Arch]
+	})
+	return ret, nil
+}
+
+// sortPackages sorts packages by name and architecture, highest versions
+// first.
+func sortPackages(pkgs []pkg.Package) {
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := pkgs[i], pkgs[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return version.Compare(a.Version, b.Version) > 0
+	})
+}
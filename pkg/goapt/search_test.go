@@ -0,0 +1,69 @@
+package goapt
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSearchAndShow(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64", "i386"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := func(pkgs []pkg.Package) []string {
+		var ret []string
+		for _, p := range pkgs {
+			ret = append(ret, p.Name+":"+p.Arch+"="+p.Version)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		desc   string
+		search string
+		filter *Filter
+		expect []string
+	}{
+		{desc: "name", search: "^make$", expect: []string{"make:i386=4.2"}},
+		{desc: "description", search: "foo library", expect: []string{"app:amd64=1.0", "libfoo:amd64=1.1", "libfoo:amd64=1.0"}},
+		{desc: "translated description", search: "does foo", expect: []string{"libfoo:amd64=1.1", "libfoo:amd64=1.0"}},
+		{desc: "arch", search: ".", filter: &Filter{Arch: "i386"}, expect: []string{"app-data:all=1.0", "make:i386=4.2"}},
+		{desc: "source", search: ".", filter: &Filter{Source: "foo"}, expect: []string{"libfoo:amd64=1.1", "libfoo:amd64=1.0"}},
+	}
+	for _, test := range tests {
+		pkgs, err := c.Search(regexp.MustCompile(test.search), test.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.desc, err)
+		}
+		if got := keys(pkgs); !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.desc, cmp.Diff(test.expect, got))
+		}
+	}
+
+	pkgs, err := c.Show("libfoo", "1.0", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Control.Get("Filename") != "pool/main/libfoo_1.0_amd64.deb" {
+		t.Errorf("expect the stanza of libfoo 1.0; got %v", keys(pkgs))
+	}
+	if got := pkgs[0].Summary(); got != "foo library" {
+		t.Errorf("expect summary %q; got %q", "foo library", got)
+	}
+	if got, expect := pkgs[0].Control.Get("Description"), "foo library\n The foo library does foo."; got != expect {
+		t.Errorf("expect description %q; got %q", expect, got)
+	}
+}
@@ -0,0 +1,61 @@
+package goapt
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/snapshot"
+)
+
+// Snapshot mirrors the sources like Mirror into the mirror directory of
+// store, and saves the mirrored files, the InRelease files, the indices and
+// the pool files, as the immutable snapshot name. Published, the snapshot
+// has the layout of the mirror, e.g.
+// archive.ubuntu.com/ubuntu/dists/focal/InRelease. No snapshot is saved if
+// any file failed to download.
+func (c *Client) Snapshot(ctx context.Context, store *snapshot.Store, name string, filter *MirrorFilter) (*snapshot.Snapshot, []Fetch, error) {
+	// Check the name before mirroring, which may take long
+	if !snapshot.ValidName(name) {
+		return nil, nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+	if _, err := store.Get(name); err == nil {
+		return nil, nil, fmt.Errorf("snapshot %s already exists", name)
+	}
+	list, err := c.Sources()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir := filepath.Join(store.Dir(), "mirror")
+	fetches, paths, err := c.mirror(ctx, dir, filter)
+	if err != nil {
+		return nil, fetches, err
+	}
+
+	snap := &snapshot.Snapshot{
+		Name:          name,
+		Created:       time.Now().UTC(),
+		Architectures: c.archs,
+	}
+	for _, s := range suites(list) {
+		components := make([]string, len(s.sources))
+		for i, source := range s.sources {
+			components[i] = source.Component
+		}
+		snap.Sources = append(snap.Sources, "deb "+s.url+" "+s.name+" "+strings.Join(components, " "))
+	}
+	for _, path := range paths {
+		f, err := store.Add(dir, path)
+		if err != nil {
+			return nil, fetches, err
+		}
+		snap.Files = append(snap.Files, f)
+	}
+	if err := store.Save(snap); err != nil {
+		return nil, fetches, err
+	}
+	return snap, fetches, nil
+}
@@ -0,0 +1,102 @@
+package goapt
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/server"
+	"github.com/anfernee/goapt/pkg/snapshot"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot(t *testing.T) {
+	repo := newTestRepo(t)
+	c, err := New(&Options{
+		Root:          newTestRoot(t, repo.url),
+		Architectures: []string{"amd64"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store, err := snapshot.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snap, _, err := c.Snapshot(context.Background(), store, "app", &MirrorFilter{Names: regexp.MustCompile("^app$")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prefix := strings.TrimSuffix(hostPath(strings.TrimSuffix(repo.url, "/")), "/") + "/"
+	var got []string
+	for _, f := range snap.Files {
+		got = append(got, strings.TrimPrefix(f.Path, prefix))
+	}
+	expect := []string{
+		"dists/focal/Contents-amd64.gz",
+		"dists/focal/InRelease",
+		"dists/focal/main/binary-all/Packages.gz",
+		"dists/focal/main/binary-amd64/Packages.gz",
+		"dists/focal/main/i18n/Translation-en",
+		"pool/main/app_1.0_amd64.deb",
+	}
+	if !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if expect := []string{"deb " + repo.url + " focal main"}; !cmp.Equal(expect, snap.Sources) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, snap.Sources))
+	}
+
+	if _, _, err := c.Snapshot(context.Background(), store, "app", nil); err == nil {
+		t.Errorf("expect err for an existing snapshot; got nil")
+	}
+	if _, fetches, err := c.Snapshot(context.Background(), store, "../app", nil); err == nil || len(fetches) > 0 {
+		t.Errorf("expect err for an invalid name before mirroring; got %v, %d fetches", err, len(fetches))
+	}
+	if _, _, err := c.Snapshot(context.Background(), store, "all", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The published snapshot is a repository of exactly its packages.
+	dir := t.TempDir()
+	if _, err := store.Publish("app", dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv := httptest.NewServer(server.New(filepath.Join(dir, filepath.FromSlash(prefix)), nil))
+	t.Cleanup(srv.Close)
+
+	published, err := New(&Options{
+		Root:          newTestRoot(t, srv.URL+"/"),
+		Architectures: []string{"amd64"},
+		KeyRing:       repo.keyRing,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := published.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pkgs, err := published.Packages()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var app []pkg.Package
+	for i := range pkgs {
+		if pkgs[i].Name == "app" {
+			app = append(app, pkgs[i])
+		}
+	}
+	paths, err := published.Download(context.Background(), app, t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 1 {
+		t.Errorf("expect the app package downloaded; got %v", paths)
+	}
+}
@@ -0,0 +1,486 @@
+package goapt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/anfernee/goapt/pkg/download"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/preferences"
+	"github.com/anfernee/goapt/pkg/release"
+)
+
+// indexCompressions are the compressions of an index, by preference.
+var indexCompressions = []string{".xz", ".gz", ".bz2", ""}
+
+// ListName returns the name of the file caching url in the lists directory,
+// the way apt names them, e.g.
+// "archive.ubuntu.com_ubuntu_dists_focal_InRelease".
+func ListName(url string) string {
+	return strings.ReplaceAll(hostPath(url), "/", "_")
+}
+
+// hostPath returns url without its scheme and credentials, e.g.
+// "archive.ubuntu.com/ubuntu/dists/focal/InRelease".
+func hostPath(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.Index(url+"/", "/") {
+		url = url[i+1:]
+	}
+	return url
+}
+
+// suite is a suite of a repository, with the sources of its components.
+type suite struct {
+	url     string
+	name    string
+	sources []pkg.DebianSource
+}
+
+// suites groups the sources by suite, in the order of the source list.
+func suites(list pkg.DebianSourceList) []*suite {
+	var (
+		ret   []*suite
+		byKey = map[string]*suite{}
+	)
+	for _, source := range list {
+		key := source.URL + " " + source.Suite
+		s := byKey[key]
+		if s == nil {
+			s = &suite{url: source.URL, name: source.Suite}
+			byKey[key] = s
+			ret = append(ret, s)
+		}
+		s.sources = append(s.sources, source)
+	}
+	return ret
+}
+
+// FetchStatus is the outcome of fetching a file during Update, like the
+// first column of apt-get update.
+type FetchStatus string
+
+const (
+	// FetchHit means the cached file is up to date.
+	FetchHit FetchStatus = "Hit"
+	// FetchGet means the file was downloaded.
+	FetchGet FetchStatus = "Get"
+	// FetchIgn means the file is not published by the repository.
+	FetchIgn FetchStatus = "Ign"
+	// FetchErr means the file failed to download or to verify.
+	FetchErr FetchStatus = "Err"
+)
+
+// Fetch is the result of fetching a single file during Update.
+type Fetch struct {
+	Status FetchStatus
+	URL    string
+	// Description describes the file like apt, e.g.
+	// "http://archive.ubuntu.com/ubuntu focal/main amd64 Packages".
+	Description string
+	// Size is the number of bytes downloaded.
+	Size int64
+	Err  error
+}
+
+// index is an index file of a suite.
+type index struct {
+	url string
+	// name is the path of the index relative to the suite, as listed in
+	// the Release file.
+	name        string
+	description string
+	// optional indices that the Release file doesn't list are skipped
+	// rather than ignored.
+	optional bool
+}
+
+// Update fetches and verifies the InRelease file of every suite of the
+// sources, then downloads the Packages, Sources and Translation indices it
+// lists for the client's architectures, "all" and languages into the
+// cache directory, checking their hashes. Cached indices that are up to date are
+// kept. The suites and their indices are downloaded concurrently, like
+// apt. It returns the result of every file, in the order of the sources,
+// and an error if any of them failed.
+func (c *Client) Update(ctx context.Context) ([]Fetch, error) {
+	list, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var (
+		m       = c.downloader(nil)
+		all     = suites(list)
+		results = make([][]Fetch, len(all))
+		wg      sync.WaitGroup
+	)
+	for i, s := range all {
+		wg.Add(1)
+		go func(i int, s *suite) {
+			defer wg.Done()
+			results[i] = c.updateSuite(ctx, m, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var (
+		ret    []Fetch
+		failed int
+	)
+	for _, fetches := range results {
+		for _, f := range fetches {
+			c.logger.Printf("%s %s", f.Status, f.URL)
+			if f.Status == FetchErr {
+				failed++
+			}
+			ret = append(ret, f)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return ret, err
+	}
+	if failed > 0 {
+		return ret, fmt.Errorf("%d file(s) failed to download", failed)
+	}
+	return ret, nil
+}
+
+func (c *Client) updateSuite(ctx context.Context, m *download.Manager, s *suite) []Fetch {
+	inRelease := s.sources[0].DirectorySignedURL()
+	result := Fetch{
+		URL:         inRelease,
+		Description: strings.TrimSuffix(s.url, "/") + " " + s.name + " InRelease",
+	}
+	fail := func(err error) []Fetch {
+		result.Status, result.Err = FetchErr, err
+		return []Fetch{result}
+	}
+
+	signed, r, err := c.fetchRelease(ctx, s)
+	if err != nil {
+		return fail(err)
+	}
+
+	cachePath := filepath.Join(c.cacheDir, ListName(inRelease))
+	if cached, err := os.ReadFile(cachePath); err == nil && bytes.Equal(cached, signed) {
+		result.Status = FetchHit
+	} else {
+		result.Status, result.Size = FetchGet, int64(len(signed))
+	}
+
+	var (
+		indices = c.indices(s)
+		fetches = make([]*Fetch, len(indices))
+		wg      sync.WaitGroup
+	)
+	for i, idx := range indices {
+		wg.Add(1)
+		go func(i int, idx index) {
+			defer wg.Done()
+			fetches[i] = c.updateIndex(ctx, m, r, idx, result.Status == FetchHit)
+		}(i, idx)
+	}
+	wg.Wait()
+
+	ret := []Fetch{result}
+	for _, f := range fetches {
+		if f != nil {
+			ret = append(ret, *f)
+		}
+	}
+
+	if result.Status == FetchGet {
+		if err := writeFile(cachePath, bytes.NewReader(signed)); err != nil {
+			ret[0].Status, ret[0].Err = FetchErr, err
+		}
+	}
+	return ret
+}
+
+// fetchRelease fetches and verifies the InRelease file of a suite, and
+// returns it with the parsed release.
+func (c *Client) fetchRelease(ctx context.Context, s *suite) ([]byte, *release.Release, error) {
+	inRelease := s.sources[0].DirectorySignedURL()
+	signed, err := c.fetch(ctx, inRelease)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, err := release.VerifyCleartext(signed, c.keyRing)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to verify %s: %w", inRelease, err)
+	}
+	r, err := release.Parse(strings.NewReader(text))
+	if err != nil {
+		return nil, nil, err
+	}
+	return signed, r, nil
+}
+
+// indices returns the indices of the sources of a suite, once each even if
+// a source is repeated.
+func (c *Client) indices(s *suite) []index {
+	var ret []index
+	seen := map[string]bool{}
+	add := func(u, description string, optional bool) {
+		if seen[u] {
+			return
+		}
+		seen[u] = true
+		prefix := strings.TrimSuffix(s.url, "/") + "/dists/" + s.name + "/"
+		ret = append(ret, index{
+			url:         u,
+			name:        strings.TrimPrefix(u, prefix),
+			description: strings.TrimSuffix(s.url, "/") + " " + s.name + "/" + description,
+			optional:    optional,
+		})
+	}
+
+	for _, source := range s.sources {
+		switch source.Type {
+		case pkg.DebianSourceTypeDeb:
+			for _, arch := range c.indexArchs() {
+				// Like apt, binary-all is fetched if the suite has it
+				optional := arch == pkg.ArchAll && !contains(c.archs, pkg.ArchAll)
+				for _, u := range source.ResourceURLs(pkg.Architectures{arch}) {
+					add(u, source.Component+" "+arch+" Packages", optional)
+				}
+			}
+			for _, lang := range c.languages {
+				add(source.TranslationURL(lang), source.Component+" Translation-"+lang, false)
+			}
+		case pkg.DebianSourceTypeDebSrc:
+			add(source.ResourceURLFor(""), source.Component+" Sources", false)
+		}
+	}
+	return ret
+}
+
+// indexArchs returns the architectures of the Packages indices: the
+// client's architectures, and "all" like apt.
+func (c *Client) indexArchs() pkg.Architectures {
+	archs := append(pkg.Architectures{}, c.archs...)
+	if !contains(archs, pkg.ArchAll) {
+		archs = append(archs, pkg.ArchAll)
+	}
+	return archs
+}
+
+// updateIndex downloads an index with m, in the best compression listed
+// in r, checks its hash and caches it uncompressed. It returns nil if the
+// cached index is up to date: it matches the hash of the uncompressed index
+// in r, or, if r doesn't list it, the release is unchanged. Optional
+// indices that r doesn't list are skipped too.
+func (c *Client) updateIndex(ctx context.Context, m *download.Manager, r *release.Release, idx index, hit bool) *Fetch {
+	cachePath := filepath.Join(c.cacheDir, ListName(idx.url))
+	if file, ok := r.Files[idx.name]; ok {
+		if checkFile(cachePath, file) == nil {
+			return nil
+		}
+	} else if _, err := os.Stat(cachePath); err == nil && hit {
+		return nil
+	}
+
+	for _, ext := range indexCompressions {
+		file, ok := r.Files[idx.name+ext]
+		if !ok {
+			continue
+		}
+
+		result := &Fetch{URL: idx.url + ext, Description: idx.description}
+		// Like apt, downloads land in the partial directory until verified
+		partial := filepath.Join(c.cacheDir, "partial", ListName(result.URL))
+		err := m.Fetch(ctx, download.Request{URL: result.URL, Path: partial})
+		if err == nil {
+			err = checkFile(partial, file)
+		}
+		if err == nil {
+			err = decompressFile(partial, cachePath)
+		}
+		os.Remove(partial)
+		if err != nil {
+			result.Status, result.Err = FetchErr, err
+			return result
+		}
+		result.Status, result.Size = FetchGet, int64(file.Size)
+		return result
+	}
+
+	if idx.optional {
+		return nil
+	}
+	return &Fetch{Status: FetchIgn, URL: idx.url, Description: idx.description}
+}
+
+// decompressFile atomically writes the content of the file src,
+// decompressed according to its extension, to dst.
+func decompressFile(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dr, err := common.Decompress(src, f)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+	return writeFile(dst, dr)
+}
+
+// Packages returns the packages of the cached indices of the sources, for
+// the client's architectures and "all", with their long descriptions from
+// the cached Translation indices of the client's languages. Indices that
+// are not cached are skipped.
+func (c *Client) Packages() ([]pkg.Package, error) {
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+	return a.packages, nil
+}
+
+// available are the packages of the cached indices, with the source and
+// the origin of every package.
+type available struct {
+	packages []pkg.Package
+	sources  []pkg.DebianSource
+	origins  []preferences.Origin
+}
+
+// packages is Packages, also returning the source and origin of every
+// package.
+func (c *Client) packages() (*available, error) {
+	list, err := c.Sources()
+	if err != nil {
+		return nil, err
+	}
+	translations, err := c.translations(list)
+	if err != nil {
+		return nil, err
+	}
+
+	// The releases of the suites, for the origins of the packages
+	releases := map[string]*release.Release{}
+	for _, s := range suites(list) {
+		inRelease := filepath.Join(c.cacheDir, ListName(s.sources[0].DirectorySignedURL()))
+		if _, err := os.Stat(inRelease); err != nil {
+			continue
+		}
+		r, _, err := c.cachedRelease(s)
+		if err != nil {
+			return nil, err
+		}
+		releases[s.url+" "+s.name] = r
+	}
+
+	ret := &available{}
+	seen := map[string]bool{}
+	for _, source := range list {
+		if source.Type != pkg.DebianSourceTypeDeb {
+			continue
+		}
+		r := releases[source.URL+" "+source.Suite]
+
+		for _, arch := range c.indexArchs() {
+			for _, url := range source.ResourceURLs(pkg.Architectures{arch}) {
+				if seen[url] {
+					continue
+				}
+				seen[url] = true
+				f, err := os.Open(filepath.Join(c.cacheDir, ListName(url)))
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				pkgs, err := pkg.Parse(f)
+				f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", url, err)
+				}
+				origin := newOrigin(r, source, arch)
+				for i := range pkgs {
+					pkgs[i].BaseURL = source.URL
+					pkgs[i].Translate(translations)
+					ret.sources = append(ret.sources, source)
+					ret.origins = append(ret.origins, origin)
+				}
+				ret.packages = append(ret.packages, pkgs...)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// translations loads the cached Translation indices of the sources, the
+// languages first in the client's languages taking precedence. Update only
+// caches the Translation indices listed in the Release files.
+func (c *Client) translations(list pkg.DebianSourceList) (pkg.Translations, error) {
+	ret := pkg.Translations{}
+	for _, lang := range c.languages {
+		for _, source := range list {
+			if source.Type != pkg.DebianSourceTypeDeb {
+				continue
+			}
+			url := source.TranslationURL(lang)
+			f, err := os.Open(filepath.Join(c.cacheDir, ListName(url)))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			t, err := pkg.ParseTranslations(f, lang)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", url, err)
+			}
+			ret.Merge(t)
+		}
+	}
+	return ret, nil
+}
+
+func (c *Client) fetch(ctx context.Context, url string) ([]byte, error) {
+	rc, err := c.fetcher.OpenContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// writeFile atomically writes the content of r to path.
+func writeFile(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,24 @@
+package goapt
+
+import (
+	"github.com/anfernee/goapt/pkg/dpkg"
+	"github.com/anfernee/goapt/pkg/upgrade"
+)
+
+// PlanUpgrade computes the plan to upgrade the packages of the dpkg status
+// database of the target system, Dir::State::status, to their candidates
+// from the cached indices, pinned by the client's preferences.
+func (c *Client) PlanUpgrade() (*upgrade.Plan, error) {
+	db, err := dpkg.Load(dpkg.Find(c.config.StatusPath()))
+	if err != nil {
+		return nil, err
+	}
+	a, err := c.packages()
+	if err != nil {
+		return nil, err
+	}
+	return upgrade.Compute(db.Packages(), a.packages, c.archs, &upgrade.Options{
+		Preferences: c.prefs,
+		Origins:     a.originsOf(),
+	}), nil
+}
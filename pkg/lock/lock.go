@@ -0,0 +1,178 @@
+// Package lock reads and writes lockfiles, which pin the packages resolved
+// from a manifest to exact versions and files, so builds fetch the same
+// .deb files hermetically long after the repositories moved on.
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Version is the version of the lockfile format.
+const Version = 1
+
+// sha256Hex matches a hex encoded SHA256 hash.
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Manifest lists the packages to lock and where they come from.
+//
+// Example:
+//
+//	{
+//	  "sources": ["deb http://archive.ubuntu.com/ubuntu focal main"],
+//	  "architectures": ["amd64"],
+//	  "packages": ["bash", "ca-certificates"]
+//	}
+type Manifest struct {
+	// Sources are lines of sources.list.
+	Sources []string `json:"sources"`
+	// Architectures are the architectures of the packages, native first.
+	Architectures []string `json:"architectures"`
+	// Packages are the root packages, "name[:arch][=version]", resolved
+	// with their dependencies.
+	Packages []string `json:"packages"`
+	// KeyRings are the key files, armored if named *.asc and binary
+	// otherwise, trusted to sign the sources in addition to the trusted keys
+	// of apt. Relative paths are relative to the manifest.
+	KeyRings []string `json:"keyrings,omitempty"`
+}
+
+// Source is a suite packages are locked from.
+type Source struct {
+	URL        string   `json:"url"`
+	Suite      string   `json:"suite"`
+	Components []string `json:"components"`
+	// InRelease is the SHA256 of the InRelease file the packages were
+	// resolved from.
+	InRelease string `json:"inrelease_sha256"`
+}
+
+// Package is a locked package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	// Filename is the path of the .deb file relative to URL of its source.
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+	// URL is the URL of the .deb file.
+	URL string `json:"url"`
+	// InRelease is the SHA256 of the InRelease file of the source the
+	// package was resolved from.
+	InRelease string `json:"inrelease_sha256"`
+}
+
+// Lockfile pins the packages of a manifest.
+type Lockfile struct {
+	Version int `json:"version"`
+	// Manifest is the manifest the lockfile was generated from.
+	Manifest Manifest  `json:"manifest"`
+	Sources  []Source  `json:"sources"`
+	Packages []Package `json:"packages"`
+}
+
+// ReadManifest reads the manifest at path.
+func ReadManifest(path string) (*Manifest, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret Manifest
+	if err := json.Unmarshal(d, &ret); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch {
+	case len(ret.Sources) == 0:
+		return nil, fmt.Errorf("%s: no sources", path)
+	case len(ret.Packages) == 0:
+		return nil, fmt.Errorf("%s: no packages", path)
+	}
+	return &ret, nil
+}
+
+// Read reads and validates the lockfile at path.
+func Read(path string) (*Lockfile, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret Lockfile
+	if err := json.Unmarshal(d, &ret); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := ret.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &ret, nil
+}
+
+// Validate checks that the lockfile is well formed: every package is
+// pinned to a file and a hash, once per architecture, and comes from one of
+// the sources.
+func (l *Lockfile) Validate() error {
+	if l.Version != Version {
+		return fmt.Errorf("unsupported lockfile version %d", l.Version)
+	}
+
+	sources := map[string]bool{}
+	for _, s := range l.Sources {
+		if !sha256Hex.MatchString(s.InRelease) {
+			return fmt.Errorf("source %s %s: invalid InRelease SHA256 %q", s.URL, s.Suite, s.InRelease)
+		}
+		sources[s.InRelease] = true
+	}
+
+	seen := map[string]bool{}
+	for _, p := range l.Packages {
+		key := p.Name + ":" + p.Arch
+		switch {
+		case p.Name == "" || p.Version == "" || p.Arch == "" || p.Filename == "" || p.URL == "":
+			return fmt.Errorf("package %s: missing fields", key)
+		case !sha256Hex.MatchString(p.SHA256):
+			return fmt.Errorf("package %s: invalid SHA256 %q", key, p.SHA256)
+		case !sources[p.InRelease]:
+			return fmt.Errorf("package %s: unknown source %q", key, p.InRelease)
+		case seen[key]:
+			return fmt.Errorf("package %s is locked more than once", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Bytes formats the lockfile as indented JSON, with the packages sorted by
+// name and architecture, so the same packages always give the same
+// lockfile.
+func (l *Lockfile) Bytes() ([]byte, error) {
+	sort.Slice(l.Packages, func(i, j int) bool {
+		if l.Packages[i].Name != l.Packages[j].Name {
+			return l.Packages[i].Name < l.Packages[j].Name
+		}
+		return l.Packages[i].Arch < l.Packages[j].Arch
+	})
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(l); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Write writes the lockfile to path.
+func (l *Lockfile) Write(path string) error {
+	d, err := l.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, d, 0644)
+}
@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func newTestLockfile() *Lockfile {
+	return &Lockfile{
+		Version: Version,
+		Manifest: Manifest{
+			Sources:       []string{"deb http://archive.ubuntu.com/ubuntu focal main"},
+			Architectures: []string{"amd64"},
+			Packages:      []string{"hello"},
+		},
+		Sources: []Source{
+			{URL: "http://archive.ubuntu.com/ubuntu", Suite: "focal", Components: []string{"main"}, InRelease: testHash},
+		},
+		Packages: []Package{
+			{
+				Name:      "libc6",
+				Version:   "2.31-0ubuntu9",
+				Arch:      "amd64",
+				Filename:  "pool/main/g/glibc/libc6_2.31-0ubuntu9_amd64.deb",
+				Size:      2573,
+				SHA256:    testHash,
+				URL:       "http://archive.ubuntu.com/ubuntu/pool/main/g/glibc/libc6_2.31-0ubuntu9_amd64.deb",
+				InRelease: testHash,
+			},
+			{
+				Name:      "hello",
+				Version:   "2.10-2ubuntu2",
+				Arch:      "amd64",
+				Filename:  "pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+				Size:      28188,
+				SHA256:    testHash,
+				URL:       "http://archive.ubuntu.com/ubuntu/pool/main/h/hello/hello_2.10-2ubuntu2_amd64.deb",
+				InRelease: testHash,
+			},
+		},
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goapt.lock")
+	l := newTestLockfile()
+	if err := l.Write(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(l, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(l, got))
+	}
+	if got.Packages[0].Name != "hello" {
+		t.Errorf("expect the packages sorted; got %s first", got.Packages[0].Name)
+	}
+
+	// The same packages give the same lockfile
+	d, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := newTestLockfile().Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(d) != string(again) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(string(d), string(again)))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		desc   string
+		modify func(l *Lockfile)
+		expect string
+	}{
+		{
+			desc:   "version",
+			modify: func(l *Lockfile) { l.Version = 2 },
+			expect: "unsupported lockfile version",
+		},
+		{
+			desc:   "missing fields",
+			modify: func(l *Lockfile) { l.Packages[0].Filename = "" },
+			expect: "missing fields",
+		},
+		{
+			desc:   "invalid hash",
+			modify: func(l *Lockfile) { l.Packages[0].SHA256 = "abc" },
+			expect: "invalid SHA256",
+		},
+		{
+			desc:   "unknown source",
+			modify: func(l *Lockfile) { l.Sources[0].InRelease = strings.Repeat("1", 64) },
+			expect: "unknown source",
+		},
+		{
+			desc:   "duplicate",
+			modify: func(l *Lockfile) { l.Packages[1].Name = "libc6" },
+			expect: "locked more than once",
+		},
+	}
+	for _, test := range tests {
+		l := newTestLockfile()
+		test.modify(l)
+		if err := l.Validate(); err == nil || !strings.Contains(err.Error(), test.expect) {
+			t.Errorf("%s: expect err %q; got %v", test.desc, test.expect, err)
+		}
+	}
+
+	if err := newTestLockfile().Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
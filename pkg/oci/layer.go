@@ -0,0 +1,221 @@
+package oci
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/deb"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// statusDir is where distroless images register installed packages, one
+// control file per package.
+const statusDir = "var/lib/dpkg/status.d"
+
+// Input is a resolved package and the path of its downloaded .deb file.
+type Input struct {
+	Package pkg.Package
+	Path    string
+}
+
+// entry is a normalized tar entry of a layer, with its content at offset
+// in the spool file.
+type entry struct {
+	hdr    *tar.Header
+	offset int64
+}
+
+// layer collects the entries of a layer. The content of the entries is
+// spooled to a temporary file rather than held in memory.
+type layer struct {
+	entries map[string]*entry
+	spool   *os.File
+	size    int64
+}
+
+// WriteLayer writes a reproducible, uncompressed layer tarball containing
+// the data of all inputs and a status.d entry per package to w. Entries are
+// sorted by name, hardlinks after the other entries so their targets come
+// first, owned by root, and have their mtime set to mtime.
+func WriteLayer(w io.Writer, inputs []Input, mtime time.Time) error {
+	spool, err := os.CreateTemp("", "goapt-layer-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	l := &layer{entries: map[string]*entry{}, spool: spool}
+
+	for _, input := range inputs {
+		d, err := deb.Open(input.Path)
+		if err != nil {
+			return err
+		}
+		if err := d.Check(input.Package); err != nil {
+			return fmt.Errorf("%s: %w", input.Path, err)
+		}
+		if err := l.addData(d); err != nil {
+			return err
+		}
+
+		name := d.Control.Get("Package")
+		if arch := d.Control.Get("Multi-Arch"); arch == "same" {
+			name += ":" + d.Control.Get("Architecture")
+		}
+		if err := l.addFile(path.Join(statusDir, name), []byte(d.Control.String())); err != nil {
+			return err
+		}
+		if len(d.MD5Sums) > 0 {
+			if err := l.addFile(path.Join(statusDir, name+".md5sums"), md5sums(d.MD5Sums)); err != nil {
+				return err
+			}
+		}
+	}
+	return l.write(w, mtime)
+}
+
+// write writes the entries as a tarball to w.
+func (l *layer) write(w io.Writer, mtime time.Time) error {
+	names := make([]string, 0, len(l.entries))
+	for name := range l.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		// A hardlink can only be extracted after its target
+		a, b := l.entries[names[i]].hdr.Typeflag == tar.TypeLink, l.entries[names[j]].hdr.Typeflag == tar.TypeLink
+		if a != b {
+			return b
+		}
+		return names[i] < names[j]
+	})
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		e := l.entries[name]
+		hdr := e.hdr
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.ModTime = mtime
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.PAXRecords = nil
+		hdr.Format = tar.FormatPAX
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, io.NewSectionReader(l.spool, e.offset, hdr.Size)); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// addData adds the data.tar entries of d, and their parent directories.
+func (l *layer) addData(d *deb.Deb) error {
+	r, err := d.Data()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", d.Path, err)
+		}
+
+		name := normalize(hdr.Name)
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if _, ok := l.entries[name]; ok {
+				continue
+			}
+		case tar.TypeReg, tar.TypeRegA, tar.TypeSymlink, tar.TypeLink:
+		default:
+			// Device nodes and fifos don't belong to image layers.
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = normalize(hdr.Linkname)
+		}
+		if hdr.Typeflag == tar.TypeRegA {
+			hdr.Typeflag = tar.TypeReg
+		}
+		e := &entry{hdr: hdr, offset: l.size}
+		if hdr.Typeflag == tar.TypeReg {
+			n, err := io.Copy(l.spool, r)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.Path, err)
+			}
+			l.size += n
+		}
+		l.addParents(name)
+		l.entries[name] = e
+	}
+}
+
+func (l *layer) addFile(name string, data []byte) error {
+	n, err := l.spool.Write(data)
+	if err != nil {
+		return err
+	}
+	l.addParents(name)
+	l.entries[name] = &entry{
+		hdr: &tar.Header{
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(n),
+		},
+		offset: l.size,
+	}
+	l.size += int64(n)
+	return nil
+}
+
+func (l *layer) addParents(name string) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := l.entries[dir]; ok {
+			continue
+		}
+		l.entries[dir] = &entry{hdr: &tar.Header{Typeflag: tar.TypeDir, Mode: 0755}}
+	}
+}
+
+// normalize converts an archive path like "./usr/bin/" to "usr/bin".
+func normalize(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+func md5sums(sums map[string]string) []byte {
+	var (
+		files []string
+		b     strings.Builder
+	)
+	for file := range sums {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		fmt.Fprintf(&b, "%s  %s\n", sums[file], file)
+	}
+	return []byte(b.String())
+}
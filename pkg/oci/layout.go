@@ -0,0 +1,223 @@
+package oci
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	MediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// Descriptor describes a blob in an OCI image layout.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Index is the index.json of an OCI image layout.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest is an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Config is an OCI image config.
+type Config struct {
+	Created      *time.Time `json:"created,omitempty"`
+	Architecture string     `json:"architecture"`
+	OS           string     `json:"os"`
+	Config       struct{}   `json:"config"`
+	RootFS       RootFS     `json:"rootfs"`
+}
+
+// RootFS lists the uncompressed layer digests of an image.
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// Options specifies the options to write an image layout.
+type Options struct {
+	// Arch is the image architecture. It defaults to the architecture of
+	// the first non "all" package.
+	Arch string
+	// Created is the timestamp of the image and of every layer entry. It
+	// defaults to the unix epoch so that the output is reproducible.
+	Created time.Time
+	// Tag is the optional reference name of the image in index.json.
+	Tag string
+}
+
+// WriteLayout writes an OCI image layout with a single layer built from
+// inputs into dir. It returns the descriptor of the image manifest.
+func WriteLayout(dir string, inputs []Input, options *Options) (*Descriptor, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	created := options.Created
+	if created.IsZero() {
+		created = time.Unix(0, 0).UTC()
+	}
+	arch := options.Arch
+	for _, input := range inputs {
+		if arch == "" && input.Package.Arch != "all" {
+			arch = input.Package.Arch
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return nil, err
+	}
+	layerDesc, diffID, err := writeLayerBlob(dir, inputs, created)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{
+		Created:      &created,
+		Architecture: arch,
+		OS:           "linux",
+		RootFS: RootFS{
+			Type:    "layers",
+			DiffIDs: []string{diffID},
+		},
+	}
+	configDesc, err := writeJSONBlob(dir, MediaTypeConfig, config)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Config:        *configDesc,
+		Layers:        []Descriptor{*layerDesc},
+	}
+	manifestDesc, err := writeJSONBlob(dir, MediaTypeManifest, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if options.Tag != "" {
+		manifestDesc.Annotations = map[string]string{refNameAnnotation: options.Tag}
+	}
+
+	index := Index{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeIndex,
+		Manifests:     []Descriptor{*manifestDesc},
+	}
+	if err := writeJSON(filepath.Join(dir, "index.json"), index); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		return nil, err
+	}
+	return manifestDesc, nil
+}
+
+// writeLayerBlob streams the gzipped layer of inputs into a blob of dir,
+// hashing it on the way, and returns its descriptor and the digest of the
+// uncompressed layer.
+func writeLayerBlob(dir string, inputs []Input, created time.Time) (*Descriptor, string, error) {
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	tmp, err := os.CreateTemp(blobs, ".tmp-layer-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var (
+		compressed = sha256.New()
+		cw         = &countingWriter{w: io.MultiWriter(tmp, compressed)}
+		zw         = gzip.NewWriter(cw)
+		diffID     = sha256.New()
+	)
+	if err := WriteLayer(io.MultiWriter(zw, diffID), inputs, created); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, "", err
+	}
+	sum := fmt.Sprintf("%x", compressed.Sum(nil))
+	if err := os.Rename(tmp.Name(), filepath.Join(blobs, sum)); err != nil {
+		return nil, "", err
+	}
+	return &Descriptor{
+		MediaType: MediaTypeLayer,
+		Digest:    "sha256:" + sum,
+		Size:      cw.n,
+	}, fmt.Sprintf("sha256:%x", diffID.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func writeBlob(dir, mediaType string, data []byte) (*Descriptor, error) {
+	d := digest(data)
+	if err := os.WriteFile(filepath.Join(dir, "blobs", "sha256", d[len("sha256:"):]), data, 0644); err != nil {
+		return nil, err
+	}
+	return &Descriptor{
+		MediaType: mediaType,
+		Digest:    d,
+		Size:      int64(len(data)),
+	}, nil
+}
+
+func writeJSONBlob(dir, mediaType string, v interface{}) (*Descriptor, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return writeBlob(dir, mediaType, data)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,168 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/google/go-cmp/cmp"
+)
+
+const fixture = "../deb/testdata/hello_1.0-1_amd64.gzip.deb"
+
+func hello() []Input {
+	info, _ := os.Stat(fixture)
+	return []Input{{
+		Package: pkg.Package{
+			Metadata: common.Metadata{Name: "hello", Version: "1.0-1"},
+			Arch:     "amd64",
+			Size:     int(info.Size()),
+		},
+		Path: fixture,
+	}}
+}
+
+func TestWriteLayer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLayer(&buf, hello(), time.Unix(0, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names, links []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hdr.Uid != 0 || hdr.Gid != 0 || hdr.ModTime.Unix() != 0 {
+			t.Errorf("%s: expect normalized owner and mtime; got %d:%d %v", hdr.Name, hdr.Uid, hdr.Gid, hdr.ModTime)
+		}
+		if hdr.Name == "var/lib/dpkg/status.d/hello" {
+			d, _ := io.ReadAll(tr)
+			if !strings.HasPrefix(string(d), "Package: hello\n") {
+				t.Errorf("unexpected status.d entry %q", d)
+			}
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			links = append(links, hdr.Name)
+			continue
+		}
+		if len(links) > 0 {
+			t.Errorf("expect hardlinks last; got %s after %v", hdr.Name, links)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("expect sorted entries; got %v", names)
+	}
+	if expect := []string{"usr/bin/hello-hard"}; !cmp.Equal(expect, links) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, links))
+	}
+	for _, expect := range []string{"usr/bin/hello", "var/lib/dpkg/status.d/", "var/lib/dpkg/status.d/hello", "var/lib/dpkg/status.d/hello.md5sums"} {
+		found := false
+		for _, name := range names {
+			found = found || name == expect
+		}
+		if !found {
+			t.Errorf("expect %s in layer; got %v", expect, names)
+		}
+	}
+}
+
+func TestWriteLayerHardlinks(t *testing.T) {
+	spool, err := os.CreateTemp(t.TempDir(), "spool")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer spool.Close()
+	l := &layer{entries: map[string]*entry{}, spool: spool}
+	if err := l.addFile("usr/bin/z", []byte("z")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.entries["usr/bin/a"] = &entry{hdr: &tar.Header{Typeflag: tar.TypeLink, Linkname: "usr/bin/z"}}
+
+	var buf bytes.Buffer
+	if err := l.write(&buf, time.Unix(0, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The layer extracts
+	root := t.TempDir()
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		target := filepath.Join(root, hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			var d []byte
+			if d, err = io.ReadAll(tr); err == nil {
+				err = os.WriteFile(target, d, 0644)
+			}
+		case tar.TypeLink:
+			err = os.Link(filepath.Join(root, hdr.Linkname), target)
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", hdr.Name, err)
+		}
+	}
+	if d, err := os.ReadFile(filepath.Join(root, "usr/bin/a")); err != nil || string(d) != "z" {
+		t.Errorf("expect the hardlink of z; got %q, %v", d, err)
+	}
+}
+
+func TestWriteLayout(t *testing.T) {
+	var digests []string
+	for i := 0; i < 2; i++ {
+		dir := t.TempDir()
+		desc, err := WriteLayout(dir, hello(), &Options{Tag: "latest"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		digests = append(digests, desc.Digest)
+
+		d, err := os.ReadFile(filepath.Join(dir, "index.json"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var index Index
+		if err := json.Unmarshal(d, &index); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(index.Manifests) != 1 || index.Manifests[0].Digest != desc.Digest {
+			t.Errorf("unexpected index %s", d)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(desc.Digest, "sha256:"))); err != nil {
+			t.Errorf("expect manifest blob; got %v", err)
+		}
+	}
+
+	if digests[0] != digests[1] {
+		t.Errorf("expect reproducible manifest digest; got %v", digests)
+	}
+
+	wrong := hello()
+	wrong[0].Package.Version = "2.0"
+	if _, err := WriteLayout(t.TempDir(), wrong, nil); err == nil {
+		t.Errorf("expect err for mismatched package; got nil")
+	}
+}
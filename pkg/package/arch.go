@@ -0,0 +1,117 @@
+package pkg
+
+import "runtime"
+
+const (
+	ArchAMD64 = "amd64"
+	ArchI386  = "i386"
+	// ArchAll is the architecture of architecture independent packages.
+	ArchAll = "all"
+)
+
+// Values of the Multi-Arch field.
+const (
+	MultiArchSame    = "same"
+	MultiArchForeign = "foreign"
+	MultiArchAllowed = "allowed"
+)
+
+// Architectures are the architectures of a system, the native architecture
+// first followed by the foreign architectures, like dpkg
+// --print-architecture and --print-foreign-architectures. An empty list
+// accepts every architecture.
+type Architectures []string
+
+// Native returns the native architecture, or "" if there is none.
+func (a Architectures) Native() string {
+	if len(a) == 0 {
+		return ""
+	}
+	return a[0]
+}
+
+// Has returns whether packages of arch can be installed on the system.
+// Architecture independent packages can always be installed.
+func (a Architectures) Has(arch string) bool {
+	if len(a) == 0 || arch == ArchAll {
+		return true
+	}
+	for _, x := range a {
+		if x == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// HostArch returns the Debian architecture goapt is running on, e.g.
+// "i386" for GOARCH 386.
+func HostArch() string {
+	switch runtime.GOARCH {
+	case "386":
+		return ArchI386
+	case "arm":
+		return "armhf"
+	case "ppc64le":
+		return "ppc64el"
+	case "mips64le":
+		return "mips64el"
+	}
+	return runtime.GOARCH
+}
+
+// preferred returns the architectures to look up a dependency of a package
+// of arch in, arch first.
+func (a Architectures) preferred(arch string) []string {
+	if arch == "" || arch == ArchAll {
+		arch = a.Native()
+	}
+	ret := []string{arch}
+	for _, x := range a {
+		if x != arch {
+			ret = append(ret, x)
+		}
+	}
+	return ret
+}
+
+// SatisfiesOn returns whether the package satisfies r, a relation of a
+// package of arch on a system whose native architecture is native,
+// following the Multi-Arch rules of dpkg:
+//
+//   - Multi-Arch: foreign packages satisfy relations of any architecture.
+//   - Multi-Arch: allowed packages satisfy "name:any" relations of any
+//     architecture.
+//   - Otherwise the architecture of the package, or the native one for
+//     "all", must be the one of the relation, "name:<arch>", or the one of
+//     the depending package.
+//
+// An empty arch and native accept every architecture.
+func (p *Package) SatisfiesOn(r Relation, arch, native string) bool {
+	return p.archSatisfies(r, arch, native) && p.Satisfies(r)
+}
+
+func (p *Package) archSatisfies(r Relation, arch, native string) bool {
+	if p.MultiArch == MultiArchForeign {
+		return true
+	}
+
+	want := arch
+	switch r.Arch {
+	case "":
+	case "any":
+		return p.MultiArch == MultiArchAllowed
+	case "native":
+		want = native
+	default:
+		want = r.Arch
+	}
+	if want == ArchAll {
+		want = native
+	}
+	have := p.Arch
+	if have == ArchAll {
+		have = native
+	}
+	return want == "" || have == "" || have == want
+}
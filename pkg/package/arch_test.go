@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSatisfiesOn(t *testing.T) {
+	tests := []struct {
+		pkg      Package
+		relation string
+		arch     string
+		expect   bool
+	}{
+		{pkg: Package{Arch: "amd64"}, relation: "foo", arch: "amd64", expect: true},
+		{pkg: Package{Arch: "i386"}, relation: "foo", arch: "amd64", expect: false},
+		{pkg: Package{Arch: "all"}, relation: "foo", arch: "amd64", expect: true},
+		{pkg: Package{Arch: "all"}, relation: "foo", arch: "i386", expect: false},
+		{pkg: Package{Arch: "i386", MultiArch: MultiArchForeign}, relation: "foo", arch: "amd64", expect: true},
+		{pkg: Package{Arch: "i386", MultiArch: MultiArchSame}, relation: "foo", arch: "amd64", expect: false},
+		{pkg: Package{Arch: "i386", MultiArch: MultiArchAllowed}, relation: "foo:any", arch: "amd64", expect: true},
+		{pkg: Package{Arch: "amd64"}, relation: "foo:any", arch: "amd64", expect: false},
+		{pkg: Package{Arch: "i386"}, relation: "foo:i386", arch: "amd64", expect: true},
+		{pkg: Package{Arch: "amd64"}, relation: "foo:native", arch: "i386", expect: true},
+		{pkg: Package{Arch: "amd64"}, relation: "foo", arch: "all", expect: true},
+		{pkg: Package{Arch: "amd64"}, relation: "foo (>= 2)", arch: "amd64", expect: false},
+	}
+
+	for _, test := range tests {
+		test.pkg.Name = "foo"
+		test.pkg.Version = "1"
+		r, err := ParseRelation(test.relation)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := test.pkg.SatisfiesOn(r, test.arch, "amd64"); got != test.expect {
+			t.Errorf("%s:%s (%s) for %s on %s: expect %v; got %v",
+				test.pkg.Name, test.pkg.Arch, test.pkg.MultiArch, test.relation, test.arch, test.expect, got)
+		}
+	}
+}
+
+func TestIndexSatisfy(t *testing.T) {
+	newPackage := func(name, arch, multiArch, ver string) Package {
+		p := Package{Arch: arch, MultiArch: multiArch}
+		p.Name = name
+		p.Version = ver
+		return p
+	}
+	idx := NewIndex([]Package{
+		newPackage("libfoo", "amd64", MultiArchSame, "1.0"),
+		newPackage("libfoo", "i386", MultiArchSame, "1.1"),
+		newPackage("libfoo", "arm64", MultiArchSame, "1.2"),
+		newPackage("make", "amd64", MultiArchForeign, "4.2"),
+		newPackage("python3", "i386", MultiArchAllowed, "3.8"),
+	})
+	archs := Architectures{"amd64", "i386"}
+
+	tests := []struct {
+		relation string
+		arch     string
+		expect   []string
+	}{
+		{relation: "libfoo", arch: "amd64", expect: []string{"amd64"}},
+		{relation: "libfoo", arch: "i386", expect: []string{"i386"}},
+		{relation: "libfoo", arch: "all", expect: []string{"amd64"}},
+		{relation: "libfoo:arm64", arch: "amd64", expect: nil},
+		{relation: "make", arch: "i386", expect: []string{"amd64"}},
+		{relation: "python3:any", arch: "amd64", expect: []string{"i386"}},
+		{relation: "python3", arch: "amd64", expect: nil},
+	}
+
+	for _, test := range tests {
+		r, err := ParseRelation(test.relation)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got []string
+		for _, p := range idx.Satisfy(r, test.arch, archs) {
+			got = append(got, p.Arch)
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s on %s: unexpected diff: %v", test.relation, test.arch, cmp.Diff(test.expect, got))
+		}
+	}
+}
+
+func TestIndexSatisfyProvides(t *testing.T) {
+	newPackage := func(name, ver, provides string) Package {
+		p := Package{Arch: "amd64"}
+		p.Name = name
+		p.Version = ver
+		p.Provides, _ = ParseRelations(provides)
+		return p
+	}
+	idx := NewIndex([]Package{
+		newPackage("postfix", "3.4.10-1", "mail-transport-agent"),
+		newPackage("postfix", "3.4.13-0ubuntu1", "mail-transport-agent"),
+		newPackage("exim4", "4.93-13", "mail-transport-agent"),
+		newPackage("exim4-daemon", "4.93-13", "exim4"),
+	})
+	archs := Architectures{"amd64"}
+
+	tests := []struct {
+		relation string
+		expect   []string
+	}{
+		{relation: "mail-transport-agent", expect: []string{"postfix=3.4.13-0ubuntu1", "exim4=4.93-13"}},
+		{relation: "exim4", expect: []string{"exim4=4.93-13", "exim4-daemon=4.93-13"}},
+	}
+	for _, test := range tests {
+		r, err := ParseRelation(test.relation)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got []string
+		for _, p := range idx.Satisfy(r, "amd64", archs) {
+			got = append(got, p.Name+"="+p.Version)
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.relation, cmp.Diff(test.expect, got))
+		}
+	}
+
+	// A pinned provider is picked by the policy
+	idx.SetPolicy(func(versions []*Package) *Package { return versions[0] })
+	r, _ := ParseRelation("mail-transport-agent")
+	if got := idx.Satisfy(r, "amd64", archs); len(got) == 0 || got[0].Version != "3.4.10-1" {
+		t.Errorf("expect the pinned postfix first; got %v", got)
+	}
+}
@@ -28,24 +28,56 @@ type DebianSource struct {
 	URL       string
 	Suite     string
 	Component string
+	// Archs are the architectures of the arch= option, empty for all the
+	// architectures of the system.
+	Archs []string
 }
 
 // DirectoryURL is the URL for the release metadata and directories
 func (s *DebianSource) DirectoryURL() string {
-	return fmt.Sprintf("%s/dists/%s/Release", s.URL, s.Suite)
+	ret, _ := url.JoinPath(s.URL, "dists", s.Suite, "Release")
+	return ret
 }
 
 // DirectorySignedURL is the URL for the release metadata and directories
 func (s *DebianSource) DirectorySignedURL() string {
-	return fmt.Sprintf("%s/dists/%s/InRelease", s.URL, s.Suite)
+	ret, _ := url.JoinPath(s.URL, "dists", s.Suite, "InRelease")
+	return ret
+}
+
+// ResourceURLs returns the URLs of the Packages indices of archs the source
+// is enabled for, one per architecture, or of the Sources index for
+// deb-src. Add ArchAll to archs for repositories publishing binary-all.
+func (s *DebianSource) ResourceURLs(archs Architectures) []string {
+	if s.Type == DebianSourceTypeDebSrc {
+		return []string{s.ResourceURLFor("")}
+	}
+
+	var ret []string
+	for _, arch := range archs {
+		if len(s.Archs) > 0 && arch != ArchAll && !Architectures(s.Archs).Has(arch) {
+			continue
+		}
+		ret = append(ret, s.ResourceURLFor(arch))
+	}
+	return ret
+}
+
+// TranslationURL is the URL of the Translation-<lang> index of the
+// component, holding the long descriptions in lang.
+func (s *DebianSource) TranslationURL(lang string) string {
+	ret, _ := url.JoinPath(s.URL, "dists", s.Suite, s.Component, "i18n", "Translation-"+lang)
+	return ret
 }
 
-func (s *DebianSource) ResourceURL() string {
+// ResourceURLFor is the URL of the Packages index of arch, or of the
+// Sources index for deb-src.
+func (s *DebianSource) ResourceURLFor(arch string) string {
 	var ret string
 
 	switch s.Type {
 	case DebianSourceTypeDeb:
-		ret, _ = url.JoinPath(s.URL, "dists", s.Suite, s.Component, fmt.Sprintf("binary-%s", defaultArch), "Packages")
+		ret, _ = url.JoinPath(s.URL, "dists", s.Suite, s.Component, fmt.Sprintf("binary-%s", arch), "Packages")
 	case DebianSourceTypeDebSrc:
 		ret, _ = url.JoinPath(s.URL, "dists", s.Suite, s.Component, "source", "Sources")
 	}
@@ -53,34 +85,38 @@ func (s *DebianSource) ResourceURL() string {
 	return ret
 }
 
-type Arch string
-
-const (
-	ArchAMD64 = "amd64"
-	ArchI386  = "i386"
-)
-
-var defaultArch Arch = ArchAMD64
+// LoadDebianSourceList loads /etc/apt/sources.list and the files under
+// sources.list.d.
+func LoadDebianSourceList() (DebianSourceList, error) {
+	return loadDebianSourceList(defaultSourceListPath)
+}
 
-func SetArch(arch Arch) {
-	defaultArch = arch
+// LoadDebianSourceListFrom loads a source list file at path and the files
+// under path.d, e.g. the sources.list of a root filesystem.
+func LoadDebianSourceListFrom(path string) (DebianSourceList, error) {
+	return loadDebianSourceList(path)
 }
 
-func LoadDebianSourceList() (DebianSourceList, error) {
-	return loadDebianSourceList(defaultSourceListPath)
+// LoadDebianSourceListFromDir loads a source list file at path and the files
+// under dir.
+func LoadDebianSourceListFromDir(path, dir string) (DebianSourceList, error) {
+	return loadDebianSourceListWithDir(path, dir)
 }
 
 func loadDebianSourceList(path string) (DebianSourceList, error) {
-	ret, err := loadDebianSourceFromFile(path)
-	if err != nil {
-		return ret, err
-	}
+	return loadDebianSourceListWithDir(path, path+".d")
+}
 
-	dir := path + ".d"
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return ret, nil
+// loadDebianSourceListWithDir loads the source list file at path and the
+// files under dir. Like apt, a missing file at path is only an error if dir
+// has no sources either.
+func loadDebianSourceListWithDir(path, dir string) (DebianSourceList, error) {
+	ret, fileErr := loadDebianSourceFromFile(path)
+	if fileErr != nil && !os.IsNotExist(fileErr) {
+		return nil, fileErr
 	}
+
+	entries, _ := os.ReadDir(dir)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -91,6 +127,10 @@ func loadDebianSourceList(path string) (DebianSourceList, error) {
 		}
 		ret = append(ret, list...)
 	}
+
+	if fileErr != nil && len(ret) == 0 {
+		return nil, fileErr
+	}
 	return ret, nil
 }
 
@@ -152,7 +192,7 @@ func parseLine(line string) DebianSourceList {
 	for _, split := range splits {
 		if strings.Index(split, "=") != -1 {
 			options = append(options, split)
-		} else {
+		} else if split != "[" && split != "]" && split != "" {
 			args = append(args, split)
 		}
 	}
@@ -161,7 +201,14 @@ func parseLine(line string) DebianSourceList {
 		return ret
 	}
 
-	// XXX: Options are ignored
+	// XXX: Options other than arch are ignored
+	var archs []string
+	for _, option := range options {
+		key, value, _ := strings.Cut(strings.Trim(option, "[]"), "=")
+		if key == "arch" {
+			archs = strings.Split(value, ",")
+		}
+	}
 
 	// Create a DebianSource struct for each component
 	for _, component := range args[3:] {
@@ -170,6 +217,7 @@ func parseLine(line string) DebianSourceList {
 			URL:       args[1],
 			Suite:     args[2],
 			Component: component,
+			Archs:     archs,
 		})
 	}
 
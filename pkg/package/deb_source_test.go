@@ -1,6 +1,8 @@
 package pkg
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -24,7 +26,7 @@ func TestLoadSingleDebSource(t *testing.T) {
 
 	got := []string{}
 	for _, ds := range list {
-		got = append(got, ds.ResourceURL())
+		got = append(got, ds.ResourceURLFor(ArchAMD64))
 	}
 
 	if !cmp.Equal(expected, got) {
@@ -50,10 +52,68 @@ func TestLoadDebSource(t *testing.T) {
 
 	got := []string{}
 	for _, ds := range list {
-		got = append(got, ds.ResourceURL())
+		got = append(got, ds.ResourceURLFor(ArchAMD64))
 	}
 
 	if !cmp.Equal(expected, got) {
 		t.Errorf("unexpected diff: %v", cmp.Diff(expected, got))
 	}
 }
+
+func TestResourceURLs(t *testing.T) {
+	archs := Architectures{"amd64", "arm64", ArchAll}
+	tests := []struct {
+		line   string
+		expect []string
+	}{
+		{
+			line: "deb http://ports.ubuntu.com/ubuntu-ports/ focal main",
+			expect: []string{
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/binary-amd64/Packages",
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/binary-arm64/Packages",
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/binary-all/Packages",
+			},
+		},
+		{
+			line: "deb [ arch=arm64,armhf ] http://ports.ubuntu.com/ubuntu-ports/ focal main",
+			expect: []string{
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/binary-arm64/Packages",
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/binary-all/Packages",
+			},
+		},
+		{
+			line: "deb-src [arch=arm64] http://ports.ubuntu.com/ubuntu-ports/ focal main",
+			expect: []string{
+				"http://ports.ubuntu.com/ubuntu-ports/dists/focal/main/source/Sources",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		list := parseLine(test.line)
+		if len(list) != 1 {
+			t.Fatalf("%q: expect 1 source; got %d", test.line, len(list))
+		}
+		if got := list[0].ResourceURLs(archs); !cmp.Equal(test.expect, got) {
+			t.Errorf("%q: unexpected diff: %v", test.line, cmp.Diff(test.expect, got))
+		}
+	}
+}
+
+func TestLoadDebSourceMissingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// sources.list.d is enough
+	list, err := LoadDebianSourceListFromDir(filepath.Join(dir, "sources.list"), "testdata/sources.list.d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) == 0 {
+		t.Errorf("expect the sources of sources.list.d; got none")
+	}
+
+	// Otherwise a missing sources.list is an error
+	if _, err := LoadDebianSourceListFromDir(filepath.Join(dir, "sources.list"), filepath.Join(dir, "sources.list.d")); !os.IsNotExist(err) {
+		t.Errorf("expect not exist err; got %v", err)
+	}
+}
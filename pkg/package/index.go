@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// Policy picks the candidate among the versions of a package installable on
+// an architecture, or returns nil if none should be installed.
+type Policy func(versions []*Package) *Package
+
+// Index looks up packages by name and by the virtual names they provide.
+type Index struct {
+	packages map[string][]*Package
+	provides map[string][]*Package
+	policy   Policy
+}
+
+// NewIndex creates an index over pkgs.
+func NewIndex(pkgs []Package) *Index {
+	idx := &Index{
+		packages: map[string][]*Package{},
+		provides: map[string][]*Package{},
+	}
+	for i := range pkgs {
+		idx.Add(&pkgs[i])
+	}
+	return idx
+}
+
+// Add adds a package to the index.
+func (idx *Index) Add(p *Package) {
+	idx.packages[p.Name] = append(idx.packages[p.Name], p)
+	for _, group := range p.Provides {
+		for _, r := range group {
+			idx.provides[r.Name] = append(idx.provides[r.Name], p)
+		}
+	}
+}
+
+// SetPolicy sets the policy picking the candidates, e.g. from the pins of
+// apt preferences. The highest versions are the candidates by default.
+func (idx *Index) SetPolicy(policy Policy) {
+	idx.policy = policy
+}
+
+// Get returns all the versions of a package.
+func (idx *Index) Get(name string) []*Package {
+	return idx.packages[name]
+}
+
+// Names returns the names of all real packages.
+func (idx *Index) Names() []string {
+	var ret []string
+	for name := range idx.packages {
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// Candidate returns the version of a package installable on arch picked by
+// the policy of the index, the highest by default, or nil if there is none.
+func (idx *Index) Candidate(name, arch string) *Package {
+	if idx.policy != nil {
+		var versions []*Package
+		for _, p := range idx.packages[name] {
+			if p.InstallableOn(arch) {
+				versions = append(versions, p)
+			}
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+		return idx.policy(versions)
+	}
+
+	var ret *Package
+	for _, p := range idx.packages[name] {
+		if !p.InstallableOn(arch) {
+			continue
+		}
+		if ret == nil || version.Compare(p.Version, ret.Version) > 0 {
+			ret = p
+		}
+	}
+	return ret
+}
+
+// Satisfy returns the candidates satisfying r, a relation of a package of
+// arch, either directly or through Provides, real packages first. Only
+// packages of archs are considered, preferring arch, and the Multi-Arch
+// rules of SatisfiesOn apply.
+func (idx *Index) Satisfy(r Relation, arch string, archs Architectures) []*Package {
+	var (
+		ret    []*Package
+		native = archs.Native()
+	)
+	for _, a := range archs.preferred(arch) {
+		p := idx.Candidate(r.Name, a)
+		if p == nil || containsPackage(ret, p) {
+			continue
+		}
+		if p.SatisfiesOn(r, arch, native) {
+			ret = append(ret, p)
+		}
+	}
+	for _, p := range idx.provides[r.Name] {
+		if !archs.Has(p.Arch) {
+			continue
+		}
+		// Like apt, only the candidate of a provider can satisfy r
+		cand := idx.Candidate(p.Name, p.Arch)
+		if cand == nil || containsPackage(ret, cand) {
+			continue
+		}
+		if cand.archSatisfies(r, arch, native) && cand.ProvidesRelation(r) {
+			ret = append(ret, cand)
+		}
+	}
+	return ret
+}
+
+func containsPackage(pkgs []*Package, p *Package) bool {
+	for _, x := range pkgs {
+		if x == p {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallableOn returns whether the package can be installed on arch.
+func (p *Package) InstallableOn(arch string) bool {
+	return arch == "" || p.Arch == arch || p.Arch == "all"
+}
+
+// ProvidesRelation returns whether the package provides a virtual package
+// satisfying r. Unversioned provides never satisfy a versioned relation.
+func (p *Package) ProvidesRelation(r Relation) bool {
+	for _, group := range p.Provides {
+		for _, provided := range group {
+			if provided.Name != r.Name {
+				continue
+			}
+			if r.Op == "" {
+				return true
+			}
+			if provided.Op == "=" && version.Satisfies(provided.Version, r.Op, r.Version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Satisfies returns whether the package satisfies r, either directly or
+// through Provides.
+func (p *Package) Satisfies(r Relation) bool {
+	return r.SatisfiedBy(p.Name, p.Version) || p.ProvidesRelation(r)
+}
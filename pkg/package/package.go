@@ -1,15 +1,13 @@
 package pkg
 
 import (
-	"bufio"
-	"compress/gzip"
+	"crypto/md5"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
 
 	"github.com/anfernee/goapt/pkg/common"
-	"github.com/ulikunitz/xz"
 )
 
 // Package is a deb package
@@ -18,81 +16,134 @@ type Package struct {
 	Filename string
 	Size     int
 	Arch     string
+	// BaseURL is the URL of the repository the package was loaded from,
+	// which Filename is relative to, if known.
+	BaseURL string
+
+	Source      string
+	Priority    string
+	MultiArch   string
+	Essential   bool
+	Description string
+	// DescriptionMD5 identifies the English description of the package in
+	// Translation indices.
+	DescriptionMD5 string
+	MD5            string
+	SHA256         string
+
+	Depends    Relations
+	PreDepends Relations
+	Recommends Relations
+	Conflicts  Relations
+	Breaks     Relations
+	Provides   Relations
+	Replaces   Relations
+
+	// Control is the stanza the package was created from, holding every
+	// field of the package.
+	Control *common.Paragraph
+}
+
+// NewPackage creates a package from a stanza of a Packages index or a
+// control file.
+func NewPackage(p *common.Paragraph) Package {
+	relations := func(field string) Relations {
+		// An invalid relation only drops itself, not the whole field
+		rs, _ := ParseRelations(p.Get(field))
+		return rs
+	}
+
+	size, _ := strconv.Atoi(p.Get("Size"))
+	descriptionMD5 := p.Get("Description-md5")
+	if descriptionMD5 == "" && p.Get("Description") != "" {
+		descriptionMD5 = fmt.Sprintf("%x", md5.Sum([]byte(p.Get("Description")+"\n")))
+	}
+	return Package{
+		Metadata: common.Metadata{
+			Name:     p.Get("Package"),
+			Version:  p.Get("Version"),
+			Section:  p.Get("Section"),
+			Origin:   p.Get("Origin"),
+			Homepage: p.Get("Homepage"),
+		},
+		Filename:       p.Get("Filename"),
+		Size:           size,
+		Arch:           p.Get("Architecture"),
+		Source:         p.Get("Source"),
+		Priority:       p.Get("Priority"),
+		MultiArch:      p.Get("Multi-Arch"),
+		Essential:      p.Get("Essential") == "yes",
+		Description:    p.Get("Description"),
+		DescriptionMD5: descriptionMD5,
+		MD5:            p.Get("MD5sum"),
+		SHA256:         p.Get("SHA256"),
+		Depends:        relations("Depends"),
+		PreDepends:     relations("Pre-Depends"),
+		Recommends:     relations("Recommends"),
+		Conflicts:      relations("Conflicts"),
+		Breaks:         relations("Breaks"),
+		Provides:       relations("Provides"),
+		Replaces:       relations("Replaces"),
+		Control:        p,
+	}
+}
+
+// SourceName returns the name of the source package, which is the
+// package name if the Source field is absent.
+func (p *Package) SourceName() string {
+	if p.Source == "" {
+		return p.Name
+	}
+	// Source may carry a version, e.g. "glibc (2.31-0ubuntu9.9)"
+	return strings.Fields(p.Source)[0]
+}
+
+// Summary returns the short description of the package, the first line of
+// its description.
+func (p *Package) Summary() string {
+	summary, _, _ := strings.Cut(p.Description, "\n")
+	return summary
 }
 
 // Load loads packages from a path or URL
 func Load(pathOrUrl string) ([]Package, error) {
-	var (
-		compressFmt string
-		r           io.Reader
-		rc          io.ReadCloser
-		err         error
-	)
-
-	rc, err = common.ReaderOf(pathOrUrl)
+	return LoadWith(nil, pathOrUrl)
+}
+
+// LoadWith loads packages from a path or URL opened with f.
+func LoadWith(f *common.Fetcher, pathOrUrl string) ([]Package, error) {
+	rc, err := f.Open(pathOrUrl)
 	if err != nil {
 		return nil, err
 	}
 	defer rc.Close()
 
-	if strings.HasSuffix(pathOrUrl, ".xz") {
-		compressFmt = "xz"
-		r, err = xz.NewReader(rc)
-	} else if strings.HasSuffix(pathOrUrl, ".gz") {
-		compressFmt = "gzip"
-		r, err = gzip.NewReader(rc)
-	}
+	r, err := common.Decompress(pathOrUrl, rc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decompress %v", compressFmt)
+		return nil, err
 	}
+	defer r.Close()
 
+	return Parse(r)
+}
+
+// Parse parses the stanzas of a Packages index.
+func Parse(r io.Reader) ([]Package, error) {
 	return parse(r)
 }
 
 func parse(r io.Reader) ([]Package, error) {
-	var (
-		buf          = bufio.NewReader(r)
-		ret          = []Package{Package{}}
-		cur *Package = &ret[len(ret)-1]
-	)
-	for {
-		lb, _, err := buf.ReadLine()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		trim := func(line, prefix string) string {
-			return strings.TrimSpace(strings.TrimPrefix(line, prefix))
-		}
-
-		line := strings.TrimSpace(string(lb))
-		switch {
-		case line == "":
-			ret = append(ret, Package{})
-			cur = &ret[len(ret)-1]
-		case strings.HasPrefix(line, "Package:"):
-			cur.Name = trim(line, "Package:")
-		case strings.HasPrefix(line, "Version:"):
-			cur.Version = trim(line, "Version:")
-		case strings.HasPrefix(line, "Section:"):
-			cur.Section = trim(line, "Section:")
-		case strings.HasPrefix(line, "Origin:"):
-			cur.Origin = trim(line, "Origin:")
-		case strings.HasPrefix(line, "Homepage:"):
-			cur.Homepage = trim(line, "Homepage:")
-		case strings.HasPrefix(line, "Filename:"):
-			cur.Filename = trim(line, "Filename:")
-		case strings.HasPrefix(line, "Architecture:"):
-			cur.Arch = trim(line, "Architecture:")
-		case strings.HasPrefix(line, "Size:"):
-			cur.Size, _ = strconv.Atoi(trim(line, "Size:"))
-		}
+	paragraphs, err := common.ParseParagraphs(r)
+	if err != nil {
+		return nil, err
 	}
 
-	if cur.Name == "" {
-		ret = ret[:len(ret)-1]
+	var ret []Package
+	for _, p := range paragraphs {
+		if p.Get("Package") == "" {
+			continue
+		}
+		ret = append(ret, NewPackage(p))
 	}
 	return ret, nil
 }
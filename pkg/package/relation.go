@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// Relation is a single package relationship, e.g. "libc6:amd64 (>= 2.31)".
+// Check deb-control(5) for details.
+type Relation struct {
+	Name string
+	// Arch is the optional architecture qualifier, like "any" or "amd64".
+	Arch    string
+	Op      string
+	Version string
+}
+
+// Relations is a relationship field like Depends. Every element is a list
+// of alternatives separated by "|", and all elements must be satisfied.
+type Relations [][]Relation
+
+// ParseRelations parses a relationship field value. Invalid relations are
+// skipped: the relations that parse are returned with the first error.
+//
+// Example:
+//
+//	libc6 (>= 2.31), debconf (>= 0.5) | debconf-2.0
+func ParseRelations(s string) (Relations, error) {
+	var (
+		ret      Relations
+		firstErr error
+	)
+	for _, group := range strings.Split(s, ",") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+
+		var alternatives []Relation
+		for _, alt := range strings.Split(group, "|") {
+			r, err := ParseRelation(alt)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			alternatives = append(alternatives, r)
+		}
+		if len(alternatives) > 0 {
+			ret = append(ret, alternatives)
+		}
+	}
+	return ret, firstErr
+}
+
+// ParseRelation parses a single relation. Build profile and architecture
+// restrictions in brackets are ignored.
+func ParseRelation(s string) (Relation, error) {
+	var r Relation
+
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "[<"); i >= 0 && !strings.Contains(s[:i], "(") {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	name := s
+	if i := strings.Index(s, "("); i >= 0 {
+		name = strings.TrimSpace(s[:i])
+		j := strings.Index(s, ")")
+		if j < i {
+			return r, fmt.Errorf("invalid relation %q", s)
+		}
+		constraint := strings.TrimSpace(s[i+1 : j])
+		for _, op := range []string{"<<", "<=", ">=", ">>", "=", "<", ">"} {
+			if strings.HasPrefix(constraint, op) {
+				r.Op = op
+				r.Version = strings.TrimSpace(strings.TrimPrefix(constraint, op))
+				break
+			}
+		}
+		if r.Op == "" || r.Version == "" {
+			return r, fmt.Errorf("invalid version constraint in %q", s)
+		}
+	}
+
+	if i := strings.Index(name, ":"); i >= 0 {
+		r.Arch = name[i+1:]
+		name = name[:i]
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return r, fmt.Errorf("invalid relation %q", s)
+	}
+	r.Name = name
+	return r, nil
+}
+
+func (r Relation) String() string {
+	s := r.Name
+	if r.Arch != "" {
+		s += ":" + r.Arch
+	}
+	if r.Op != "" {
+		s += " (" + r.Op + " " + r.Version + ")"
+	}
+	return s
+}
+
+// SatisfiedBy returns whether a package of the given name and version
+// satisfies the relation, ignoring the architecture.
+func (r Relation) SatisfiedBy(name, ver string) bool {
+	if r.Name != name {
+		return false
+	}
+	return r.Op == "" || version.Satisfies(ver, r.Op, r.Version)
+}
+
+func (rs Relations) String() string {
+	var groups []string
+	for _, alternatives := range rs {
+		var alts []string
+		for _, r := range alternatives {
+			alts = append(alts, r.String())
+		}
+		groups = append(groups, strings.Join(alts, " | "))
+	}
+	return strings.Join(groups, ", ")
+}
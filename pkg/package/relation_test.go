@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRelations(t *testing.T) {
+	tests := []struct {
+		in        string
+		expect    Relations
+		expectErr bool
+	}{
+		{
+			in: "libc6 (>= 2.31), debconf (>= 0.5) | debconf-2.0",
+			expect: Relations{
+				{{Name: "libc6", Op: ">=", Version: "2.31"}},
+				{{Name: "debconf", Op: ">=", Version: "0.5"}, {Name: "debconf-2.0"}},
+			},
+		},
+		{
+			in: "python3:any (>= 3.8~), perl:native",
+			expect: Relations{
+				{{Name: "python3", Arch: "any", Op: ">=", Version: "3.8~"}},
+				{{Name: "perl", Arch: "native"}},
+			},
+		},
+		{
+			in: "debhelper-compat (= 12), libfoo-dev [amd64] <!nocheck>",
+			expect: Relations{
+				{{Name: "debhelper-compat", Op: "=", Version: "12"}},
+				{{Name: "libfoo-dev"}},
+			},
+		},
+		{
+			in: "",
+		},
+		{
+			in:        "libc6 (>= )",
+			expectErr: true,
+		},
+		{
+			in: "libc6 (>= 2.31), zlib1g | libz (, libbroken (",
+			expect: Relations{
+				{{Name: "libc6", Op: ">=", Version: "2.31"}},
+				{{Name: "zlib1g"}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRelations(test.in)
+		if test.expectErr && err == nil {
+			t.Errorf("%q: expect err; got nil", test.in)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("%q: expect nil err; got %v", test.in, err)
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%q: unexpected diff: %v", test.in, cmp.Diff(test.expect, got))
+		}
+	}
+}
+
+func TestSatisfiedBy(t *testing.T) {
+	r := Relation{Name: "libc6", Op: ">=", Version: "2.31"}
+	if !r.SatisfiedBy("libc6", "2.31-0ubuntu9") {
+		t.Errorf("expect %v satisfied by 2.31-0ubuntu9", r)
+	}
+	if r.SatisfiedBy("libc6", "2.30") {
+		t.Errorf("expect %v not satisfied by 2.30", r)
+	}
+	if r.SatisfiedBy("musl", "2.31") {
+		t.Errorf("expect %v not satisfied by musl", r)
+	}
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"io"
+
+	"github.com/anfernee/goapt/pkg/common"
+)
+
+// Translations maps the Description-md5 of packages to their descriptions
+// in a language, as published in the Translation-<lang> indices.
+type Translations map[string]string
+
+// ParseTranslations parses the stanzas of a Translation-<lang> index.
+//
+// Example:
+//
+//	Package: perl
+//	Description-md5: 2a9b2ea9d1b5bfe7a4a5db1d1b4e0d9f
+//	Description-en: Larry Wall's Practical Extraction and Report Language
+//	 Perl is a highly capable, feature-rich programming language with over
+//	 20 years of development.
+func ParseTranslations(r io.Reader, lang string) (Translations, error) {
+	paragraphs, err := common.ParseParagraphs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := Translations{}
+	for _, p := range paragraphs {
+		sum, description := p.Get("Description-md5"), p.Get("Description-"+lang)
+		if sum == "" || description == "" {
+			continue
+		}
+		ret[sum] = description
+	}
+	return ret, nil
+}
+
+// Merge adds the descriptions of other missing from t, so the translations
+// merged first take precedence.
+func (t Translations) Merge(other Translations) {
+	for sum, description := range other {
+		if _, ok := t[sum]; !ok {
+			t[sum] = description
+		}
+	}
+}
+
+// Translate replaces the description of p, in both Description and Control,
+// with its translation in t. It returns whether t has a translation of p.
+func (p *Package) Translate(t Translations) bool {
+	description, ok := t[p.DescriptionMD5]
+	if !ok {
+		return false
+	}
+	p.Description = description
+	if p.Control != nil {
+		p.Control.Set("Description", description)
+	}
+	return true
+}
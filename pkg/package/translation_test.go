@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTranslate(t *testing.T) {
+	f, err := os.Open("testdata/Translation-en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	translations, err := ParseTranslations(f, "en")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(translations) != 2 {
+		t.Errorf("expect 2 translations; got %d", len(translations))
+	}
+
+	perl := "Larry Wall's Practical Extraction and Report Language\n" +
+		" Perl is a highly capable, feature-rich programming language with over 20\n" +
+		" years of development."
+	tests := []struct {
+		desc      string
+		stanza    string
+		translate bool
+		expect    string
+	}{
+		{
+			desc:      "md5 field",
+			stanza:    "Package: gcc\nVersion: 4:9.3.0-1ubuntu2\nDescription: GNU C compiler\nDescription-md5: 2f1f5f4b0e5c8ab5d1c4f1e6bd0e7d5a\n",
+			translate: true,
+			expect:    "GNU C compiler\n This is the GNU C compiler, a fairly portable optimizing compiler for C.",
+		},
+		{
+			desc:      "md5 of the description",
+			stanza:    "Package: perl\nVersion: 5.30.0-9ubuntu0.2\nDescription: Larry Wall's Practical Extraction and Report Language\n",
+			translate: true,
+			expect:    perl,
+		},
+		{
+			desc:   "no translation",
+			stanza: "Package: make\nVersion: 4.2.1-1.2\nDescription: utility for directing compilation\n",
+			expect: "utility for directing compilation",
+		},
+	}
+
+	for _, test := range tests {
+		paragraph, err := common.ReadParagraph(bufio.NewReader(strings.NewReader(test.stanza)))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.desc, err)
+		}
+		p := NewPackage(paragraph)
+		if got := p.Translate(translations); got != test.translate {
+			t.Errorf("%s: expect translate %v; got %v", test.desc, test.translate, got)
+		}
+		if !cmp.Equal(test.expect, p.Description) {
+			t.Errorf("%s: unexpected diff: %v", test.desc, cmp.Diff(test.expect, p.Description))
+		}
+		if got := p.Control.Get("Description"); got != test.expect {
+			t.Errorf("%s: expect control description %q; got %q", test.desc, test.expect, got)
+		}
+	}
+}
+
+func TestTranslationsMerge(t *testing.T) {
+	translations := Translations{"a": "Beschreibung"}
+	translations.Merge(Translations{"a": "description", "b": "other"})
+
+	expect := Translations{"a": "Beschreibung", "b": "other"}
+	if !cmp.Equal(expect, translations) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, translations))
+	}
+}
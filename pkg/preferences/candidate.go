@@ -0,0 +1,201 @@
+package preferences
+
+import (
+	"sort"
+
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// Default priorities of apt. Check apt_preferences(5) for details.
+const (
+	PriorityTargetRelease = 990
+	PriorityDefault       = 500
+	PriorityInstalled     = 100
+	PriorityNotAutomatic  = 1
+	// PriorityButAutomaticUpgrades is the priority of NotAutomatic releases
+	// with ButAutomaticUpgrades, the same as installed versions.
+	PriorityButAutomaticUpgrades = 100
+	// PriorityDowngrade is the priority from which a version is installed
+	// even if it is a downgrade.
+	PriorityDowngrade = 1000
+)
+
+// Origin is the release, or the dpkg status database, a package version
+// comes from.
+type Origin struct {
+	Archive   string
+	Codename  string
+	Origin    string
+	Label     string
+	Component string
+	Version   string
+	Arch      string
+	// Site is the host name of the repository.
+	Site                 string
+	NotAutomatic         bool
+	ButAutomaticUpgrades bool
+	// Installed is set for the dpkg status database.
+	Installed bool
+}
+
+// NewOrigin creates the origin of the packages of a release component.
+func NewOrigin(r *release.Release, component, arch, site string) Origin {
+	return Origin{
+		Archive:              r.Suite,
+		Codename:             r.Codename,
+		Origin:               r.Origin,
+		Label:                r.Label,
+		Component:            component,
+		Version:              r.Version,
+		Arch:                 arch,
+		Site:                 site,
+		NotAutomatic:         r.NotAutomatic,
+		ButAutomaticUpgrades: r.ButAutomaticUpgrades,
+	}
+}
+
+// Version is a package version and the origins it is available from.
+type Version struct {
+	Package *pkg.Package
+	Origins []Origin
+}
+
+// IsInstalled returns whether the version is installed.
+func (v *Version) IsInstalled() bool {
+	for _, o := range v.Origins {
+		if o.Installed {
+			return true
+		}
+	}
+	return false
+}
+
+// Options specifies the options of candidate selection.
+type Options struct {
+	// TargetRelease is the default release, like "apt-get -t". It matches
+	// the archive or codename of an origin.
+	TargetRelease string
+}
+
+// Priority returns the pin priority of a package version from origin o.
+func (prefs Preferences) Priority(p *pkg.Package, o Origin, options *Options) int {
+	// Specific pins win over everything else.
+	for i := range prefs {
+		pin := &prefs[i]
+		if !pin.IsGeneric() && pin.MatchPackage(p.Name) && pin.MatchOrigin(p.Version, o) {
+			return pin.Priority
+		}
+	}
+	for i := range prefs {
+		pin := &prefs[i]
+		if pin.IsGeneric() && pin.MatchOrigin(p.Version, o) {
+			return pin.Priority
+		}
+	}
+	return defaultPriority(o, options)
+}
+
+func defaultPriority(o Origin, options *Options) int {
+	switch {
+	case o.Installed:
+		return PriorityInstalled
+	case options != nil && options.TargetRelease != "" &&
+		(options.TargetRelease == o.Archive || options.TargetRelease == o.Codename):
+		return PriorityTargetRelease
+	case o.NotAutomatic && o.ButAutomaticUpgrades:
+		return PriorityButAutomaticUpgrades
+	case o.NotAutomatic:
+		return PriorityNotAutomatic
+	}
+	return PriorityDefault
+}
+
+// VersionPriority returns the highest priority of a version among its
+// origins. The installed version has at least the installed priority.
+func (prefs Preferences) VersionPriority(v *Version, options *Options) int {
+	ret := -1 << 31
+	for _, o := range v.Origins {
+		if p := prefs.Priority(v.Package, o, options); p > ret {
+			ret = p
+		}
+	}
+	if v.IsInstalled() && ret < PriorityInstalled {
+		ret = PriorityInstalled
+	}
+	return ret
+}
+
+// Candidate selects the version to install among the versions of a single
+// package the way apt does: the version with the highest priority wins, ties
+// are broken by the higher version, and downgrades of the installed version
+// require a priority of at least 1000. It returns nil if no version can be
+// installed.
+func (prefs Preferences) Candidate(versions []*Version, options *Options) *Version {
+	type ranked struct {
+		v        *Version
+		priority int
+	}
+
+	var (
+		list      []ranked
+		installed *Version
+	)
+	for _, v := range versions {
+		list = append(list, ranked{v: v, priority: prefs.VersionPriority(v, options)})
+		if v.IsInstalled() {
+			installed = v
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].priority != list[j].priority {
+			return list[i].priority > list[j].priority
+		}
+		return version.Compare(list[i].v.Package.Version, list[j].v.Package.Version) > 0
+	})
+
+	for _, r := range list {
+		switch {
+		case r.priority < 0:
+			continue
+		case installed == nil || r.v == installed:
+			return r.v
+		case r.priority < PriorityInstalled:
+			// Only installed if no version is installed
+			continue
+		case r.priority < PriorityDowngrade &&
+			version.Compare(r.v.Package.Version, installed.Package.Version) < 0:
+			continue
+		}
+		return r.v
+	}
+	return nil
+}
+
+// Policy returns the policy picking candidates like Candidate, for a
+// pkg.Index. origins returns the origins of a package version, Installed
+// for the installed version.
+func (prefs Preferences) Policy(origins func(p *pkg.Package) []Origin, options *Options) pkg.Policy {
+	return func(pkgs []*pkg.Package) *pkg.Package {
+		var (
+			versions []*Version
+			byKey    = map[string]*Version{}
+		)
+		// The same version may come from several releases
+		for _, p := range pkgs {
+			key := p.Arch + "=" + p.Version
+			v := byKey[key]
+			if v == nil {
+				v = &Version{Package: p}
+				byKey[key] = v
+				versions = append(versions, v)
+			}
+			v.Origins = append(v.Origins, origins(p)...)
+		}
+		if v := prefs.Candidate(versions, options); v != nil {
+			return v.Package
+		}
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+package preferences
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/common"
+)
+
+const (
+	defaultPreferencesPath = "/etc/apt/preferences"
+)
+
+// PinType is the kind of a pin.
+type PinType string
+
+const (
+	PinRelease PinType = "release"
+	PinOrigin  PinType = "origin"
+	PinVersion PinType = "version"
+)
+
+// Pin is a stanza of an apt preferences file. Check apt_preferences(5) for
+// details.
+//
+// Example:
+//
+//	Package: *
+//	Pin: release a=focal-backports
+//	Pin-Priority: 500
+type Pin struct {
+	// Packages are the package name patterns: names, globs or /regexes/.
+	Packages []string
+	Type     PinType
+	// Value is the raw value for origin and version pins.
+	Value string
+	// Release holds the key=value selectors of release pins, keyed by the
+	// single letter keys a, n, o, l, c, v and b.
+	Release  map[string]string
+	Priority int
+}
+
+// Preferences is an ordered list of pins. The first matching pin wins.
+type Preferences []Pin
+
+// Load loads /etc/apt/preferences and the files under preferences.d.
+func Load() (Preferences, error) {
+	return loadFromDir(defaultPreferencesPath, defaultPreferencesPath+".d")
+}
+
+// LoadFrom loads a preferences file at path and the files under dir, e.g.
+// Dir::Etc::preferences and Dir::Etc::preferencesparts. A missing file is
+// not an error. Files under dir must have no extension or the ".pref"
+// extension, like apt requires.
+func LoadFrom(path, dir string) (Preferences, error) {
+	return loadFromDir(path, dir)
+}
+
+func loadFromDir(path, dir string) (Preferences, error) {
+	ret, err := loadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ret, nil
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext != "" && ext != ".pref" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pins, err := loadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, pins...)
+	}
+	return ret, nil
+}
+
+func loadFile(path string) (Preferences, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pins, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pins, nil
+}
+
+// Parse parses the stanzas of a preferences file.
+func Parse(r io.Reader) (Preferences, error) {
+	var (
+		ret Preferences
+		buf = bufio.NewReader(r)
+	)
+	for {
+		p, err := common.ReadParagraph(buf)
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pin, err := parsePin(p)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *pin)
+	}
+}
+
+func parsePin(p *common.Paragraph) (*Pin, error) {
+	pin := &Pin{Packages: strings.Fields(p.Get("Package"))}
+	if len(pin.Packages) == 0 {
+		return nil, fmt.Errorf("missing Package field")
+	}
+
+	priority, err := strconv.Atoi(p.Get("Pin-Priority"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pin-Priority %q", p.Get("Pin-Priority"))
+	}
+	pin.Priority = priority
+
+	value := strings.TrimSpace(p.Get("Pin"))
+	typ, value, _ := strings.Cut(value, " ")
+	pin.Type = PinType(typ)
+	pin.Value = strings.TrimSpace(value)
+
+	switch pin.Type {
+	case PinRelease:
+		pin.Release = map[string]string{}
+		for _, selector := range strings.Split(pin.Value, ",") {
+			selector = strings.TrimSpace(selector)
+			if selector == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(selector, "=")
+			if !ok {
+				// A bare value is the release version
+				key, value = "v", selector
+			}
+			pin.Release[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	case PinOrigin, PinVersion:
+		pin.Value = strings.Trim(pin.Value, `"`)
+	default:
+		return nil, fmt.Errorf("invalid Pin %q", p.Get("Pin"))
+	}
+	return pin, nil
+}
+
+// IsGeneric returns whether the pin applies to all packages.
+func (p *Pin) IsGeneric() bool {
+	return len(p.Packages) == 1 && p.Packages[0] == "*"
+}
+
+// MatchPackage returns whether the pin applies to the package name.
+func (p *Pin) MatchPackage(name string) bool {
+	for _, pattern := range p.Packages {
+		if match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchOrigin returns whether a version of the given version string from
+// origin o is selected by the pin, ignoring the package names.
+func (p *Pin) MatchOrigin(ver string, o Origin) bool {
+	switch p.Type {
+	case PinVersion:
+		return match(p.Value, ver)
+	case PinOrigin:
+		// An empty origin matches the local files, e.g. the dpkg status.
+		return p.Value == o.Site || (p.Value == "" && o.Installed)
+	case PinRelease:
+		if o.Installed {
+			return false
+		}
+		fields := map[string]string{
+			"a": o.Archive,
+			"n": o.Codename,
+			"o": o.Origin,
+			"l": o.Label,
+			"c": o.Component,
+			"v": o.Version,
+			"b": o.Arch,
+		}
+		for key, pattern := range p.Release {
+			value, ok := fields[key]
+			if !ok || !match(pattern, value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// match matches a value against a literal, a glob or a /regex/.
+func match(pattern, value string) bool {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(value)
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, value)
+		return err == nil && ok
+	}
+	return pattern == value
+}
@@ -0,0 +1,165 @@
+package preferences
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadFrom(t *testing.T) {
+	prefs, err := LoadFrom("testdata/preferences", "testdata/preferences.d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := Preferences{
+		{
+			Packages: []string{"linux-*", "/^linux-image-/"},
+			Type:     PinRelease,
+			Value:    "a=focal-backports, o=Ubuntu",
+			Release:  map[string]string{"a": "focal-backports", "o": "Ubuntu"},
+			Priority: 900,
+		},
+		{
+			Packages: []string{"nginx"},
+			Type:     PinVersion,
+			Value:    "1.18.*",
+			Priority: 1001,
+		},
+		{
+			Packages: []string{"*"},
+			Type:     PinOrigin,
+			Value:    "apt.example.com",
+			Priority: 700,
+		},
+		{
+			Packages: []string{"telnet"},
+			Type:     PinRelease,
+			Value:    "n=focal*",
+			Release:  map[string]string{"n": "focal*"},
+			Priority: -10,
+		},
+	}
+	if !cmp.Equal(expect, prefs) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, prefs))
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	inputs := []string{
+		"Package: *\nPin: release a=focal\n",
+		"Package: *\nPin: something a=focal\nPin-Priority: 1\n",
+		"Pin: release a=focal\nPin-Priority: 1\n",
+	}
+	for _, input := range inputs {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expect err; got nil", input)
+		}
+	}
+}
+
+var (
+	focal = Origin{Archive: "focal", Codename: "focal", Origin: "Ubuntu", Label: "Ubuntu", Component: "main", Version: "20.04", Site: "archive.ubuntu.com"}
+
+	focalUpdates = Origin{Archive: "focal-updates", Codename: "focal", Origin: "Ubuntu", Label: "Ubuntu", Component: "main", Version: "20.04", Site: "archive.ubuntu.com"}
+
+	backports = Origin{Archive: "focal-backports", Codename: "focal", Origin: "Ubuntu", Label: "Ubuntu", Component: "main", Version: "20.04", Site: "archive.ubuntu.com", NotAutomatic: true, ButAutomaticUpgrades: true}
+
+	experimental = Origin{Archive: "experimental", Codename: "rc-buggy", Origin: "Debian", Site: "deb.debian.org", NotAutomatic: true}
+
+	internal = Origin{Archive: "stable", Origin: "Example", Site: "apt.example.com"}
+
+	status = Origin{Installed: true}
+)
+
+func newVersion(name, ver string, origins ...Origin) *Version {
+	return &Version{
+		Package: &pkg.Package{Metadata: common.Metadata{Name: name, Version: ver}},
+		Origins: origins,
+	}
+}
+
+func TestCandidate(t *testing.T) {
+	prefs, err := LoadFrom("testdata/preferences", "testdata/preferences.d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		desc     string
+		versions []*Version
+		options  *Options
+		expect   string
+	}{
+		{
+			desc:     "newest default version",
+			versions: []*Version{newVersion("curl", "7.68.0-1", focal), newVersion("curl", "7.68.0-1ubuntu2.14", focalUpdates)},
+			expect:   "7.68.0-1ubuntu2.14",
+		},
+		{
+			desc:     "not automatic is not a candidate",
+			versions: []*Version{newVersion("vim", "2:8.1", focal), newVersion("vim", "2:9.0", experimental)},
+			expect:   "2:8.1",
+		},
+		{
+			desc:     "target release",
+			versions: []*Version{newVersion("vim", "2:8.1", focal), newVersion("vim", "2:9.0", experimental)},
+			options:  &Options{TargetRelease: "experimental"},
+			expect:   "2:9.0",
+		},
+		{
+			desc:     "but automatic upgrades follow installed backports",
+			versions: []*Version{newVersion("cmake", "3.16", focal), newVersion("cmake", "3.20", backports, status), newVersion("cmake", "3.22", backports)},
+			expect:   "3.22",
+		},
+		{
+			desc:     "backports are not installed by default",
+			versions: []*Version{newVersion("cmake", "3.16", focal), newVersion("cmake", "3.22", backports)},
+			expect:   "3.16",
+		},
+		{
+			desc:     "specific release pin",
+			versions: []*Version{newVersion("linux-image-generic", "5.4", focalUpdates), newVersion("linux-image-generic", "5.15", backports)},
+			expect:   "5.15",
+		},
+		{
+			desc:     "origin pin wins over newer version",
+			versions: []*Version{newVersion("mytool", "2.0", focal), newVersion("mytool", "1.0", internal)},
+			expect:   "1.0",
+		},
+		{
+			desc:     "no downgrade under 1000",
+			versions: []*Version{newVersion("mytool", "2.0", focal, status), newVersion("mytool", "1.0", internal)},
+			expect:   "2.0",
+		},
+		{
+			desc:     "version pin above 1000 downgrades",
+			versions: []*Version{newVersion("nginx", "1.20", focalUpdates, status), newVersion("nginx", "1.18.0", focal)},
+			expect:   "1.18.0",
+		},
+		{
+			desc:     "negative priority",
+			versions: []*Version{newVersion("telnet", "0.17", focal)},
+			expect:   "",
+		},
+		{
+			desc:     "installed version is kept when only older versions are available",
+			versions: []*Version{newVersion("curl", "7.70", status), newVersion("curl", "7.68", focal)},
+			expect:   "7.70",
+		},
+	}
+
+	for _, test := range tests {
+		got := prefs.Candidate(test.versions, test.options)
+		ver := ""
+		if got != nil {
+			ver = got.Package.Version
+		}
+		if ver != test.expect {
+			t.Errorf("%s: expect %q; got %q", test.desc, test.expect, ver)
+		}
+	}
+}
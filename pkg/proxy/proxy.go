@@ -0,0 +1,389 @@
+// Package proxy is a caching proxy of apt repositories, like
+// apt-cacher-ng. apt clients use it as their http proxy, or as the mirror
+// of their sources.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/server"
+)
+
+// Options configures a Proxy.
+type Options struct {
+	// Client fetches the files from upstream. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+	// KeyRing holds the keys trusted to sign repositories. InRelease files,
+	// and Release files with their Release.gpg signatures, are only cached
+	// and served if verified by it. Nil disables the verification.
+	KeyRing *crypto.KeyRing
+	// MaxAge is how long a cached index is served without revalidating it
+	// upstream. Zero revalidates every request.
+	MaxAge time.Duration
+	// Logger logs the requests and how they were served. Defaults to
+	// discarding.
+	Logger *log.Logger
+}
+
+// Proxy is a caching proxy of apt repositories. It serves:
+//
+//   - proxy requests, like "GET http://archive.ubuntu.com/ubuntu/... HTTP/1.1",
+//     from clients with Acquire::http::Proxy set to it;
+//   - mirror requests, like "GET /archive.ubuntu.com/ubuntu/...", from
+//     clients with the sources rewritten to http://<proxy>/archive.ubuntu.com/ubuntu.
+//
+// Files are cached under the cache directory with the layout of a mirror,
+// e.g. <dir>/archive.ubuntu.com/ubuntu/dists/focal/InRelease. Pool files and
+// by-hash files never change, and are cached permanently. Other files, the
+// indices, are revalidated upstream, and served from the cache if upstream
+// is unreachable. Concurrent requests of a file share a single upstream
+// request. Only http upstreams are supported.
+type Proxy struct {
+	dir     string
+	options Options
+
+	mu sync.Mutex
+	// calls are the upstream requests in flight, by cache path.
+	calls map[string]*call
+	// checked is when the cached indices were last revalidated.
+	checked map[string]time.Time
+}
+
+// call is an upstream request in flight.
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a proxy caching the files under dir.
+func New(dir string, options *Options) *Proxy {
+	p := &Proxy{
+		dir:     dir,
+		calls:   map[string]*call{},
+		checked: map[string]time.Time{},
+	}
+	if options != nil {
+		p.options = *options
+	}
+	if p.options.Client == nil {
+		p.options.Client = http.DefaultClient
+	}
+	if p.options.Logger == nil {
+		p.options.Logger = log.New(io.Discard, "", 0)
+	}
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host, name := r.URL.Host, path.Clean("/"+r.URL.Path)
+	if !r.URL.IsAbs() {
+		// A mirror request, the host is the first element of the path
+		host, name, _ = strings.Cut(strings.TrimPrefix(name, "/"), "/")
+		name = "/" + name
+	}
+	if r.URL.IsAbs() && r.URL.Scheme != "http" {
+		http.Error(w, "only http upstreams are supported", http.StatusBadRequest)
+		return
+	}
+	if host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) || name == "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	upstream := "http://" + host + name
+	local := filepath.Join(p.dir, host, filepath.FromSlash(name))
+	key, fetch := local, func() (string, error) {
+		return p.fetch(upstream, local)
+	}
+	if p.options.KeyRing != nil && (path.Base(name) == "Release" || path.Base(name) == "Release.gpg") {
+		// Release and Release.gpg are fetched and verified together
+		key = strings.TrimSuffix(local, ".gpg")
+		fetch = func() (string, error) {
+			return p.fetchRelease(strings.TrimSuffix(upstream, ".gpg"), key)
+		}
+	}
+	status, err := p.do(key, fetch)
+	if err != nil {
+		p.options.Logger.Printf("Err %s: %v", upstream, err)
+		var statusErr *common.StatusError
+		switch {
+		case errors.As(err, &statusErr) && statusErr.StatusCode < 500:
+			http.Error(w, statusErr.Status, statusErr.StatusCode)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+	p.options.Logger.Printf("%s %s", status, upstream)
+
+	f, err := os.Open(local)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", server.ContentType(name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// do calls fn, unless a call for the file local is in flight already, in
+// which case it waits for its result.
+func (p *Proxy) do(local string, fn func() (string, error)) (string, error) {
+	p.mu.Lock()
+	if c := p.calls[local]; c != nil {
+		p.mu.Unlock()
+		<-c.done
+		return "Hit", c.err
+	}
+	c := &call{done: make(chan struct{})}
+	p.calls[local] = c
+	p.mu.Unlock()
+
+	status, err := fn()
+	c.err = err
+
+	p.mu.Lock()
+	delete(p.calls, local)
+	p.mu.Unlock()
+	close(c.done)
+	return status, err
+}
+
+// immutable returns whether the file at the url never changes once published:
+// the pool files and the by-hash files.
+func immutable(url string) bool {
+	switch {
+	case strings.Contains(url, "/pool/"), strings.Contains(url, "/by-hash/"):
+		return true
+	}
+	switch path.Ext(url) {
+	case ".deb", ".udeb", ".ddeb", ".dsc":
+		return true
+	}
+	return false
+}
+
+// fetch caches the upstream file at the path local, unless cached and
+// fresh, and returns how it was served: "Hit" from the cache, "Get" from
+// upstream, or "Stale" from the cache with upstream unreachable.
+func (p *Proxy) fetch(upstream, local string) (string, error) {
+	info, err := os.Stat(local)
+	cached := err == nil && info.Mode().IsRegular()
+	if cached && (immutable(upstream) || p.fresh(local)) {
+		return "Hit", nil
+	}
+	if !cached {
+		info = nil
+	}
+
+	tmp, resp, err := p.download(upstream, local, info)
+	if err != nil {
+		if cached {
+			return "Stale", nil
+		}
+		return "", err
+	}
+	if tmp == "" {
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached:
+			p.revalidated(local)
+			return "Hit", nil
+		case resp.StatusCode >= 500 && cached:
+			return "Stale", nil
+		}
+		return "", gone(upstream, resp, local)
+	}
+	defer os.Remove(tmp)
+
+	if err := p.verify(upstream, tmp); err != nil {
+		return "", fmt.Errorf("failed to verify %s: %w", upstream, err)
+	}
+	if err := os.Rename(tmp, local); err != nil {
+		return "", err
+	}
+	p.revalidated(local)
+	return "Get", nil
+}
+
+// fetchRelease caches the upstream Release file at the path local, and its
+// Release.gpg signature next to it, unless both are cached and fresh. The
+// pair is only cached if the signature verifies, so that a Release file is
+// never served without a valid signature. It returns how they were served,
+// like fetch.
+func (p *Proxy) fetchRelease(upstream, local string) (string, error) {
+	info, err := os.Stat(local)
+	sigInfo, sigErr := os.Stat(local + ".gpg")
+	cached := err == nil && info.Mode().IsRegular() && sigErr == nil && sigInfo.Mode().IsRegular()
+	if cached && p.fresh(local) {
+		return "Hit", nil
+	}
+	if !cached {
+		info = nil
+	}
+
+	tmp, resp, err := p.download(upstream, local, info)
+	if err != nil {
+		if cached {
+			return "Stale", nil
+		}
+		return "", err
+	}
+	if tmp == "" {
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached:
+			p.revalidated(local)
+			return "Hit", nil
+		case resp.StatusCode >= 500 && cached:
+			return "Stale", nil
+		}
+		return "", gone(upstream, resp, local, local+".gpg")
+	}
+	defer os.Remove(tmp)
+
+	// The signature always matches the Release file just downloaded
+	sigTmp, resp, err := p.download(upstream+".gpg", local+".gpg", nil)
+	if err != nil {
+		if cached {
+			return "Stale", nil
+		}
+		return "", err
+	}
+	if sigTmp == "" {
+		if resp.StatusCode >= 500 && cached {
+			return "Stale", nil
+		}
+		return "", fmt.Errorf("failed to verify %s: %w", upstream, gone(upstream+".gpg", resp, local, local+".gpg"))
+	}
+	defer os.Remove(sigTmp)
+
+	content, err := os.ReadFile(tmp)
+	if err != nil {
+		return "", err
+	}
+	signature, err := os.ReadFile(sigTmp)
+	if err != nil {
+		return "", err
+	}
+	if err := release.VerifyDetached(content, signature, p.options.KeyRing); err != nil {
+		return "", fmt.Errorf("failed to verify %s: %w", upstream, err)
+	}
+	if err := os.Rename(sigTmp, local+".gpg"); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, local); err != nil {
+		return "", err
+	}
+	p.revalidated(local)
+	return "Get", nil
+}
+
+// download downloads the upstream file into a temporary file next to the
+// path local, and returns its path with the response. If info, of the cached
+// file, is set, the file is only downloaded if modified since. The path is
+// empty if the response isn't 200 OK. The body of the response is always
+// closed.
+func (p *Proxy) download(upstream, local string, info os.FileInfo) (string, *http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, upstream, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if info != nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	resp, err := p.options.Client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", resp, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(local), ".tmp-"+filepath.Base(local))
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", nil, err
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(tmp.Name(), modTime, modTime)
+	}
+	return tmp.Name(), resp, nil
+}
+
+// gone returns the error of the upstream request answered with resp, and
+// removes the cached files at locals if the file is gone upstream.
+func gone(upstream string, resp *http.Response, locals ...string) error {
+	err := &common.StatusError{URL: upstream, StatusCode: resp.StatusCode, Status: resp.Status}
+	if errors.Is(err, fs.ErrNotExist) {
+		for _, local := range locals {
+			os.Remove(local)
+		}
+	}
+	return err
+}
+
+// verify verifies the signature of the InRelease file downloaded from
+// upstream to path. Release files are verified by fetchRelease.
+func (p *Proxy) verify(upstream, path string) error {
+	if p.options.KeyRing == nil || !strings.HasSuffix(upstream, "/InRelease") {
+		return nil
+	}
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = release.VerifyCleartext(d, p.options.KeyRing)
+	return err
+}
+
+// fresh returns whether the cached index at local was revalidated within
+// MaxAge.
+func (p *Proxy) fresh(local string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	checked, ok := p.checked[local]
+	return ok && time.Since(checked) < p.options.MaxAge
+}
+
+// revalidated records that the cached file at local is up to date.
+func (p *Proxy) revalidated(local string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checked[local] = time.Now()
+}
@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/anfernee/goapt/pkg/server"
+	"github.com/google/go-cmp/cmp"
+)
+
+// upstream is a repository served for tests, counting the requests of
+// every path.
+type upstream struct {
+	dir string
+	url string
+	srv *httptest.Server
+	// keyRing trusts the key signing the repository.
+	keyRing *crypto.KeyRing
+	// sign signs a cleartext message with the key of the repository.
+	sign func(string) string
+	// signDetached returns the armored detached signature of a message by
+	// the key of the repository.
+	signDetached func(string) string
+
+	mu       sync.Mutex
+	requests map[string]int
+	// delay delays every response.
+	delay time.Duration
+	// writes counts the files written.
+	writes int
+}
+
+func newUpstream(t *testing.T) *upstream {
+	t.Helper()
+
+	key, err := crypto.GenerateKey("goapt", "goapt@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	public, err := key.ToPublic()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyRing, err := crypto.NewKeyRing(public)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u := &upstream{
+		dir:      t.TempDir(),
+		keyRing:  keyRing,
+		requests: map[string]int{},
+		sign: func(text string) string {
+			signed, err := helper.SignCleartextMessage(signing, text)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return signed
+		},
+		signDetached: func(text string) string {
+			sig, err := signing.SignDetached(crypto.NewPlainMessage([]byte(text)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			armored, err := sig.GetArmored()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return armored
+		},
+	}
+	u.write(t, "ubuntu/dists/focal/InRelease", u.sign("Suite: focal\n"))
+	u.write(t, "ubuntu/dists/focal/main/binary-amd64/Packages", "Package: hello\n")
+	u.write(t, "ubuntu/pool/main/h/hello/hello_2.10_amd64.deb", "!<arch>\n")
+
+	files := server.New(u.dir, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests[r.URL.Path]++
+		delay := u.delay
+		u.mu.Unlock()
+		time.Sleep(delay)
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	u.url, u.srv = srv.URL, srv
+	return u
+}
+
+func (u *upstream) write(t *testing.T, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(u.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Make every change visible to If-Modified-Since, which has a
+	// resolution of a second
+	u.writes++
+	modTime := time.Now().Add(time.Duration(u.writes) * time.Second)
+	os.Chtimes(path, modTime, modTime)
+}
+
+func (u *upstream) count(name string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[name]
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	up := newUpstream(t)
+	dir := t.TempDir()
+	srv := httptest.NewServer(New(dir, &Options{KeyRing: up.keyRing}))
+	t.Cleanup(srv.Close)
+
+	// Mirror requests
+	mirror := srv.URL + "/" + strings.TrimPrefix(up.url, "http://")
+	deb := "/ubuntu/pool/main/h/hello/hello_2.10_amd64.deb"
+	for i := 0; i < 2; i++ {
+		if status, body := get(t, http.DefaultClient, mirror+deb); status != http.StatusOK || body != "!<arch>\n" {
+			t.Errorf("expect the pool file; got %d %q", status, body)
+		}
+	}
+	if got := up.count(deb); got != 1 {
+		t.Errorf("expect the pool file fetched once; got %d", got)
+	}
+
+	// Proxy requests, with the index revalidated
+	proxyURL, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	packages := "/ubuntu/dists/focal/main/binary-amd64/Packages"
+	for _, expect := range []string{"Package: hello\n", "Package: hello\n"} {
+		if status, body := get(t, client, up.url+packages); status != http.StatusOK || body != expect {
+			t.Errorf("expect %q; got %d %q", expect, status, body)
+		}
+	}
+	up.write(t, "ubuntu/dists/focal/main/binary-amd64/Packages", "Package: hello\nVersion: 2.10\n")
+	if _, body := get(t, client, up.url+packages); body != "Package: hello\nVersion: 2.10\n" {
+		t.Errorf("expect the updated index; got %q", body)
+	}
+	if got := up.count(packages); got != 3 {
+		t.Errorf("expect the index revalidated on every request; got %d requests", got)
+	}
+	if got := up.count(deb); got != 1 {
+		t.Errorf("expect the pool file served from the cache; got %d requests", got)
+	}
+
+	// Missing files are not cached
+	if status, _ := get(t, client, up.url+"/ubuntu/dists/focal/Release"); status != http.StatusNotFound {
+		t.Errorf("expect status %d; got %d", http.StatusNotFound, status)
+	}
+
+	// Signed metadata is verified before caching
+	inRelease := "/ubuntu/dists/focal/InRelease"
+	if status, _ := get(t, client, up.url+inRelease); status != http.StatusOK {
+		t.Errorf("expect status %d; got %d", http.StatusOK, status)
+	}
+	other := newUpstream(t)
+	up.write(t, "ubuntu/dists/focal/InRelease", other.sign("Suite: focal\nVersion: 2\n"))
+	if status, _ := get(t, client, up.url+inRelease); status != http.StatusBadGateway {
+		t.Errorf("expect status %d for an untrusted signature; got %d", http.StatusBadGateway, status)
+	}
+	cached, _ := os.ReadFile(filepath.Join(dir, strings.TrimPrefix(up.url, "http://"), filepath.FromSlash(inRelease)))
+	if strings.Contains(string(cached), "Version: 2") {
+		t.Errorf("expect the untrusted InRelease not cached")
+	}
+}
+
+func TestProxyRelease(t *testing.T) {
+	up := newUpstream(t)
+	up.write(t, "ubuntu/dists/focal/Release", "Suite: focal\n")
+	up.write(t, "ubuntu/dists/focal/Release.gpg", up.signDetached("Suite: focal\n"))
+	up.write(t, "ubuntu/dists/unsigned/Release", "Suite: unsigned\n")
+	dir := t.TempDir()
+	srv := httptest.NewServer(New(dir, &Options{KeyRing: up.keyRing}))
+	t.Cleanup(srv.Close)
+
+	mirror := srv.URL + "/" + strings.TrimPrefix(up.url, "http://")
+	release := "/ubuntu/dists/focal/Release"
+	if status, body := get(t, http.DefaultClient, mirror+release); status != http.StatusOK || body != "Suite: focal\n" {
+		t.Errorf("expect the Release file; got %d %q", status, body)
+	}
+	if status, _ := get(t, http.DefaultClient, mirror+release+".gpg"); status != http.StatusOK {
+		t.Errorf("expect status %d; got %d", http.StatusOK, status)
+	}
+	if got := up.count(release + ".gpg"); got != 1 {
+		t.Errorf("expect the signature fetched with the Release file once; got %d", got)
+	}
+
+	// A Release file out of sync with its signature is neither cached nor
+	// served
+	up.write(t, "ubuntu/dists/focal/Release", "Suite: focal\nVersion: 2\n")
+	if status, _ := get(t, http.DefaultClient, mirror+release); status != http.StatusBadGateway {
+		t.Errorf("expect status %d for a bad signature; got %d", http.StatusBadGateway, status)
+	}
+	cached, _ := os.ReadFile(filepath.Join(dir, strings.TrimPrefix(up.url, "http://"), filepath.FromSlash(release)))
+	if string(cached) != "Suite: focal\n" {
+		t.Errorf("expect the verified Release cached; got %q", cached)
+	}
+
+	// So is an unsigned Release file
+	unsigned := "/ubuntu/dists/unsigned/Release"
+	if status, _ := get(t, http.DefaultClient, mirror+unsigned); status == http.StatusOK {
+		t.Errorf("expect an error for an unsigned Release; got %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, strings.TrimPrefix(up.url, "http://"), filepath.FromSlash(unsigned))); !os.IsNotExist(err) {
+		t.Errorf("expect the unsigned Release not cached; got %v", err)
+	}
+}
+
+func TestProxyConcurrent(t *testing.T) {
+	up := newUpstream(t)
+	up.delay = 100 * time.Millisecond
+	srv := httptest.NewServer(New(t.TempDir(), &Options{KeyRing: up.keyRing}))
+	t.Cleanup(srv.Close)
+
+	mirror := srv.URL + "/" + strings.TrimPrefix(up.url, "http://")
+	deb := "/ubuntu/pool/main/h/hello/hello_2.10_amd64.deb"
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		bodies []string
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, body := get(t, http.DefaultClient, mirror+deb)
+			mu.Lock()
+			bodies = append(bodies, body)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if got := up.count(deb); got != 1 {
+		t.Errorf("expect concurrent requests fetched once; got %d", got)
+	}
+	expect := []string{"!<arch>\n", "!<arch>\n", "!<arch>\n", "!<arch>\n", "!<arch>\n", "!<arch>\n", "!<arch>\n", "!<arch>\n"}
+	if !cmp.Equal(expect, bodies) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, bodies))
+	}
+}
+
+func TestProxyStale(t *testing.T) {
+	up := newUpstream(t)
+	srv := httptest.NewServer(New(t.TempDir(), &Options{KeyRing: up.keyRing}))
+	t.Cleanup(srv.Close)
+
+	mirror := srv.URL + "/" + strings.TrimPrefix(up.url, "http://")
+	packages := "/ubuntu/dists/focal/main/binary-amd64/Packages"
+	deb := "/ubuntu/pool/main/h/hello/hello_2.10_amd64.deb"
+	if status, _ := get(t, http.DefaultClient, mirror+packages); status != http.StatusOK {
+		t.Fatalf("expect status %d; got %d", http.StatusOK, status)
+	}
+
+	// The cached index is served while upstream is unreachable
+	up.srv.Close()
+	if status, body := get(t, http.DefaultClient, mirror+packages); status != http.StatusOK || body != "Package: hello\n" {
+		t.Errorf("expect the cached index; got %d %q", status, body)
+	}
+	if status, _ := get(t, http.DefaultClient, mirror+deb); status != http.StatusBadGateway {
+		t.Errorf("expect status %d for a file not cached; got %d", http.StatusBadGateway, status)
+	}
+}
@@ -3,13 +3,13 @@ package release
 import (
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/anfernee/goapt/pkg/common"
 )
 
 const (
@@ -23,18 +23,35 @@ type VerifyOptions struct {
 	KeyPath      string
 	Armored      bool
 	AutoDiscover bool
+	// TrustedPath and TrustedDir are the keyring and keyring directory of
+	// the known keys, e.g. Dir::Etc::trusted and Dir::Etc::trustedparts.
+	// They default to /etc/apt/trusted.gpg and /etc/apt/trusted.gpg.d.
+	TrustedPath string
+	TrustedDir  string
+	// Fetcher opens http/https urls.
+	Fetcher *common.Fetcher
 }
 
 // VerifyWithOptions verifies a local file or http/https url with given public GNG
 // public key.
 func VerifyWithOptions(path string, options *VerifyOptions) (string, error) {
-	cleartext, err := loadClearText(path)
+	if options == nil {
+		options = &VerifyOptions{AutoDiscover: true}
+	}
+	cleartext, err := loadClearText(options.Fetcher, path)
 	if err != nil {
 		return "", err
 	}
 
-	if options == nil || options.AutoDiscover {
-		return verifyWithKnownKeys(cleartext)
+	if options.AutoDiscover {
+		trustedPath, trustedDir := options.TrustedPath, options.TrustedDir
+		if trustedPath == "" {
+			trustedPath = defaultTrustedPath
+		}
+		if trustedDir == "" {
+			trustedDir = defaultTrustedDir
+		}
+		return verifyWithKnownKeys(cleartext, trustedPath, trustedDir)
 	}
 
 	return verifyWithKeyRing(cleartext, options.KeyPath, options.Armored)
@@ -46,23 +63,81 @@ func Verify(path string) (string, error) {
 	return VerifyWithOptions(path, nil)
 }
 
-// loadClearText loads cleartext message from path or url.
-func loadClearText(pathOrUrl string) ([]byte, error) {
-	if !strings.HasPrefix(pathOrUrl, "http") {
-		return ioutil.ReadFile(pathOrUrl)
+// VerifyCleartext verifies a cleartext signed message, e.g. an InRelease
+// file, against the keys of keyRing, and returns the signed text.
+func VerifyCleartext(cleartext []byte, keyRing *crypto.KeyRing) (string, error) {
+	return helper.VerifyCleartextMessage(keyRing, string(cleartext), crypto.GetUnixTime())
+}
+
+// VerifyDetached verifies the armored or binary detached signature of
+// content, e.g. the Release.gpg file of a Release file, against the keys of
+// keyRing.
+func VerifyDetached(content, signature []byte, keyRing *crypto.KeyRing) error {
+	var (
+		sig *crypto.PGPSignature
+		err error
+	)
+	if strings.HasPrefix(strings.TrimSpace(string(signature)), "-----BEGIN PGP SIGNATURE") {
+		sig, err = crypto.NewPGPSignatureFromArmored(string(signature))
+		if err != nil {
+			return err
+		}
+	} else {
+		sig = crypto.NewPGPSignature(signature)
 	}
+	return keyRing.VerifyDetached(crypto.NewPlainMessage(content), sig, crypto.GetUnixTime())
+}
 
-	resp, err := http.Get(pathOrUrl)
+// LoadKeyRing loads the binary key at path, and the binary *.gpg and armored
+// *.asc keys under dir, into a single keyring, like the trusted keys of apt.
+// Missing or invalid key files are skipped.
+func LoadKeyRing(path, dir string) (*crypto.KeyRing, error) {
+	keyRing, err := crypto.NewKeyRing(nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch %q, status: %v", pathOrUrl, resp.Status)
+	add := func(keyFile string, armored bool) {
+		kr, err := loadKeyRing(keyFile, armored)
+		if err != nil {
+			return
+		}
+		for _, key := range kr.GetKeys() {
+			keyRing.AddKey(key)
+		}
+	}
+
+	add(path, false)
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		for _, entry := range entries {
+			switch {
+			case entry.IsDir():
+			case strings.HasSuffix(entry.Name(), ".gpg"):
+				add(filepath.Join(dir, entry.Name()), false)
+			case strings.HasSuffix(entry.Name(), ".asc"):
+				add(filepath.Join(dir, entry.Name()), true)
+			}
+		}
+	}
+	return keyRing, nil
+}
+
+// LoadKeyFile loads the key file at path, armored if named *.asc and binary
+// otherwise, like the trusted keys of apt.
+func LoadKeyFile(path string) (*crypto.KeyRing, error) {
+	return loadKeyRing(path, strings.HasSuffix(path, ".asc"))
+}
+
+// loadClearText loads cleartext message from path or url opened with f.
+func loadClearText(f *common.Fetcher, pathOrUrl string) ([]byte, error) {
+	rc, err := f.Open(pathOrUrl)
+	if err != nil {
+		return nil, err
 	}
+	defer rc.Close()
 
-	return ioutil.ReadAll(resp.Body)
+	return ioutil.ReadAll(rc)
 }
 
 // loadKeyRing loads keyring from a key path. armored specifies whether the key file
@@ -86,16 +161,16 @@ func loadKeyRing(keyPath string, armored bool) (*crypto.KeyRing, error) {
 	return crypto.NewKeyRing(pubkey)
 }
 
-// verifyWithKnownKeys verifies clear text message with known keys saved in /etc/apt/trusted.gpg
-// and under /etc/apt/trusted.gpg.d
-func verifyWithKnownKeys(text []byte) (string, error) {
-	keyFiles := []string{defaultTrustedPath}
+// verifyWithKnownKeys verifies clear text message with known keys saved in trustedPath, e.g.
+// /etc/apt/trusted.gpg, and under trustedDir, e.g. /etc/apt/trusted.gpg.d
+func verifyWithKnownKeys(text []byte, trustedPath, trustedDir string) (string, error) {
+	keyFiles := []string{trustedPath}
 
-	entries, err := os.ReadDir(defaultTrustedDir)
+	entries, err := os.ReadDir(trustedDir)
 	if err == nil {
 		for _, entry := range entries {
 			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".gpg") {
-				keyFiles = append(keyFiles, filepath.Join(defaultTrustedDir, entry.Name()))
+				keyFiles = append(keyFiles, filepath.Join(trustedDir, entry.Name()))
 			}
 		}
 	}
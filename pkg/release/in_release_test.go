@@ -1,9 +1,12 @@
 package release
 
 import (
-	"bufio"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/anfernee/goapt/pkg/server"
 )
 
 func TestVerifyWithOptions(t *testing.T) {
@@ -42,32 +45,43 @@ func TestVerifyWithOptions(t *testing.T) {
 }
 
 func TestUbuntuKnwonPath(t *testing.T) {
-	if !isUbuntu() {
-		t.Skip("the test only works in ubuntu")
-	}
-
-	url := "http://us.archive.ubuntu.com/ubuntu/dists/focal/InRelease"
-	_, err := Verify(url)
+	// Serve the InRelease file of a repository, and trust its key like apt.
+	dir := t.TempDir()
+	inRelease, err := os.ReadFile("testdata/inrelease.txt")
 	if err != nil {
-		t.Errorf("failed to verify %s on ubuntu: %q.\nTry to run 'apt update' to see if it works.", url, err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-}
-
-func isUbuntu() bool {
-	f, err := os.Open("/etc/lsb-release")
+	key, err := os.ReadFile("testdata/bazel-archive-keyring.gpg")
 	if err != nil {
-		return false
+		t.Fatalf("unexpected error: %v", err)
 	}
-	defer f.Close()
-
-	r := bufio.NewReader(f)
-	for {
-		line, _, err := r.ReadLine()
-		if err != nil {
-			return false
+	for name, content := range map[string][]byte{
+		"repo/dists/stable/InRelease":     inRelease,
+		"trusted.gpg.d/bazel-archive.gpg": key,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
-		if string(line) == "DISTRIB_ID=Ubuntu" {
-			return true
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
 	}
+	srv := httptest.NewServer(server.New(filepath.Join(dir, "repo"), nil))
+	t.Cleanup(srv.Close)
+
+	url := srv.URL + "/dists/stable/InRelease"
+	options := &VerifyOptions{
+		AutoDiscover: true,
+		TrustedPath:  filepath.Join(dir, "trusted.gpg"),
+		TrustedDir:   filepath.Join(dir, "trusted.gpg.d"),
+	}
+	if _, err := VerifyWithOptions(url, options); err != nil {
+		t.Errorf("failed to verify %s: %q", url, err)
+	}
+
+	options.TrustedDir = filepath.Join(dir, "missing")
+	if _, err := VerifyWithOptions(url, options); err == nil {
+		t.Errorf("expect err without a trusted key; got nil")
+	}
 }
@@ -2,19 +2,24 @@ package release
 
 import (
 	"bufio"
+	"fmt"
 	"io"
-	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/anfernee/goapt/pkg/common"
 )
 
 type Checksum string
 
 var (
-	md5  Checksum = "MD5Sum"
-	sha1 Checksum = "SHA1"
+	md5    Checksum = "MD5Sum"
+	sha1   Checksum = "SHA1"
+	sha256 Checksum = "SHA256"
+	sha512 Checksum = "SHA512"
 )
 
 // Release is a deb type source entry hosted via web server.
@@ -30,25 +35,42 @@ type Release struct {
 	Components  []string
 	Description string
 	Files       map[string]*File
+
+	// NotAutomatic and ButAutomaticUpgrades lower the default pin priority
+	// of the release, e.g. for backports. Check apt_preferences(5).
+	NotAutomatic         bool
+	ButAutomaticUpgrades bool
 }
 
 // File is a single file in a deb release.
 type File struct {
-	Name string
-	Size int
-	MD5  string
-	SHA1 string
+	Name   string
+	Size   int
+	MD5    string
+	SHA1   string
+	SHA256 string
+	SHA512 string
 }
 
 // Load loads a release from a url.
 func Load(url string) (*Release, error) {
-	resp, err := http.Get(url)
+	return LoadWith(nil, url)
+}
+
+// LoadWith loads a release from a url opened with f.
+func LoadWith(f *common.Fetcher, url string) (*Release, error) {
+	rc, err := f.Open(url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer rc.Close()
+
+	return parse(rc)
+}
 
-	return parse(resp.Body)
+// Parse parses a Release file, or the verified text of an InRelease file.
+func Parse(r io.Reader) (*Release, error) {
+	return parse(r)
 }
 
 func parse(r io.Reader) (*Release, error) {
@@ -100,12 +122,23 @@ func parse(r io.Reader) (*Release, error) {
 			release.Archs = strings.Split(trim(line, "Architectures:"), " ")
 		case strings.HasPrefix(line, "Components:"):
 			release.Components = strings.Split(trim(line, "Components:"), " ")
+		case strings.HasPrefix(line, "NotAutomatic:"):
+			release.NotAutomatic = trim(line, "NotAutomatic:") == "yes"
+		case strings.HasPrefix(line, "ButAutomaticUpgrades:"):
+			release.ButAutomaticUpgrades = trim(line, "ButAutomaticUpgrades:") == "yes"
 		case strings.HasPrefix(line, "Date:"):
 			release.Date, _ = time.Parse(time.RFC1123, trim(line, "Date:"))
 		case line == "MD5Sum:":
 			checksum = md5
 		case line == "SHA1:":
 			checksum = sha1
+		case line == "SHA256:":
+			checksum = sha256
+		case line == "SHA512:":
+			checksum = sha512
+		case strings.HasSuffix(line, ":") && !strings.Contains(line, " "):
+			// Another section, e.g. "MD5Sum-Old:"
+			checksum = ""
 		}
 
 	}
@@ -113,6 +146,75 @@ func parse(r io.Reader) (*Release, error) {
 	return release, nil
 }
 
+// String formats the release as a Release file, which parse reads back.
+// Empty fields are omitted, and files are sorted by name, so the output is
+// deterministic.
+func (r *Release) String() string {
+	var b strings.Builder
+	field := func(key, value string) {
+		if value != "" {
+			b.WriteString(key + ": " + value + "\n")
+		}
+	}
+	yes := func(v bool) string {
+		if v {
+			return "yes"
+		}
+		return ""
+	}
+
+	field("Origin", r.Origin)
+	field("Label", r.Label)
+	field("Suite", r.Suite)
+	field("Version", r.Version)
+	field("Codename", r.Codename)
+	if !r.Date.IsZero() {
+		field("Date", r.Date.UTC().Format(time.RFC1123))
+	}
+	field("NotAutomatic", yes(r.NotAutomatic))
+	field("ButAutomaticUpgrades", yes(r.ButAutomaticUpgrades))
+	field("Architectures", strings.Join(r.Archs, " "))
+	field("Components", strings.Join(r.Components, " "))
+	field("Description", r.Description)
+
+	var (
+		names []string
+		width int
+	)
+	for name, file := range r.Files {
+		names = append(names, name)
+		if w := len(strconv.Itoa(file.Size)); w > width {
+			width = w
+		}
+	}
+	sort.Strings(names)
+
+	for _, section := range []struct {
+		checksum Checksum
+		sum      func(*File) string
+	}{
+		{md5, func(f *File) string { return f.MD5 }},
+		{sha1, func(f *File) string { return f.SHA1 }},
+		{sha256, func(f *File) string { return f.SHA256 }},
+		{sha512, func(f *File) string { return f.SHA512 }},
+	} {
+		header := false
+		for _, name := range names {
+			file := r.Files[name]
+			sum := section.sum(file)
+			if sum == "" {
+				continue
+			}
+			if !header {
+				b.WriteString(string(section.checksum) + ":\n")
+				header = true
+			}
+			fmt.Fprintf(&b, " %s %*d %s\n", sum, width, file.Size, name)
+		}
+	}
+	return b.String()
+}
+
 // addOrUpdate adds or updates a file entry from a line in release file.
 //
 // Example:
@@ -137,5 +239,9 @@ func addOrUpdate(columes []string, files map[string]*File, checksum Checksum) {
 		file.MD5 = columes[0]
 	case sha1:
 		file.SHA1 = columes[0]
+	case sha256:
+		file.SHA256 = columes[0]
+	case sha512:
+		file.SHA512 = columes[0]
 	}
 }
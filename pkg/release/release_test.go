@@ -1,7 +1,12 @@
 package release
 
 import (
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha256"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -52,3 +57,54 @@ func TestReleaseParse(t *testing.T) {
 		t.Errorf("unexpected diff: %v", cmp.Diff(expected, r))
 	}
 }
+
+func TestReleaseParseSHA256(t *testing.T) {
+	index := []byte("Package: hello\nVersion: 2.10-2\nArchitecture: amd64\n")
+	sum := func(h crypto.Hash) string {
+		w := h.New()
+		w.Write(index)
+		return fmt.Sprintf("%x", w.Sum(nil))
+	}
+	text := fmt.Sprintf("Suite: focal\nMD5Sum:\n %s %d main/binary-amd64/Packages\nSHA256:\n %s %d main/binary-amd64/Packages\n",
+		sum(crypto.MD5), len(index), sum(crypto.SHA256), len(index))
+	r, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	expected := map[string]*File{
+		"main/binary-amd64/Packages": {
+			Name:   "main/binary-amd64/Packages",
+			Size:   len(index),
+			MD5:    sum(crypto.MD5),
+			SHA256: sum(crypto.SHA256),
+		},
+	}
+	if !cmp.Equal(expected, r.Files) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expected, r.Files))
+	}
+}
+
+func TestReleaseString(t *testing.T) {
+	d, _ := os.Open("testdata/example-focal.txt")
+	expected, err := parse(d)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	expected.NotAutomatic = true
+
+	s := expected.String()
+	r, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if !cmp.Equal(expected, r) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expected, r))
+	}
+	if again := r.String(); again != s {
+		t.Errorf("unexpected diff: %v", cmp.Diff(s, again))
+	}
+	if !strings.HasPrefix(s, "Origin: Ubuntu\nLabel: Ubuntu\nSuite: focal\n") {
+		t.Errorf("unexpected release:\n%s", s)
+	}
+}
@@ -0,0 +1,66 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+)
+
+// LoadSigningKey loads the private key at keyPath, armored or binary, into
+// a keyring to sign with. A locked key is unlocked with passphrase.
+func LoadSigningKey(keyPath string, passphrase []byte) (*crypto.KeyRing, error) {
+	d, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *crypto.Key
+	if bytes.HasPrefix(bytes.TrimSpace(d), []byte("-----BEGIN PGP")) {
+		key, err = crypto.NewKeyFromArmored(string(d))
+	} else {
+		key, err = crypto.NewKey(d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	if !key.IsPrivate() {
+		return nil, fmt.Errorf("%s: not a private key", keyPath)
+	}
+
+	locked, err := key.IsLocked()
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("%s: key is locked, a passphrase is required", keyPath)
+		}
+		if key, err = key.Unlock(passphrase); err != nil {
+			return nil, fmt.Errorf("%s: %w", keyPath, err)
+		}
+	}
+	return crypto.NewKeyRing(key)
+}
+
+// Sign signs the content of a Release file with the private key of keyRing,
+// and returns the cleartext signed InRelease file and the armored detached
+// signature, the Release.gpg file.
+func Sign(content []byte, keyRing *crypto.KeyRing) (inRelease, releaseGPG []byte, err error) {
+	signed, err := helper.SignCleartextMessage(keyRing, string(content))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signature, err := keyRing.SignDetached(crypto.NewPlainMessage(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	armored, err := signature.GetArmored()
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(signed), []byte(armored + "\n"), nil
+}
@@ -0,0 +1,107 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+func TestSign(t *testing.T) {
+	key, err := crypto.GenerateKey("goapt", "goapt@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	locked, err := key.Lock([]byte("secret"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	armored, err := locked.Armor()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	binary, err := key.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	public, err := key.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"locked.asc": armored, "unlocked.gpg": string(binary), "public.asc": public} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	errTests := []struct {
+		desc       string
+		path       string
+		passphrase string
+	}{
+		{desc: "missing passphrase", path: "locked.asc"},
+		{desc: "wrong passphrase", path: "locked.asc", passphrase: "wrong"},
+		{desc: "public key", path: "public.asc"},
+		{desc: "missing key", path: "missing.asc"},
+	}
+	for _, test := range errTests {
+		if _, err := LoadSigningKey(filepath.Join(dir, test.path), []byte(test.passphrase)); err == nil {
+			t.Errorf("%s: expect err; got nil", test.desc)
+		}
+	}
+
+	publicKey, err := crypto.NewKeyFromArmored(public)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	publicRing, err := crypto.NewKeyRing(publicKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile("testdata/example-focal.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range []struct {
+		path       string
+		passphrase string
+	}{
+		{path: "locked.asc", passphrase: "secret"},
+		{path: "unlocked.gpg"},
+	} {
+		keyRing, err := LoadSigningKey(filepath.Join(dir, test.path), []byte(test.passphrase))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.path, err)
+		}
+		inRelease, releaseGPG, err := Sign(content, keyRing)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.path, err)
+		}
+
+		text, err := VerifyCleartext(inRelease, publicRing)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.path, err)
+		}
+		if text != string(content) {
+			t.Errorf("%s: expect the signed text to be the release; got %q", test.path, text)
+		}
+
+		if err := VerifyDetached(content, releaseGPG, publicRing); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+		}
+		if err := VerifyDetached(append(content, '\n'), releaseGPG, publicRing); err == nil {
+			t.Errorf("%s: expect err for a modified release; got nil", test.path)
+		}
+		signature, err := crypto.NewPGPSignatureFromArmored(string(releaseGPG))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.path, err)
+		}
+		if err := VerifyDetached(content, signature.GetBinary(), publicRing); err != nil {
+			t.Errorf("%s: unexpected error for a binary signature: %v", test.path, err)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Package repo publishes apt repositories: it builds the indices and the
+// Release file of a suite from a pool of .deb files, like reprepro or
+// apt-ftparchive.
+package repo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/anfernee/goapt/pkg/common"
+	"github.com/anfernee/goapt/pkg/deb"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// DefaultComponent is the component of the packages directly under the
+// pool directory.
+const DefaultComponent = "main"
+
+// IndexCompressions are the compressions every Packages index is written
+// in, the uncompressed one first.
+var IndexCompressions = []string{"", ".gz", ".xz"}
+
+// Options configures the suite built by Build.
+type Options struct {
+	// Suite is the name of the suite, "stable" if empty.
+	Suite string
+	// Codename is the codename of the suite, the suite if empty.
+	Codename    string
+	Origin      string
+	Label       string
+	Version     string
+	Description string
+	// Date is the date of the release. Zero omits it, so identical pools
+	// build identical suites.
+	Date time.Time
+	// Component puts every package into a single component. If empty, the
+	// component of a package is its first directory under the pool, like
+	// pool/main/h/hello, or DefaultComponent.
+	Component string
+	// Architectures are the architectures of the suite. Defaults to the
+	// architectures of the packages. Architecture independent packages are
+	// published in the index of every architecture.
+	Architectures []string
+	// KeyRing holds the private key signing the release into the InRelease
+	// and Release.gpg files. Nil leaves the release unsigned. Signatures
+	// carry the time of signing, so signed suites are not deterministic.
+	KeyRing *crypto.KeyRing
+}
+
+// entry is a package of the pool.
+type entry struct {
+	component string
+	pkg       pkg.Package
+	stanza    *common.Paragraph
+}
+
+// Build scans the .deb files of pool, a directory under root, and writes
+// the Packages indices of every component and architecture, each in
+// IndexCompressions, and the Release file listing them, signed if KeyRing
+// is set, under root/dists/<suite>. The Filename of the packages is
+// relative to root. It returns the release.
+func Build(root, pool string, options *Options) (*release.Release, error) {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	if o.Suite == "" {
+		o.Suite = "stable"
+	}
+	if o.Codename == "" {
+		o.Codename = o.Suite
+	}
+
+	entries, err := scan(root, pool, o.Component)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no .deb file found in %s", pool)
+	}
+
+	archs := o.Architectures
+	if len(archs) == 0 {
+		archs = architectures(entries)
+	}
+	var components []string
+	byComponent := map[string][]*entry{}
+	for _, e := range entries {
+		if byComponent[e.component] == nil {
+			components = append(components, e.component)
+		}
+		byComponent[e.component] = append(byComponent[e.component], e)
+	}
+	sort.Strings(components)
+
+	r := &release.Release{
+		Origin:      o.Origin,
+		Label:       o.Label,
+		Suite:       o.Suite,
+		Version:     o.Version,
+		Codename:    o.Codename,
+		Date:        o.Date,
+		Archs:       archs,
+		Components:  components,
+		Description: o.Description,
+		Files:       map[string]*release.File{},
+	}
+
+	dists := filepath.Join(root, "dists", o.Suite)
+	for _, component := range components {
+		for _, arch := range archs {
+			var index bytes.Buffer
+			for _, e := range byComponent[component] {
+				if e.pkg.Arch == arch || (e.pkg.Arch == pkg.ArchAll && arch != pkg.ArchAll) {
+					index.WriteString(e.stanza.String() + "\n")
+				}
+			}
+
+			for _, ext := range IndexCompressions {
+				name := path.Join(component, "binary-"+arch, "Packages"+ext)
+				data, err := compress(name, index.Bytes())
+				if err != nil {
+					return nil, err
+				}
+				if err := writeFile(filepath.Join(dists, name), data); err != nil {
+					return nil, err
+				}
+				r.Files[name], _ = fileOf(name, bytes.NewReader(data))
+			}
+		}
+	}
+
+	content := []byte(r.String())
+	if err := writeFile(filepath.Join(dists, "Release"), content); err != nil {
+		return nil, err
+	}
+	if o.KeyRing != nil {
+		inRelease, releaseGPG, err := release.Sign(content, o.KeyRing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign the release: %w", err)
+		}
+		if err := writeFile(filepath.Join(dists, "InRelease"), inRelease); err != nil {
+			return nil, err
+		}
+		if err := writeFile(filepath.Join(dists, "Release.gpg"), releaseGPG); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// scan reads the control files of the .deb files of pool, sorted by name,
+// version, architecture and file name.
+func scan(root, pool, component string) ([]*entry, error) {
+	var ret []*entry
+	err := filepath.WalkDir(pool, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() || !strings.HasSuffix(p, ".deb") {
+			return nil
+		}
+
+		filename, err := filepath.Rel(root, p)
+		if err != nil || strings.HasPrefix(filename, "..") {
+			return fmt.Errorf("%s is not under %s", p, root)
+		}
+		e := &entry{component: component}
+		if e.component == "" {
+			e.component = DefaultComponent
+			if rel, _ := filepath.Rel(pool, p); strings.Contains(filepath.ToSlash(rel), "/") {
+				e.component = strings.Split(filepath.ToSlash(rel), "/")[0]
+			}
+		}
+
+		d, err := deb.Open(p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		file, err := fileOf(filename, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		e.pkg = d.Package()
+		e.pkg.Filename = filepath.ToSlash(filename)
+		e.stanza = stanza(d.Control, e.pkg.Filename, file)
+		ret = append(ret, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].pkg, ret[j].pkg
+		switch {
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Version != b.Version:
+			return version.Compare(a.Version, b.Version) < 0
+		case a.Arch != b.Arch:
+			return a.Arch < b.Arch
+		}
+		return a.Filename < b.Filename
+	})
+	return ret, nil
+}
+
+// stanza returns the stanza of a package in a Packages index: its control
+// file with the location and the hashes of the .deb file before the
+// description, like apt-ftparchive.
+func stanza(control *common.Paragraph, filename string, file *release.File) *common.Paragraph {
+	ret := common.NewParagraph()
+	add := func() {
+		ret.Set("Filename", filename)
+		ret.Set("Size", strconv.Itoa(file.Size))
+		ret.Set("MD5sum", file.MD5)
+		ret.Set("SHA1", file.SHA1)
+		ret.Set("SHA256", file.SHA256)
+		ret.Set("SHA512", file.SHA512)
+	}
+	for _, key := range control.Keys {
+		if key == "Description" {
+			add()
+		}
+		ret.Set(key, control.Get(key))
+	}
+	if ret.Get("Filename") == "" {
+		add()
+	}
+	return ret
+}
+
+// architectures returns the sorted architectures of the packages, or "all"
+// if every package is architecture independent.
+func architectures(entries []*entry) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.pkg.Arch != pkg.ArchAll && !seen[e.pkg.Arch] {
+			seen[e.pkg.Arch] = true
+			ret = append(ret, e.pkg.Arch)
+		}
+	}
+	if len(ret) == 0 {
+		return []string{pkg.ArchAll}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// fileOf returns the size and the hashes of the content of r.
+func fileOf(name string, r io.Reader) (*release.File, error) {
+	h1, h2, h3, h4 := md5.New(), sha1.New(), sha256.New(), sha512.New()
+	n, err := io.Copy(io.MultiWriter(h1, h2, h3, h4), r)
+	if err != nil {
+		return nil, err
+	}
+	return &release.File{
+		Name:   name,
+		Size:   int(n),
+		MD5:    fmt.Sprintf("%x", h1.Sum(nil)),
+		SHA1:   fmt.Sprintf("%x", h2.Sum(nil)),
+		SHA256: fmt.Sprintf("%x", h3.Sum(nil)),
+		SHA512: fmt.Sprintf("%x", h4.Sum(nil)),
+	}, nil
+}
+
+// compress compresses data according to the extension of name.
+func compress(name string, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := common.Compress(name, &b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeFile writes data to path, creating its directory.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,224 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/release"
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeTestDeb writes a .deb file with the given control file and no data.
+func writeTestDeb(t *testing.T, path, control string) {
+	t.Helper()
+
+	member := func(b *bytes.Buffer, name string, content []byte) {
+		fmt.Fprintf(b, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, 0, 0, 0, "100644", len(content))
+		b.Write(content)
+		if len(content)%2 == 1 {
+			b.WriteByte('\n')
+		}
+	}
+	targz := func(files map[string]string) []byte {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		tw := tar.NewWriter(zw)
+		for name, content := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		zw.Close()
+		return b.Bytes()
+	}
+
+	var b bytes.Buffer
+	b.WriteString("!<arch>\n")
+	member(&b, "debian-binary", []byte("2.0\n"))
+	member(&b, "control.tar.gz", targz(map[string]string{"./control": control}))
+	member(&b, "data.tar.gz", targz(nil))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// newTestPool creates a repository root with a pool of two components.
+func newTestPool(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	hello, err := os.ReadFile("../deb/testdata/hello_1.0-1_amd64.gzip.deb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "pool/main/h/hello"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pool/main/h/hello/hello_1.0-1_amd64.deb"), hello, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeTestDeb(t, filepath.Join(root, "pool/main/h/hello/hello-doc_1.0-1_all.deb"),
+		"Package: hello-doc\nVersion: 1.0-1\nArchitecture: all\nDescription: hello documentation\n")
+	writeTestDeb(t, filepath.Join(root, "pool/contrib/t/tool/tool_2.0_i386.deb"),
+		"Package: tool\nVersion: 2.0\nArchitecture: i386\nDepends: hello\n")
+	return root
+}
+
+func TestBuild(t *testing.T) {
+	root := newTestPool(t)
+	date := time.Date(2020, time.September, 13, 12, 26, 40, 0, time.UTC)
+
+	r, err := Build(root, filepath.Join(root, "pool"), &Options{Suite: "stable", Origin: "Example", Date: date})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := []string{"amd64", "i386"}; !cmp.Equal(expect, r.Archs) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, r.Archs))
+	}
+	if expect := []string{"contrib", "main"}; !cmp.Equal(expect, r.Components) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, r.Components))
+	}
+
+	// The Release file reads back, and lists the hashes of every index
+	f, err := os.Open(filepath.Join(root, "dists/stable/Release"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	parsed, err := release.Parse(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(r, parsed) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(r, parsed))
+	}
+	if len(parsed.Files) != 12 {
+		t.Errorf("expect 12 indices; got %d", len(parsed.Files))
+	}
+	for name, file := range parsed.Files {
+		content, err := os.ReadFile(filepath.Join(root, "dists/stable", name))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if got := fmt.Sprintf("%x", sha512.Sum512(content)); got != file.SHA512 || len(content) != file.Size {
+			t.Errorf("%s: expect SHA512 %s; got %s", name, file.SHA512, got)
+		}
+		if file.MD5 == "" || file.SHA1 == "" || file.SHA256 == "" {
+			t.Errorf("%s: expect every hash; got %+v", name, file)
+		}
+	}
+
+	tests := []struct {
+		index  string
+		expect []string
+	}{
+		{index: "main/binary-amd64/Packages", expect: []string{"hello:amd64", "hello-doc:all"}},
+		{index: "main/binary-i386/Packages.gz", expect: []string{"hello-doc:all"}},
+		{index: "contrib/binary-amd64/Packages.xz", expect: nil},
+		{index: "contrib/binary-i386/Packages.xz", expect: []string{"tool:i386"}},
+	}
+	for _, test := range tests {
+		pkgs, err := pkg.Load(filepath.Join(root, "dists/stable", test.index))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.index, err)
+		}
+		var got []string
+		for _, p := range pkgs {
+			got = append(got, p.Name+":"+p.Arch)
+			if p.Filename == "" || p.SHA256 == "" {
+				t.Errorf("%s: expect the location of %s; got %q", test.index, p.Name, p.Control.String())
+			}
+			content, err := os.ReadFile(filepath.Join(root, p.Filename))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.index, err)
+			}
+			if got := fmt.Sprintf("%x", sha256.Sum256(content)); got != p.SHA256 {
+				t.Errorf("%s: expect SHA256 %s of %s; got %s", test.index, p.SHA256, p.Name, got)
+			}
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.index, cmp.Diff(test.expect, got))
+		}
+	}
+
+	pkgs, _ := pkg.Load(filepath.Join(root, "dists/stable/main/binary-amd64/Packages"))
+	if keys := pkgs[0].Control.Keys; keys[len(keys)-1] != "Description" || pkgs[0].Filename != "pool/main/h/hello/hello_1.0-1_amd64.deb" {
+		t.Errorf("unexpected stanza:\n%s", pkgs[0].Control.String())
+	}
+}
+
+func TestBuildDeterministic(t *testing.T) {
+	read := func() map[string]string {
+		root := newTestPool(t)
+		if _, err := Build(root, filepath.Join(root, "pool"), &Options{Component: "main", Architectures: []string{"amd64"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ret := map[string]string{}
+		filepath.Walk(filepath.Join(root, "dists"), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				content, _ := os.ReadFile(path)
+				rel, _ := filepath.Rel(root, path)
+				ret[rel] = string(content)
+			}
+			return nil
+		})
+		return ret
+	}
+
+	first, second := read(), read()
+	if !cmp.Equal(first, second) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(first, second))
+	}
+	if len(first) != 4 {
+		t.Errorf("expect a Release and 3 indices; got %d files", len(first))
+	}
+}
+
+func TestBuildSigned(t *testing.T) {
+	key, err := crypto.GenerateKey("goapt", "goapt@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyRing, err := crypto.NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	root := newTestPool(t)
+	if _, err := Build(root, filepath.Join(root, "pool"), &Options{KeyRing: keyRing}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, "dists/stable/Release"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inRelease, err := os.ReadFile(filepath.Join(root, "dists/stable/InRelease"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text, err := release.VerifyCleartext(inRelease, keyRing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != string(content) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(string(content), text))
+	}
+	if _, err := os.Stat(filepath.Join(root, "dists/stable/Release.gpg")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
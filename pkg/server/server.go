@@ -0,0 +1,332 @@
+// Package server serves a published or mirrored apt repository, a static
+// directory, over HTTP, like a web server configured for apt would.
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anfernee/goapt/pkg/common"
+)
+
+// byHash matches the by-hash path of a file, e.g.
+// dists/focal/main/binary-amd64/by-hash/SHA256/<hash>.
+var byHash = regexp.MustCompile(`^(.*)/by-hash/(MD5Sum|SHA1|SHA256|SHA512)/([0-9a-f]+)$`)
+
+// hashes are the hash functions of the by-hash paths.
+var hashes = map[string]func() hash.Hash{
+	"MD5Sum": md5.New,
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// contentTypes are the content types of the files of a repository by
+// extension. Files without an extension, like Release and Packages, are
+// text.
+var contentTypes = map[string]string{
+	".deb":  "application/vnd.debian.binary-package",
+	".udeb": "application/vnd.debian.binary-package",
+	".ddeb": "application/vnd.debian.binary-package",
+	".dsc":  "text/plain; charset=utf-8",
+	".gz":   "application/gzip",
+	".xz":   "application/x-xz",
+	".zst":  "application/zstd",
+	".bz2":  "application/x-bzip2",
+	".gpg":  "application/pgp-signature",
+	".asc":  "application/pgp-keys",
+	".json": "application/json",
+	".txt":  "text/plain; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+}
+
+// compressions are the extensions of the compressed variants generated on
+// the fly.
+var compressions = []string{".gz", ".xz", ".zst"}
+
+// defaultCacheSize is the default bound of the content cached in memory.
+const defaultCacheSize = 64 << 20
+
+// Options configures a Server.
+type Options struct {
+	// Compress generates the compressed variants of files missing from the
+	// repository, e.g. Packages.xz from Packages, on the fly.
+	Compress bool
+	// CacheSize bounds the bytes of the generated content, the compressed
+	// variants and the hashes of the files, kept in memory. The least
+	// recently used content is evicted first. Defaults to 64 MiB.
+	CacheSize int64
+}
+
+// Server serves the repository in a directory. Besides the files of the
+// directory, it serves:
+//
+//   - the by-hash paths of the files, e.g.
+//     dists/focal/main/binary-amd64/by-hash/SHA256/<hash>, unless published;
+//   - if enabled, the compressed variants of the files.
+//
+// Every file supports Range and conditional requests.
+type Server struct {
+	root    http.FileSystem
+	options Options
+
+	mu sync.Mutex
+	// cache caches the generated content by name, and the hashes of the
+	// files by name and hash, as long as the files are unmodified, up to
+	// CacheSize bytes.
+	cache map[string]*list.Element
+	// lru lists the cached content, the most recently used first.
+	lru *list.List
+	// size is the number of bytes cached.
+	size int64
+}
+
+// cached is content computed from a file.
+type cached struct {
+	key     string
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// New returns a server serving the repository in dir.
+func New(dir string, options *Options) *Server {
+	s := &Server{
+		root:  http.Dir(dir),
+		cache: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+	if options != nil {
+		s.options = *options
+	}
+	if s.options.CacheSize == 0 {
+		s.options.CacheSize = defaultCacheSize
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	f, err := s.root.Open(name)
+	if err == nil {
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		if info.IsDir() {
+			http.FileServer(s.root).ServeHTTP(w, r)
+			return
+		}
+		setContentType(w, name)
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		serveError(w, err)
+		return
+	}
+
+	if m := byHash.FindStringSubmatch(name); m != nil {
+		s.serveByHash(w, r, m[1], m[2], m[3])
+		return
+	}
+	if s.options.Compress {
+		for _, ext := range compressions {
+			if strings.HasSuffix(name, ext) {
+				s.serveCompressed(w, r, name, ext)
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// serveByHash serves the file of dir whose hash, of the algorithm, is sum.
+func (s *Server) serveByHash(w http.ResponseWriter, r *http.Request, dir, algorithm, sum string) {
+	d, err := s.root.Open(dir)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	infos, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		name := path.Join(dir, info.Name())
+		got, err := s.compute(name+" "+algorithm, name, info, func(f io.Reader) ([]byte, error) {
+			h := hashes[algorithm]()
+			if _, err := io.Copy(h, f); err != nil {
+				return nil, err
+			}
+			return []byte(fmt.Sprintf("%x", h.Sum(nil))), nil
+		})
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		if string(got) != sum {
+			continue
+		}
+
+		f, err := s.root.Open(name)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		defer f.Close()
+		setContentType(w, name)
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveCompressed serves name, compressed on the fly from the file without
+// the extension ext.
+func (s *Server) serveCompressed(w http.ResponseWriter, r *http.Request, name, ext string) {
+	source := strings.TrimSuffix(name, ext)
+	f, err := s.root.Open(source)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	info, err := f.Stat()
+	f.Close()
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := s.compute(name, source, info, func(f io.Reader) ([]byte, error) {
+		var b bytes.Buffer
+		cw, err := common.Compress(name, &b)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(cw, f); err != nil {
+			return nil, err
+		}
+		if err := cw.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	})
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	setContentType(w, name)
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(data))
+}
+
+// compute returns the content computed by fn from the file name, cached by
+// key as long as the file, of info, is unmodified.
+func (s *Server) compute(key, name string, info fs.FileInfo, fn func(io.Reader) ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	if e := s.cache[key]; e != nil {
+		if c := e.Value.(*cached); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			s.lru.MoveToFront(e)
+			s.mu.Unlock()
+			return c.data, nil
+		}
+	}
+	s.mu.Unlock()
+
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := fn(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(&cached{key: key, modTime: info.ModTime(), size: info.Size(), data: data})
+	return data, nil
+}
+
+// store caches c, evicting the least recently used content over CacheSize.
+func (s *Server) store(c *cached) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.cache[c.key]; e != nil {
+		s.evict(e)
+	}
+	if int64(len(c.data)) > s.options.CacheSize {
+		return
+	}
+	s.cache[c.key] = s.lru.PushFront(c)
+	s.size += int64(len(c.data))
+	for s.size > s.options.CacheSize {
+		s.evict(s.lru.Back())
+	}
+}
+
+// evict removes the cached content of e.
+func (s *Server) evict(e *list.Element) {
+	c := s.lru.Remove(e).(*cached)
+	delete(s.cache, c.key)
+	s.size -= int64(len(c.data))
+}
+
+// ContentType returns the content type of the file name of a repository.
+func ContentType(name string) string {
+	if contentType, ok := contentTypes[path.Ext(name)]; ok {
+		return contentType
+	} else if path.Ext(name) != "" {
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// setContentType sets the content type of the file name.
+func setContentType(w http.ResponseWriter, name string) {
+	w.Header().Set("Content-Type", ContentType(name))
+}
+
+// serveError replies with the status of err.
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+}
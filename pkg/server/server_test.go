@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/common"
+)
+
+const testPackages = "Package: hello\nVersion: 2.10-2\nArchitecture: amd64\n"
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// newTestServer serves a repository with a Packages index and a pool file.
+func newTestServer(t *testing.T, options *Options) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "dists/focal/main/binary-amd64/Packages"), testPackages)
+	writeTestFile(t, filepath.Join(dir, "dists/focal/Release"), "Suite: focal\n")
+	writeTestFile(t, filepath.Join(dir, "pool/main/hello_2.10-2_amd64.deb"), "!<arch>\n")
+
+	srv := httptest.NewServer(New(dir, options))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func get(t *testing.T, url string, header map[string]string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestServer(t *testing.T) {
+	url := newTestServer(t, nil)
+	packages := "/dists/focal/main/binary-amd64/Packages"
+
+	tests := []struct {
+		desc        string
+		path        string
+		header      map[string]string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			desc:        "index",
+			path:        packages,
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body:        testPackages,
+		},
+		{
+			desc:        "package",
+			path:        "/pool/main/hello_2.10-2_amd64.deb",
+			status:      http.StatusOK,
+			contentType: "application/vnd.debian.binary-package",
+			body:        "!<arch>\n",
+		},
+		{
+			desc:   "range",
+			path:   packages,
+			header: map[string]string{"Range": "bytes=9-13"},
+			status: http.StatusPartialContent,
+			body:   "hello",
+		},
+		{
+			desc:   "by-hash",
+			path:   fmt.Sprintf("/dists/focal/main/binary-amd64/by-hash/SHA256/%x", sha256.Sum256([]byte(testPackages))),
+			status: http.StatusOK,
+			body:   testPackages,
+		},
+		{
+			desc:   "unknown hash",
+			path:   fmt.Sprintf("/dists/focal/main/binary-amd64/by-hash/SHA256/%x", sha256.Sum256(nil)),
+			status: http.StatusNotFound,
+		},
+		{
+			desc:   "compression disabled",
+			path:   packages + ".gz",
+			status: http.StatusNotFound,
+		},
+		{
+			desc:   "outside the repository",
+			path:   "/../../etc/passwd",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		resp, body := get(t, url+test.path, test.header)
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: expect status %d; got %d", test.desc, test.status, resp.StatusCode)
+			continue
+		}
+		if test.status >= 300 {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); test.contentType != "" && got != test.contentType {
+			t.Errorf("%s: expect content type %q; got %q", test.desc, test.contentType, got)
+		}
+		if body != test.body {
+			t.Errorf("%s: expect body %q; got %q", test.desc, test.body, body)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url+packages, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expect status %d for POST; got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestServerCompress(t *testing.T) {
+	url := newTestServer(t, &Options{Compress: true})
+
+	for _, ext := range []string{".gz", ".xz", ".zst"} {
+		name := "/dists/focal/main/binary-amd64/Packages" + ext
+		resp, body := get(t, url+name, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expect status %d; got %d", ext, http.StatusOK, resp.StatusCode)
+			continue
+		}
+		r, err := common.Decompress(name, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ext, err)
+		}
+		d, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ext, err)
+		}
+		if string(d) != testPackages {
+			t.Errorf("%s: expect the compressed index; got %q", ext, d)
+		}
+	}
+
+	if resp, _ := get(t, url+"/dists/focal/main/binary-amd64/Missing.gz", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expect status %d without a source file; got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestServerCacheSize(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "dists/focal/main/binary-amd64/Packages"), testPackages)
+	s := New(dir, &Options{Compress: true, CacheSize: 150})
+
+	for i := 0; i < 2; i++ {
+		for _, ext := range []string{".gz", ".xz", ".zst"} {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dists/focal/main/binary-amd64/Packages"+ext, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("%s: expect status %d; got %d", ext, http.StatusOK, w.Code)
+			}
+		}
+	}
+	if s.size > 150 || len(s.cache) != s.lru.Len() {
+		t.Errorf("expect at most 150 bytes cached; got %d bytes in %d entries", s.size, len(s.cache))
+	}
+	if len(s.cache) == 3 {
+		t.Errorf("expect the least recently used variant evicted")
+	}
+}
@@ -0,0 +1,282 @@
+// Package snapshot stores immutable point-in-time snapshots of mirrored
+// repositories. The files of a snapshot are stored content-addressed by
+// their SHA256 hash, so files unchanged between snapshots are stored once,
+// and a snapshot can be published as a static repository at any time.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// validName matches the names of snapshots.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// ValidName returns whether name is a valid snapshot name: letters, digits
+// and ".", "_", "+", "-", not starting with a punctuation character.
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// File is a file of a snapshot.
+type File struct {
+	// Path is the slash separated path of the file in the repository.
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Snapshot is the manifest of a snapshot.
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	// Sources are the source lines the snapshot was taken from.
+	Sources       []string `json:"sources,omitempty"`
+	Architectures []string `json:"architectures,omitempty"`
+	// Files are the files of the snapshot, sorted by path.
+	Files []File `json:"files"`
+}
+
+// Size returns the total size of the files of the snapshot.
+func (s *Snapshot) Size() int64 {
+	var ret int64
+	for _, f := range s.Files {
+		ret += f.Size
+	}
+	return ret
+}
+
+// Store is a directory of snapshots. The files are stored under
+// blobs/sha256/<hash[:2]>/<hash>, and the manifests of the snapshots under
+// snapshots/<name>.json.
+type Store struct {
+	dir string
+}
+
+// Open opens the store at dir, creating it if missing.
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"blobs/sha256", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// blobPath returns the path of the blob of a SHA256 hash.
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.dir, "blobs", "sha256", hash[:2], hash)
+}
+
+// manifestPath returns the path of the manifest of a snapshot.
+func (s *Store) manifestPath(name string) string {
+	return filepath.Join(s.dir, "snapshots", name+".json")
+}
+
+// Add stores the file at root/name, a slash separated path, unless a file
+// with the same content is stored already, and returns it.
+func (s *Store) Add(root, name string) (File, error) {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "blobs"), ".tmp-")
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), f)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return File{}, err
+	}
+
+	ret := File{Path: name, SHA256: fmt.Sprintf("%x", h.Sum(nil)), Size: n}
+	blob := s.blobPath(ret.SHA256)
+	if _, err := os.Stat(blob); err == nil {
+		return ret, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return File{}, err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp.Name(), blob); err != nil {
+		return File{}, err
+	}
+	return ret, nil
+}
+
+// Open opens the stored content of f.
+func (s *Store) Open(f File) (io.ReadCloser, error) {
+	if len(f.SHA256) != sha256.Size*2 {
+		return nil, fmt.Errorf("%s: invalid SHA256 %q", f.Path, f.SHA256)
+	}
+	return os.Open(s.blobPath(f.SHA256))
+}
+
+// Save writes the manifest of snap, whose files are stored already. A
+// snapshot is immutable: saving a snapshot whose name is taken fails.
+func (s *Store) Save(snap *Snapshot) error {
+	if !ValidName(snap.Name) {
+		return fmt.Errorf("invalid snapshot name %q", snap.Name)
+	}
+	sort.Slice(snap.Files, func(i, j int) bool {
+		return snap.Files[i].Path < snap.Files[j].Path
+	})
+	for _, f := range snap.Files {
+		if _, err := os.Stat(s.blobPath(f.SHA256)); err != nil {
+			return fmt.Errorf("%s is not stored: %w", f.Path, err)
+		}
+	}
+
+	d, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "snapshots"), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(d, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return err
+	}
+
+	// Linking, unlike renaming, fails if the manifest exists.
+	if err := os.Link(tmp.Name(), s.manifestPath(snap.Name)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("snapshot %s already exists", snap.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns the snapshot name.
+func (s *Store) Get(name string) (*Snapshot, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+	d, err := os.ReadFile(s.manifestPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %s not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ret Snapshot
+	if err := json.Unmarshal(d, &ret); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+	return &ret, nil
+}
+
+// List returns the snapshots of the store, oldest first.
+func (s *Store) List() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "snapshots"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		snap, err := s.Get(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, snap)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if !ret[i].Created.Equal(ret[j].Created) {
+			return ret[i].Created.Before(ret[j].Created)
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// Publish writes the files of the snapshot name under dir, at their paths,
+// as a static repository to serve. Files are hard linked to the store when
+// possible, and copied otherwise. Existing files are replaced.
+func (s *Store) Publish(name, dir string) (*Snapshot, error) {
+	snap, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range snap.Files {
+		if path.IsAbs(f.Path) || strings.HasPrefix(path.Clean(f.Path), "..") {
+			return nil, fmt.Errorf("snapshot %s: invalid path %s", name, f.Path)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err := s.link(f, dst); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+	return snap, nil
+}
+
+// link hard links the blob of f to dst, or copies it if linking fails, e.g.
+// across file systems.
+func (s *Store) link(f File, dst string) error {
+	blob := s.blobPath(f.SHA256)
+	if err := os.Link(blob, dst); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// take stores the files of root and saves them as the snapshot name.
+func take(t *testing.T, s *Store, root, name string, paths ...string) *Snapshot {
+	t.Helper()
+
+	snap := &Snapshot{Name: name, Created: time.Date(2022, 4, 21, 0, 0, 0, 0, time.UTC)}
+	for _, p := range paths {
+		f, err := s.Add(root, p)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		snap.Files = append(snap.Files, f)
+	}
+	if err := s.Save(snap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return snap
+}
+
+func countBlobs(t *testing.T, s *Store) int {
+	t.Helper()
+
+	var n int
+	err := filepath.Walk(filepath.Join(s.Dir(), "blobs", "sha256"), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return n
+}
+
+func TestStore(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "dists/focal/InRelease"), "release 1")
+	writeTestFile(t, filepath.Join(root, "pool/main/a.deb"), "a")
+	first := take(t, s, root, "first", "pool/main/a.deb", "dists/focal/InRelease")
+
+	expect := []File{
+		{Path: "dists/focal/InRelease", SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("release 1"))), Size: 9},
+		{Path: "pool/main/a.deb", SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("a"))), Size: 1},
+	}
+	if !cmp.Equal(expect, first.Files) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, first.Files))
+	}
+
+	// The mirror moves on; the unchanged pool file is stored once.
+	writeTestFile(t, filepath.Join(root, "dists/focal/InRelease"), "release 2")
+	writeTestFile(t, filepath.Join(root, "pool/main/b.deb"), "b")
+	take(t, s, root, "second", "dists/focal/InRelease", "pool/main/a.deb", "pool/main/b.deb")
+	if got, expect := countBlobs(t, s), 4; got != expect {
+		t.Errorf("expect %d blobs; got %d", expect, got)
+	}
+
+	if err := s.Save(&Snapshot{Name: "first"}); err == nil {
+		t.Errorf("expect err saving an existing snapshot; got nil")
+	}
+	if err := s.Save(&Snapshot{Name: "../first"}); err == nil {
+		t.Errorf("expect err for an invalid name; got nil")
+	}
+	if _, err := s.Get("missing"); err == nil {
+		t.Errorf("expect err for a missing snapshot; got nil")
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, snap := range list {
+		names = append(names, snap.Name)
+	}
+	if expect := []string{"first", "second"}; !cmp.Equal(expect, names) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, names))
+	}
+
+	got, err := s.Get("first")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmp.Equal(first, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(first, got))
+	}
+
+	dir := t.TempDir()
+	if _, err := s.Publish("first", dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, expect := range map[string]string{
+		"dists/focal/InRelease": "release 1",
+		"pool/main/a.deb":       "a",
+	} {
+		d, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(d) != expect {
+			t.Errorf("expect %s to be %q; got %q", name, expect, d)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pool/main/b.deb")); err == nil {
+		t.Errorf("expect pool/main/b.deb not published")
+	}
+}
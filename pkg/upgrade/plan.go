@@ -0,0 +1,304 @@
+package upgrade
+
+import (
+	"sort"
+
+	"github.com/anfernee/goapt/pkg/dpkg"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/preferences"
+	"github.com/anfernee/goapt/pkg/version"
+)
+
+// Change is a planned change of a single package.
+type Change struct {
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+	// From is the installed version, empty for new packages.
+	From string `json:"from,omitempty"`
+	// To is the candidate version, empty for removals.
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Plan is the upgrade plan of a root filesystem.
+type Plan struct {
+	// Upgrades are installed packages with a newer candidate.
+	Upgrades []Change `json:"upgrades"`
+	// New are packages pulled in by the dependencies of upgrades.
+	New []Change `json:"new"`
+	// Removals are installed packages conflicting with the upgrades.
+	Removals []Change `json:"removals"`
+	// Held are held packages with a newer candidate, which are kept back,
+	// and the packages kept back because they need a newer version of one.
+	Held []Change `json:"held"`
+}
+
+// Options specifies the options of Compute.
+type Options struct {
+	// Preferences pins the candidates like apt_preferences(5). The highest
+	// versions are the candidates if nil.
+	Preferences preferences.Preferences
+	// Origins returns the releases an available package comes from, for
+	// the release pins of Preferences.
+	Origins func(p *pkg.Package) []preferences.Origin
+}
+
+// planner keeps the target state of the system while computing a plan.
+type planner struct {
+	idx    *pkg.Index
+	archs  pkg.Architectures
+	plan   *Plan
+	target map[string]*pkg.Package
+	// installed is the installed package of every target package.
+	installed map[string]*pkg.Package
+	held      map[string]bool
+}
+
+func keyOf(p *pkg.Package) string {
+	return p.Name + ":" + p.Arch
+}
+
+// Compute computes the plan to upgrade installed packages to the
+// candidates from available, the way "apt-get dist-upgrade" would. archs
+// are the architectures of the system, native first; dependencies are only
+// pulled in from them.
+func Compute(installed []*dpkg.Entry, available []pkg.Package, archs pkg.Architectures, options *Options) *Plan {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	p := &planner{
+		idx:   pkg.NewIndex(available),
+		archs: archs,
+		plan: &Plan{
+			Upgrades: []Change{},
+			New:      []Change{},
+			Removals: []Change{},
+			Held:     []Change{},
+		},
+		target:    map[string]*pkg.Package{},
+		installed: map[string]*pkg.Package{},
+		held:      map[string]bool{},
+	}
+
+	if o.Preferences != nil {
+		p.idx.SetPolicy(o.Preferences.Policy(func(pk *pkg.Package) []preferences.Origin {
+			if p.installed[keyOf(pk)] == pk {
+				return []preferences.Origin{{Installed: true}}
+			}
+			if o.Origins != nil {
+				return o.Origins(pk)
+			}
+			return []preferences.Origin{{}}
+		}, nil))
+	}
+
+	var queue []*pkg.Package
+	for _, e := range installed {
+		if !e.Status.IsInstalled() {
+			continue
+		}
+		current := e.Package
+		// The installed versions are candidates too, like in apt
+		p.idx.Add(&current)
+		p.target[keyOf(&current)] = &current
+		p.installed[keyOf(&current)] = &current
+
+		cand := p.idx.Candidate(e.Name, e.Arch)
+		if cand == nil || version.Compare(cand.Version, e.Version) <= 0 {
+			continue
+		}
+		change := Change{Name: e.Name, Arch: e.Arch, From: e.Version, To: cand.Version}
+		if e.Status.Want == "hold" {
+			p.held[keyOf(&current)] = true
+			p.plan.Held = append(p.plan.Held, change)
+			continue
+		}
+		p.plan.Upgrades = append(p.plan.Upgrades, change)
+		p.target[keyOf(&current)] = cand
+		queue = append(queue, cand)
+	}
+
+	changed := append([]*pkg.Package{}, queue...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, added := range p.resolve(next) {
+			queue = append(queue, added)
+			changed = append(changed, added)
+		}
+	}
+
+	// Drop the packages kept back while resolving
+	var kept []*pkg.Package
+	for _, c := range changed {
+		if p.target[keyOf(c)] == c {
+			kept = append(kept, c)
+		}
+	}
+	p.conflicts(kept)
+
+	for _, changes := range [][]Change{p.plan.Upgrades, p.plan.New, p.plan.Removals, p.plan.Held} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		})
+	}
+	return p.plan
+}
+
+// resolve adds the missing dependencies of pk to the target, upgrading
+// installed packages if needed, and returns the added packages. If a
+// dependency needs a newer version of a held package, pk is kept back
+// instead.
+func (p *planner) resolve(pk *pkg.Package) []*pkg.Package {
+	var picks []*pkg.Package
+	for _, group := range append(append(pkg.Relations{}, pk.PreDepends...), pk.Depends...) {
+		if p.satisfied(group, pk.Arch) {
+			continue
+		}
+		var held *pkg.Package
+		for _, r := range group {
+			cands := p.idx.Satisfy(r, pk.Arch, p.archs)
+			if len(cands) == 0 {
+				continue
+			}
+			if p.held[keyOf(cands[0])] {
+				held = cands[0]
+				continue
+			}
+			picks = append(picks, cands[0])
+			held = nil
+			break
+		}
+		if held != nil {
+			p.keepBack(pk, "depends on held "+held.Name)
+			return nil
+		}
+	}
+
+	var ret []*pkg.Package
+	for _, cand := range picks {
+		key := keyOf(cand)
+		if p.target[key] == cand {
+			continue
+		}
+		p.target[key] = cand
+		change := Change{Name: cand.Name, Arch: cand.Arch, To: cand.Version, Reason: "dependency of " + pk.Name}
+		if installed, ok := p.installed[key]; ok {
+			change.From = installed.Version
+			p.plan.Upgrades = append(removeChange(p.plan.Upgrades, cand), change)
+		} else {
+			p.plan.New = append(p.plan.New, change)
+		}
+		ret = append(ret, cand)
+	}
+	return ret
+}
+
+// keepBack reverts pk to its installed version, or drops it if it is not
+// installed, and reports it as held.
+func (p *planner) keepBack(pk *pkg.Package, reason string) {
+	key := keyOf(pk)
+	change := Change{Name: pk.Name, Arch: pk.Arch, To: pk.Version, Reason: reason}
+	if installed, ok := p.installed[key]; ok {
+		p.target[key] = installed
+		p.plan.Upgrades = removeChange(p.plan.Upgrades, pk)
+		change.From = installed.Version
+	} else {
+		delete(p.target, key)
+		p.plan.New = removeChange(p.plan.New, pk)
+	}
+	p.plan.Held = append(p.plan.Held, change)
+}
+
+// removeChange removes the change of the package of pk from changes.
+func removeChange(changes []Change, pk *pkg.Package) []Change {
+	ret := changes[:0]
+	for _, c := range changes {
+		if c.Name != pk.Name || c.Arch != pk.Arch {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// satisfied returns whether any alternative of group, a dependency of a
+// package of arch, is satisfied by the target state.
+func (p *planner) satisfied(group []pkg.Relation, arch string) bool {
+	for _, r := range group {
+		for _, t := range p.target {
+			if t.SatisfiesOn(r, arch, p.archs.Native()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conflicts removes installed packages that conflict with, or are broken by,
+// the changed packages, or that declare a conflict against them.
+func (p *planner) conflicts(changed []*pkg.Package) {
+	isChanged := map[string]bool{}
+	for _, c := range changed {
+		isChanged[keyOf(c)] = true
+	}
+
+	remove := func(key string, reason string) {
+		t := p.target[key]
+		if t == nil {
+			return
+		}
+		delete(p.target, key)
+		p.plan.Removals = append(p.plan.Removals, Change{
+			Name:   t.Name,
+			Arch:   t.Arch,
+			From:   versionOf(p.installed[key]),
+			Reason: reason,
+		})
+	}
+
+	keys := make([]string, 0, len(p.target))
+	for key := range p.target {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, c := range changed {
+		for _, group := range append(append(pkg.Relations{}, c.Conflicts...), c.Breaks...) {
+			for _, r := range group {
+				for _, key := range keys {
+					t := p.target[key]
+					if t == nil || isChanged[key] || t.Name == c.Name {
+						continue
+					}
+					if t.Satisfies(r) {
+						remove(key, "conflicts with "+c.Name)
+					}
+				}
+			}
+		}
+	}
+
+	for _, key := range keys {
+		t := p.target[key]
+		if t == nil || isChanged[key] {
+			continue
+		}
+		for _, group := range append(append(pkg.Relations{}, t.Conflicts...), t.Breaks...) {
+			for _, r := range group {
+				for _, c := range changed {
+					if c.Name != t.Name && c.Satisfies(r) && p.target[key] != nil {
+						remove(key, "conflicts with "+c.Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func versionOf(p *pkg.Package) string {
+	if p == nil {
+		return ""
+	}
+	return p.Version
+}
@@ -0,0 +1,90 @@
+package upgrade
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/dpkg"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/anfernee/goapt/pkg/preferences"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompute(t *testing.T) {
+	db, err := dpkg.Load("testdata/status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	available, err := pkg.Load("testdata/Packages")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := &Plan{
+		Upgrades: []Change{
+			{Name: "libc6", Arch: "amd64", From: "2.31-0ubuntu9.7", To: "2.31-0ubuntu9.9"},
+			{Name: "libssl1.1", Arch: "amd64", From: "1.1.1f-1ubuntu2.12", To: "1.1.1f-1ubuntu2.16"},
+			{Name: "openssl", Arch: "amd64", From: "1.1.1f-1ubuntu2.12", To: "1.1.1f-1ubuntu2.16"},
+		},
+		New: []Change{
+			{Name: "libcrypt1", Arch: "amd64", To: "1:4.4.10-10ubuntu4", Reason: "dependency of libc6"},
+		},
+		Removals: []Change{
+			{Name: "legacy-tool", Arch: "amd64", From: "0.9-1", Reason: "conflicts with openssl"},
+		},
+		Held: []Change{
+			{Name: "zlib-tool", Arch: "amd64", From: "1.0-1", To: "2.0-1", Reason: "depends on held zlib1g"},
+			{Name: "zlib1g", Arch: "amd64", From: "1:1.2.11.dfsg-2ubuntu1.2", To: "1:1.2.11.dfsg-2ubuntu1.5"},
+		},
+	}
+
+	got := Compute(db.Packages(), available, pkg.Architectures{"amd64"}, nil)
+	if !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestComputePreferences(t *testing.T) {
+	db, err := dpkg.Load("testdata/status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	available, err := pkg.Load("testdata/Packages")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prefs, err := preferences.Parse(strings.NewReader(`Package: libssl1.1
+Pin: version 1.1.1f-1ubuntu2.12
+Pin-Priority: 1001
+
+Package: openssl
+Pin: release a=focal-security
+Pin-Priority: -1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := Compute(db.Packages(), available, pkg.Architectures{"amd64"}, &Options{
+		Preferences: prefs,
+		Origins: func(p *pkg.Package) []preferences.Origin {
+			return []preferences.Origin{{Archive: "focal-security"}}
+		},
+	})
+	expect := &Plan{
+		Upgrades: []Change{
+			{Name: "libc6", Arch: "amd64", From: "2.31-0ubuntu9.7", To: "2.31-0ubuntu9.9"},
+		},
+		New: []Change{
+			{Name: "libcrypt1", Arch: "amd64", To: "1:4.4.10-10ubuntu4", Reason: "dependency of libc6"},
+		},
+		Removals: []Change{},
+		Held: []Change{
+			{Name: "zlib-tool", Arch: "amd64", From: "1.0-1", To: "2.0-1", Reason: "depends on held zlib1g"},
+			{Name: "zlib1g", Arch: "amd64", From: "1:1.2.11.dfsg-2ubuntu1.2", To: "1:1.2.11.dfsg-2ubuntu1.5"},
+		},
+	}
+	if !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
@@ -0,0 +1,154 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a debian package version: [epoch:]upstream_version[-debian_revision].
+// Check deb-version(7) for details.
+type Version struct {
+	Epoch    int
+	Upstream string
+	Revision string
+}
+
+// Parse parses a debian package version. Invalid epochs are treated as 0.
+func Parse(s string) Version {
+	var v Version
+
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ":"); i >= 0 {
+		v.Epoch, _ = strconv.Atoi(s[:i])
+		s = s[i+1:]
+	}
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		v.Revision = s[i+1:]
+		s = s[:i]
+	}
+	v.Upstream = s
+	return v
+}
+
+func (v Version) String() string {
+	s := v.Upstream
+	if v.Epoch != 0 {
+		s = strconv.Itoa(v.Epoch) + ":" + s
+	}
+	if v.Revision != "" {
+		s += "-" + v.Revision
+	}
+	return s
+}
+
+// Compare compares two versions the way dpkg does. It returns -1, 0 or 1
+// if a is older than, equal to, or newer than b.
+func Compare(a, b string) int {
+	va, vb := Parse(a), Parse(b)
+	switch {
+	case va.Epoch < vb.Epoch:
+		return -1
+	case va.Epoch > vb.Epoch:
+		return 1
+	}
+	if c := compareString(va.Upstream, vb.Upstream); c != 0 {
+		return c
+	}
+	return compareString(va.Revision, vb.Revision)
+}
+
+// order returns the sort weight of a character in the non digit part:
+// '~' sorts before everything, even the end of the string, and letters sort
+// before non letters.
+func order(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	case c != 0:
+		return int(c) + 256
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareString is dpkg's verrevcmp.
+func compareString(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		diff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			var ac, bc int
+			if i < len(a) {
+				ac = order(a[i])
+			}
+			if j < len(b) {
+				bc = order(b[j])
+			}
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if diff == 0 {
+				diff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if diff != 0 {
+			return sign(diff)
+		}
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// Satisfies returns whether version v satisfies the relation "op other",
+// where op is one of <<, <=, =, >=, >> as used in package relationships.
+// The obsolete "<" and ">" are treated as "<=" and ">=".
+func Satisfies(v, op, other string) bool {
+	c := Compare(v, other)
+	switch op {
+	case "<<":
+		return c < 0
+	case "<=", "<":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=", ">":
+		return c >= 0
+	case ">>":
+		return c > 0
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0-1", "1.0-2", -1},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0", "1.0+b1", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0+", -1},
+		{"2.31-0ubuntu9.9", "2.31-0ubuntu9.10", -1},
+		{"1:1.2.11.dfsg-2ubuntu1.5", "1:1.2.11.dfsg-2ubuntu1.2", 1},
+		{"0:1.0", "1.0", 0},
+		{"1.001", "1.1", 0},
+	}
+
+	for _, test := range tests {
+		if got := Compare(test.a, test.b); got != test.expect {
+			t.Errorf("Compare(%q, %q): expect %d; got %d", test.a, test.b, test.expect, got)
+		}
+		if got := Compare(test.b, test.a); got != -test.expect {
+			t.Errorf("Compare(%q, %q): expect %d; got %d", test.b, test.a, -test.expect, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	v := Parse("1:2.3-4-5ubuntu1")
+	if v.Epoch != 1 || v.Upstream != "2.3-4" || v.Revision != "5ubuntu1" {
+		t.Errorf("unexpected version %#v", v)
+	}
+	if v.String() != "1:2.3-4-5ubuntu1" {
+		t.Errorf("expect 1:2.3-4-5ubuntu1; got %s", v)
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		v, op, other string
+		expect       bool
+	}{
+		{"2.31", ">=", "2.31", true},
+		{"2.30", ">=", "2.31", false},
+		{"2.30", "<<", "2.31", true},
+		{"2.31", "<<", "2.31", false},
+		{"2.31", "=", "2.31", true},
+		{"2.32", ">>", "2.31", true},
+		{"2.31", "<=", "2.31", true},
+	}
+	for _, test := range tests {
+		if got := Satisfies(test.v, test.op, test.other); got != test.expect {
+			t.Errorf("%s %s %s: expect %v; got %v", test.v, test.op, test.other, test.expect, got)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Package why answers reverse dependency queries over a set of packages:
+// which packages depend on a package, and why a package is, or can't be,
+// installed.
+package why
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/anfernee/goapt/pkg/common"
+	pkg "github.com/anfernee/goapt/pkg/package"
+)
+
+// Dependency fields of an Edge, which pull in packages.
+const (
+	PreDepends = "Pre-Depends"
+	Depends    = "Depends"
+	Recommends = "Recommends"
+)
+
+// Conflict fields of a Conflict, which prevent packages from being
+// installed together.
+const (
+	Conflicts = "Conflicts"
+	Breaks    = "Breaks"
+)
+
+// Edge is a dependency of a package on another, satisfied either directly
+// or through Provides.
+type Edge struct {
+	From     *pkg.Package
+	To       *pkg.Package
+	Field    string
+	Relation pkg.Relation
+}
+
+func (e Edge) String() string {
+	return e.From.Name + " " + e.Field + " " + e.Relation.String()
+}
+
+// Graph is the dependency graph of a set of packages.
+type Graph struct {
+	native  string
+	pkgs    []*pkg.Package
+	byName  map[string][]*pkg.Package
+	provide map[string][]*pkg.Package
+	forward map[*pkg.Package][]Edge
+	reverse map[*pkg.Package][]Edge
+}
+
+// NewGraph creates the dependency graph of pkgs, e.g. the installed
+// packages of a system, on a system of native architecture.
+func NewGraph(pkgs []pkg.Package, native string) *Graph {
+	g := &Graph{
+		native:  native,
+		byName:  map[string][]*pkg.Package{},
+		provide: map[string][]*pkg.Package{},
+		forward: map[*pkg.Package][]Edge{},
+		reverse: map[*pkg.Package][]Edge{},
+	}
+	for i := range pkgs {
+		p := &pkgs[i]
+		g.pkgs = append(g.pkgs, p)
+		g.byName[p.Name] = append(g.byName[p.Name], p)
+		for _, group := range p.Provides {
+			for _, r := range group {
+				g.provide[r.Name] = append(g.provide[r.Name], p)
+			}
+		}
+	}
+
+	for _, p := range g.pkgs {
+		for _, field := range []string{PreDepends, Depends, Recommends} {
+			for _, group := range relations(p, field) {
+				for _, r := range group {
+					for _, to := range g.satisfying(r, p.Arch) {
+						if to == p {
+							continue
+						}
+						e := Edge{From: p, To: to, Field: field, Relation: r}
+						g.forward[p] = append(g.forward[p], e)
+						g.reverse[to] = append(g.reverse[to], e)
+					}
+				}
+			}
+		}
+	}
+	return g
+}
+
+func relations(p *pkg.Package, field string) pkg.Relations {
+	switch field {
+	case PreDepends:
+		return p.PreDepends
+	case Depends:
+		return p.Depends
+	case Recommends:
+		return p.Recommends
+	case Conflicts:
+		return p.Conflicts
+	case Breaks:
+		return p.Breaks
+	}
+	return nil
+}
+
+// satisfying returns the packages of the graph satisfying r, a relation of
+// a package of arch.
+func (g *Graph) satisfying(r pkg.Relation, arch string) []*pkg.Package {
+	var ret []*pkg.Package
+	for _, p := range g.byName[r.Name] {
+		if p.SatisfiesOn(r, arch, g.native) {
+			ret = append(ret, p)
+		}
+	}
+	for _, p := range g.provide[r.Name] {
+		if p.Name != r.Name && p.SatisfiesOn(r, arch, g.native) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// Get returns the packages of the graph named name, which may be qualified
+// with an architecture, "name:arch".
+func (g *Graph) Get(name string) []*pkg.Package {
+	name, arch, _ := strings.Cut(name, ":")
+	var ret []*pkg.Package
+	for _, p := range g.byName[name] {
+		if arch == "" || p.Arch == arch {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// Depends returns the dependencies of p.
+func (g *Graph) Depends(p *pkg.Package) []Edge {
+	return g.forward[p]
+}
+
+// RDepends returns the reverse dependencies of p, sorted by the name of the
+// depending packages.
+func (g *Graph) RDepends(p *pkg.Package) []Edge {
+	ret := append([]Edge{}, g.reverse[p]...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].From.Name < ret[j].From.Name
+	})
+	return ret
+}
+
+// Why returns the shortest dependency chain from one of roots to p, the
+// first edge starting at the root. It returns an empty chain if p is a root,
+// and nil if no root depends on p. Pre-Depends and Depends are preferred
+// to Recommends.
+func (g *Graph) Why(p *pkg.Package, roots []*pkg.Package) []Edge {
+	isRoot := map[*pkg.Package]bool{}
+	for _, r := range roots {
+		isRoot[r] = true
+	}
+	if isRoot[p] {
+		return []Edge{}
+	}
+
+	// Search backwards from p, first along the hard dependencies only
+	for _, fields := range [][]string{{PreDepends, Depends}, {PreDepends, Depends, Recommends}} {
+		if chain := g.search(p, isRoot, fields); chain != nil {
+			return chain
+		}
+	}
+	return nil
+}
+
+func (g *Graph) search(p *pkg.Package, isRoot map[*pkg.Package]bool, fields []string) []Edge {
+	follow := map[string]bool{}
+	for _, f := range fields {
+		follow[f] = true
+	}
+
+	// next is the edge towards p of every visited package
+	next := map[*pkg.Package]*Edge{p: nil}
+	queue := []*pkg.Package{p}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, e := range g.RDepends(cur) {
+			e := e
+			if !follow[e.Field] {
+				continue
+			}
+			if _, ok := next[e.From]; ok {
+				continue
+			}
+			next[e.From] = &e
+			if isRoot[e.From] {
+				var chain []Edge
+				for n := next[e.From]; n != nil; n = next[n.To] {
+					chain = append(chain, *n)
+				}
+				return chain
+			}
+			queue = append(queue, e.From)
+		}
+	}
+	return nil
+}
+
+// Roots returns the packages of the graph not marked as automatically
+// installed by auto, keyed by "name:arch" where architecture independent
+// packages have the native architecture, like in apt extended states. If
+// auto is empty, the roots are the essential packages and the packages no
+// other package depends on.
+func (g *Graph) Roots(auto map[string]bool) []*pkg.Package {
+	var ret []*pkg.Package
+	for _, p := range g.pkgs {
+		arch := p.Arch
+		if arch == pkg.ArchAll {
+			arch = g.native
+		}
+		switch {
+		case len(auto) > 0:
+			if !auto[p.Name+":"+arch] {
+				ret = append(ret, p)
+			}
+		case p.Essential || len(g.reverse[p]) == 0:
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// conflicting returns the packages of the graph matching r, a Conflicts or
+// Breaks relation.
+func (g *Graph) conflicting(r pkg.Relation) []*pkg.Package {
+	var ret []*pkg.Package
+	for _, p := range g.byName[r.Name] {
+		if conflicts(p, r) {
+			ret = append(ret, p)
+		}
+	}
+	for _, p := range g.provide[r.Name] {
+		if p.Name != r.Name && conflicts(p, r) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// conflicts returns whether p matches r, a Conflicts or Breaks relation.
+// Unlike dependencies, they apply to packages of every architecture unless
+// qualified.
+func conflicts(p *pkg.Package, r pkg.Relation) bool {
+	if r.Arch != "" && r.Arch != "any" && p.Arch != r.Arch {
+		return false
+	}
+	return p.Satisfies(r)
+}
+
+// Conflict is a conflict between a package of the graph and another
+// package.
+type Conflict struct {
+	// Installed is the package of the graph.
+	Installed *pkg.Package
+	// Other is the package outside of the graph.
+	Other *pkg.Package
+	// Declared is the package declaring the conflict, either Installed or
+	// Other.
+	Declared *pkg.Package
+	Field    string
+	Relation pkg.Relation
+}
+
+func (c Conflict) String() string {
+	return c.Declared.Name + " " + c.Field + " " + c.Relation.String()
+}
+
+// WhyNot returns the packages of the graph that prevent p from being
+// installed: the packages p conflicts with or breaks, and the packages
+// conflicting with or breaking p.
+func (g *Graph) WhyNot(p *pkg.Package) []Conflict {
+	var ret []Conflict
+	for _, field := range []string{Conflicts, Breaks} {
+		for _, group := range relations(p, field) {
+			for _, r := range group {
+				for _, installed := range g.conflicting(r) {
+					if installed.Name == p.Name {
+						// A newer version replaces the installed one
+						continue
+					}
+					ret = append(ret, Conflict{Installed: installed, Other: p, Declared: p, Field: field, Relation: r})
+				}
+			}
+		}
+	}
+
+	for _, installed := range g.pkgs {
+		if installed.Name == p.Name {
+			continue
+		}
+		for _, field := range []string{Conflicts, Breaks} {
+			for _, group := range relations(installed, field) {
+				for _, r := range group {
+					if conflicts(p, r) {
+						ret = append(ret, Conflict{Installed: installed, Other: p, Declared: installed, Field: field, Relation: r})
+					}
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// LoadAutoInstalled loads the packages marked as automatically installed
+// in the apt extended states at path, keyed by "name:arch". A missing file
+// is not an error.
+func LoadAutoInstalled(path string) (map[string]bool, error) {
+	ret := map[string]bool{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		p, err := common.ReadParagraph(r)
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if p.Get("Auto-Installed") == "1" {
+			ret[p.Get("Package")+":"+p.Get("Architecture")] = true
+		}
+	}
+}
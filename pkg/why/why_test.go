@@ -0,0 +1,127 @@
+package why
+
+import (
+	"testing"
+
+	"github.com/anfernee/goapt/pkg/dpkg"
+	pkg "github.com/anfernee/goapt/pkg/package"
+	"github.com/google/go-cmp/cmp"
+)
+
+func loadGraph(t *testing.T) *Graph {
+	t.Helper()
+
+	db, err := dpkg.Load("testdata/status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var pkgs []pkg.Package
+	for _, e := range db.Installed() {
+		pkgs = append(pkgs, e.Package)
+	}
+	return NewGraph(pkgs, "amd64")
+}
+
+func edges(chain []Edge) []string {
+	ret := []string{}
+	for _, e := range chain {
+		ret = append(ret, e.String())
+	}
+	return ret
+}
+
+func names(pkgs []*pkg.Package) []string {
+	var ret []string
+	for _, p := range pkgs {
+		ret = append(ret, p.Name)
+	}
+	return ret
+}
+
+func TestRDepends(t *testing.T) {
+	g := loadGraph(t)
+
+	expect := []string{
+		"perl Depends perl-base (= 5.30.0-9ubuntu0.2)",
+		"ubuntu-minimal Depends perl-base",
+	}
+	if got := edges(g.RDepends(g.Get("perl-base")[0])); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+	if got := g.Get("perl-base:i386"); len(got) != 0 {
+		t.Errorf("expect no perl-base:i386; got %v", names(got))
+	}
+}
+
+func TestWhy(t *testing.T) {
+	g := loadGraph(t)
+	auto, err := LoadAutoInstalled("testdata/extended_states")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roots := g.Roots(auto)
+	if expect, got := []string{"git", "mawk", "ubuntu-minimal"}, names(roots); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+
+	tests := map[string][]string{
+		"perl":      {"git Depends perl"},
+		"perl-base": {"ubuntu-minimal Depends perl-base"},
+		"libapt-pkg6.0": {
+			"ubuntu-minimal Depends apt",
+			"apt Depends libapt-pkg6.0 (>= 2.0.9)",
+		},
+		"ca-certificates": {
+			"ubuntu-minimal Depends apt",
+			"apt Recommends ca-certificates",
+		},
+		"git": {},
+	}
+	for name, expect := range tests {
+		if got := edges(g.Why(g.Get(name)[0], roots)); !cmp.Equal(expect, got) {
+			t.Errorf("%s: unexpected diff: %v", name, cmp.Diff(expect, got))
+		}
+	}
+}
+
+func TestRootsWithoutExtendedStates(t *testing.T) {
+	g := loadGraph(t)
+	auto, err := LoadAutoInstalled("testdata/missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect, got := []string{"git", "mawk", "perl-base", "ubuntu-minimal"}, names(g.Roots(auto)); !cmp.Equal(expect, got) {
+		t.Errorf("unexpected diff: %v", cmp.Diff(expect, got))
+	}
+}
+
+func TestWhyNot(t *testing.T) {
+	g := loadGraph(t)
+
+	newPackage := func(name, conflicts string) *pkg.Package {
+		p := &pkg.Package{Arch: "amd64"}
+		p.Name = name
+		p.Version = "1.0"
+		p.Conflicts, _ = pkg.ParseRelations(conflicts)
+		return p
+	}
+
+	tests := []struct {
+		pkg    *pkg.Package
+		expect []string
+	}{
+		{pkg: newPackage("gawk", ""), expect: []string{"mawk Conflicts gawk"}},
+		{pkg: newPackage("openssl-legacy", ""), expect: []string{"ca-certificates Breaks openssl-legacy"}},
+		{pkg: newPackage("busybox-awk", "awk"), expect: []string{"busybox-awk Conflicts awk"}},
+		{pkg: newPackage("hello", ""), expect: []string{}},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for _, c := range g.WhyNot(test.pkg) {
+			got = append(got, c.String())
+		}
+		if !cmp.Equal(test.expect, got) {
+			t.Errorf("%s: unexpected diff: %v", test.pkg.Name, cmp.Diff(test.expect, got))
+		}
+	}
+}